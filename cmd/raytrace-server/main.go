@@ -0,0 +1,40 @@
+//Command raytrace-server renders scenes posted to it over HTTP. See
+//server.Server for the endpoints.
+//
+//Usage:
+//
+//	raytrace-server [-addr localhost:8080] [-jobs 2] [-queue 100] [-threads n]
+//		[-max-width 4096] [-max-height 4096] [-max-samples 256]
+//		[-parse-timeout 10s] [-job-ttl 1h] [-root dir]
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"runtime"
+
+	"github.com/calbim/ray-tracer/src/server"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	jobs := flag.Int("jobs", 2, "number of jobs rendered at once")
+	queue := flag.Int("queue", 100, "number of jobs that can wait to be rendered")
+	threads := flag.Int("threads", runtime.NumCPU(), "number of rows each job renders at once")
+	maxWidth := flag.Int("max-width", server.DefaultMaxWidth, "largest image width a job may ask for")
+	maxHeight := flag.Int("max-height", server.DefaultMaxHeight, "largest image height a job may ask for")
+	maxSamples := flag.Int("max-samples", server.DefaultMaxSamples, "most samples per pixel a job may ask for")
+	parseTimeout := flag.Duration("parse-timeout", server.DefaultParseTimeout, "how long a scene may take to read")
+	jobTTL := flag.Duration("job-ttl", server.DefaultJobTTL, "how long finished jobs are kept")
+	root := flag.String("root", "", "directory scenes may include files and read images from; none when empty")
+	flag.Parse()
+
+	s := server.New(*jobs, *queue)
+	s.Threads = *threads
+	s.MaxWidth, s.MaxHeight, s.MaxSamples = *maxWidth, *maxHeight, *maxSamples
+	s.ParseTimeout, s.JobTTL = *parseTimeout, *jobTTL
+	s.Root = *root
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, s))
+}
@@ -0,0 +1,29 @@
+//Command raytrace-worker renders tiles of scenes for raytrace -workers. See
+//package distributed.
+//
+//Usage:
+//
+//	raytrace-worker [-addr :7070] [-threads n]
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"runtime"
+
+	"github.com/calbim/ray-tracer/src/distributed"
+)
+
+func main() {
+	addr := flag.String("addr", ":7070", "address to listen on")
+	threads := flag.Int("threads", runtime.NumCPU(), "number of rows of a tile rendered at once")
+	flag.Parse()
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("listening on %s", l.Addr())
+	log.Fatal(distributed.Serve(l, &distributed.Worker{Threads: *threads}))
+}
@@ -0,0 +1,273 @@
+//Command raytrace renders a scene file to an image.
+//
+//Usage:
+//
+//	raytrace [flags] scene.yml
+//
+//The scene may be YAML or JSON, as read by scene.Load. Progress and timing
+//are printed to standard error. With -watch, the scene file and the files it
+//includes or reads images from are polled for changes, and a small preview
+//is written to the output path after each one until the command is stopped.
+//With -workers, the image is rendered in tiles by raytrace-worker processes
+//at the given addresses instead of on this machine.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/calbim/ray-tracer/src/camera"
+	"github.com/calbim/ray-tracer/src/canvas"
+	"github.com/calbim/ray-tracer/src/distributed"
+	"github.com/calbim/ray-tracer/src/scene"
+	"github.com/calbim/ray-tracer/src/world"
+)
+
+func main() {
+	err := run(os.Args[1:], os.Stderr)
+	switch {
+	case err == flag.ErrHelp:
+	case err == errUsage:
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "raytrace:", err)
+		os.Exit(1)
+	}
+}
+
+//errUsage is returned for bad arguments, after the usage has been printed
+var errUsage = errors.New("usage")
+
+type options struct {
+	scene   string
+	output  string
+	format  string
+	width   int
+	height  int
+	samples int
+	threads int
+	depth   int
+	quiet   bool
+	watch   bool
+	poll    time.Duration
+	preview float64
+	workers []string
+	stats   bool
+	shader  world.Shader
+}
+
+func parseArgs(args []string, stderr io.Writer) (*options, error) {
+	o := &options{}
+	flags := flag.NewFlagSet("raytrace", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&o.output, "o", "", "output image `path` (default: the scene's name with the format's extension)")
+	flags.StringVar(&o.format, "format", "", "output format, png or ppm (default: from the output path, or png)")
+	flags.IntVar(&o.width, "width", 0, "image width in pixels (default: the scene camera's)")
+	flags.IntVar(&o.height, "height", 0, "image height in pixels (default: the scene camera's)")
+	flags.IntVar(&o.samples, "samples", 1, "rays per pixel")
+	flags.IntVar(&o.threads, "threads", runtime.NumCPU(), "number of rows rendered at once")
+	flags.IntVar(&o.depth, "depth", 0, "maximum number of reflection bounces (default: the scene's)")
+	flags.BoolVar(&o.quiet, "quiet", false, "don't print progress and timing")
+	flags.BoolVar(&o.stats, "stats", false, "print counts of rays and intersection tests after rendering")
+	flags.BoolVar(&o.watch, "watch", false, "render a preview each time the scene or a file it uses changes")
+	flags.DurationVar(&o.poll, "poll", 500*time.Millisecond, "how often files are checked for changes in watch mode")
+	flags.Float64Var(&o.preview, "preview", 0.25, "size of the preview in watch mode, as a fraction of the image size")
+	shader := flags.String("shader", "", "debug view to render in place of lighting, one of "+strings.Join(world.ShaderNames(), ", "))
+	workers := flags.String("workers", "", "comma separated `addresses` of raytrace-worker processes to render on")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: raytrace [flags] scene.yml")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil, err
+		}
+		return nil, errUsage
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return nil, errUsage
+	}
+	o.scene = flags.Arg(0)
+	if *shader != "" {
+		var ok bool
+		if o.shader, ok = world.ShaderNamed(*shader); !ok {
+			return nil, fmt.Errorf("unknown shader %q, expected one of %s", *shader, strings.Join(world.ShaderNames(), ", "))
+		}
+	}
+	if *workers != "" {
+		o.workers = strings.Split(*workers, ",")
+		if o.watch || o.stats || o.shader != nil {
+			return nil, errors.New("-workers cannot be used with -watch, -stats or -shader")
+		}
+	}
+	if o.samples < 1 || o.threads < 1 {
+		return nil, errors.New("-samples and -threads must be at least 1")
+	}
+	if o.width < 0 || o.height < 0 || o.depth < 0 {
+		return nil, errors.New("-width, -height and -depth cannot be negative")
+	}
+	if o.poll <= 0 || o.preview <= 0 {
+		return nil, errors.New("-poll and -preview must be positive")
+	}
+	if o.format == "" {
+		switch strings.ToLower(filepath.Ext(o.output)) {
+		case ".ppm":
+			o.format = "ppm"
+		case ".png", "":
+			o.format = "png"
+		default:
+			return nil, fmt.Errorf("cannot tell the format of %s from its extension, use -format", o.output)
+		}
+	}
+	if o.format != "png" && o.format != "ppm" {
+		return nil, fmt.Errorf("unknown format %q, expected png or ppm", o.format)
+	}
+	if o.output == "" {
+		name := filepath.Base(o.scene)
+		o.output = strings.TrimSuffix(name, filepath.Ext(name)) + "." + o.format
+	}
+	return o, nil
+}
+
+func run(args []string, stderr io.Writer) error {
+	o, err := parseArgs(args, stderr)
+	if err != nil {
+		return err
+	}
+	log := stderr
+	if o.quiet {
+		log = ioutil.Discard
+	}
+	if o.watch {
+		return watch(o, log, stderr, nil)
+	}
+	s, _, err := load(o, log)
+	if err != nil {
+		return err
+	}
+	return render(s, o, false, nil, log)
+}
+
+//load loads the scene, returning the files it read or tried even when
+//loading fails
+func load(o *options, log io.Writer) (*scene.Scene, []string, error) {
+	start := time.Now()
+	s, files, err := scene.LoadFiles(o.scene)
+	if err != nil {
+		return nil, files, err
+	}
+	fmt.Fprintf(log, "loaded %s in %v, %d objects\n", o.scene, time.Since(start).Round(time.Millisecond), len(s.World.Objects))
+	return s, files, nil
+}
+
+//errCanceled is returned by render when it is stopped early
+var errCanceled = errors.New("render canceled")
+
+//render renders a scene and writes the image. A preview is rendered at a
+//fraction of the size with one sample per pixel. A scene that panics while
+//rendering is reported as an error like one that fails to load.
+func render(s *scene.Scene, o *options, preview bool, cancel <-chan struct{}, log io.Writer) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintln(log)
+			err = fmt.Errorf("%s: render failed: %v", o.scene, r)
+		}
+	}()
+	scale, samples := 1.0, o.samples
+	if preview {
+		scale, samples = o.preview, 1
+	}
+	c := s.Camera.Resize(float64(o.width), float64(o.height))
+	if scale != 1 {
+		c = c.Resize(math.Round(c.HSize*scale), math.Round(c.VSize*scale))
+	}
+	if o.depth > 0 {
+		s.World.MaxDepth = o.depth
+	}
+	s.World.Shader = o.shader
+
+	start := time.Now()
+	percent := -1
+	progress := func(done, total int) {
+		if p := 100 * done / total; p != percent {
+			percent = p
+			fmt.Fprintf(log, "\rrendering %3d%%", p)
+		}
+	}
+	var image *canvas.Canvas
+	on := fmt.Sprintf("%d threads", o.threads)
+	if o.workers != nil {
+		sc := *s
+		sc.Camera = c
+		image, err = distributed.Render(&sc, o.workers, distributed.Options{Samples: samples, Progress: progress})
+		if err != nil {
+			fmt.Fprintln(log)
+			return err
+		}
+		on = fmt.Sprintf("%d workers", len(o.workers))
+	} else {
+		if o.stats {
+			s.World.Stats = &world.Stats{}
+		}
+		image = c.RenderWith(s.World, camera.Options{
+			Samples:  samples,
+			Threads:  o.threads,
+			Progress: progress,
+			Cancel:   cancel,
+		})
+	}
+	select {
+	case <-cancel:
+		fmt.Fprintln(log, "\rrender canceled")
+		return errCanceled
+	default:
+	}
+	elapsed := time.Since(start)
+	pixels := c.HSize * c.VSize
+	fmt.Fprintf(log, "\rrendered %vx%v with %d samples per pixel on %s in %v, %.0f pixels/s\n",
+		c.HSize, c.VSize, samples, on, elapsed.Round(time.Millisecond), pixels/elapsed.Seconds())
+
+	if err := write(image, o.output, o.format); err != nil {
+		return err
+	}
+	fmt.Fprintf(log, "wrote %s\n", o.output)
+	if s.World.Stats != nil {
+		fmt.Fprint(log, s.World.Stats)
+	}
+	return nil
+}
+
+//write saves an image through a temporary file, so that viewers watching
+//the path never see it half written
+func write(image *canvas.Canvas, path, format string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if format == "ppm" {
+		_, err = io.WriteString(f, image.ToPPM())
+	} else {
+		err = image.ToPNG(f)
+	}
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
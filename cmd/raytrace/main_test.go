@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/calbim/ray-tracer/src/distributed"
+)
+
+const testScene = `
+- add: camera
+  width: 40
+  height: 20
+  field-of-view: 0.785
+  from: [0, 1.5, -5]
+  to: [0, 1, 0]
+  up: [0, 1, 0]
+- add: light
+  at: [-10, 10, -10]
+  intensity: [1, 1, 1]
+- add: sphere
+  transform:
+    - [translate, 0, 1, 0]
+`
+
+func writeScene(t *testing.T, text string) string {
+	path := filepath.Join(t.TempDir(), "test.yml")
+	if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunWritesPNG(t *testing.T) {
+	path := writeScene(t, testScene)
+	out := filepath.Join(filepath.Dir(path), "out.png")
+	var log bytes.Buffer
+	if err := run([]string{"-o", out, "-width", "30", "-samples", "2", "-threads", "2", "-stats", path}, &log); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 30 || b.Dy() != 15 {
+		t.Errorf("wanted a 30x15 image keeping the camera's aspect, got %dx%d", b.Dx(), b.Dy())
+	}
+	for _, want := range []string{"loaded", "rendering 100%", "rendered 30x15 with 2 samples per pixel on 2 threads", "wrote " + out, "rays: 900 primary"} {
+		if !strings.Contains(log.String(), want) {
+			t.Errorf("wanted the log to contain %q, got %q", want, log.String())
+		}
+	}
+}
+
+func TestRunWritesPPM(t *testing.T) {
+	path := writeScene(t, testScene)
+	out := filepath.Join(filepath.Dir(path), "out.ppm")
+	var log bytes.Buffer
+	if err := run([]string{"-quiet", "-o", out, path}, &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Len() != 0 {
+		t.Errorf("wanted nothing logged with -quiet, got %q", log.String())
+	}
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "P3\n40 20\n") {
+		t.Errorf("wanted a 40x20 PPM, got %q", data[:10])
+	}
+}
+
+func TestRunWithWorkers(t *testing.T) {
+	path := writeScene(t, testScene)
+	dir := filepath.Dir(path)
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		go distributed.Serve(l, &distributed.Worker{Threads: 1})
+		addrs = append(addrs, l.Addr().String())
+	}
+	var log bytes.Buffer
+	args := []string{"-o", filepath.Join(dir, "remote.ppm"), "-samples", "2", "-workers", strings.Join(addrs, ","), path}
+	if err := run(args, &log); err != nil {
+		t.Fatal(err)
+	}
+	if want := "rendered 40x20 with 2 samples per pixel on 2 workers"; !strings.Contains(log.String(), want) {
+		t.Errorf("wanted the log to contain %q, got %q", want, log.String())
+	}
+	if err := run([]string{"-quiet", "-o", filepath.Join(dir, "local.ppm"), "-samples", "2", path}, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	remote, err := ioutil.ReadFile(filepath.Join(dir, "remote.ppm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := ioutil.ReadFile(filepath.Join(dir, "local.ppm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(remote, local) {
+		t.Errorf("wanted the image rendered by workers to match the one rendered locally")
+	}
+}
+
+func TestRunWithShader(t *testing.T) {
+	path := writeScene(t, testScene)
+	out := filepath.Join(filepath.Dir(path), "ids.png")
+	if err := run([]string{"-quiet", "-o", out, "-shader", "ids", path}, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	colors := map[[4]uint32]bool{}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			colors[[4]uint32{r, g, b, a}] = true
+		}
+	}
+	if len(colors) != 2 {
+		t.Errorf("wanted the sphere in one flat color on an empty background, got %d colors", len(colors))
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	bad := writeScene(t, testScene+"  material:\n    shininess: [1]\n")
+	good := writeScene(t, testScene)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{bad}, "line 16: shininess: expected a number, got a list"},
+		{[]string{"missing.yml"}, "missing.yml"},
+		{[]string{"-o", "out.jpg", good}, "cannot tell the format of out.jpg"},
+		{[]string{"-format", "gif", good}, `unknown format "gif"`},
+		{[]string{"-samples", "0", good}, "-samples and -threads must be at least 1"},
+		{[]string{"-watch", "-workers", "localhost:7070", good}, "-workers cannot be used with -watch, -stats or -shader"},
+		{[]string{"-shader", "wireframe", good}, `unknown shader "wireframe", expected one of cost, depth`},
+		{[]string{}, "usage"},
+	}
+	for _, test := range tests {
+		err := run(test.args, ioutil.Discard)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("wanted an error containing %q for %v, got %v", test.want, test.args, err)
+		}
+	}
+}
+
+func TestRunRenderError(t *testing.T) {
+	path := writeScene(t, testScene+"  material:\n    pattern:\n      type: stripes\n      colors: [[1, 0, 0], [0, 0, 1]]\n      transform:\n        - [scale, 0, 0, 0]\n")
+	err := run([]string{"-o", filepath.Join(filepath.Dir(path), "out.png"), path}, ioutil.Discard)
+	if err == nil || !strings.HasPrefix(err.Error(), path+": render failed: ") || strings.Contains(err.Error(), "\n") {
+		t.Errorf("wanted a one line error naming %s, got %v", path, err)
+	}
+}
+
+//syncBuffer is a buffer that can be written by a watch while it is read
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+//waitFor polls until ok returns true, failing the test after a while
+func waitFor(t *testing.T, what string, ok func() bool) {
+	for deadline := time.Now().Add(10 * time.Second); !ok(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := writeScene(t, testScene)
+	dir := filepath.Dir(path)
+	include := filepath.Join(dir, "material.yml")
+	if err := ioutil.WriteFile(include, []byte("- define: shiny\n  value:\n    shininess: 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("- include: material.yml\n"+testScene+"  material: shiny\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "preview.png")
+	o, err := parseArgs([]string{"-watch", "-poll", "5ms", "-preview", "0.5", "-o", out, path}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log, stderr syncBuffer
+	stop := make(chan struct{})
+	finished := make(chan error)
+	go func() { finished <- watch(o, &log, &stderr, stop) }()
+	exists := func() bool {
+		_, err := os.Stat(out)
+		return err == nil
+	}
+	waitFor(t, "the first preview", exists)
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 20 || b.Dy() != 10 {
+		t.Errorf("wanted a 20x10 preview, got %dx%d", b.Dx(), b.Dy())
+	}
+
+	os.Remove(out)
+	if err := ioutil.WriteFile(include, []byte("- define: shiny\n  value:\n    shininess: 100\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "a preview after an included file changed", exists)
+	if !strings.Contains(log.String(), include+" changed") {
+		t.Errorf("wanted the change to %s logged, got %q", include, log.String())
+	}
+
+	if err := ioutil.WriteFile(include, []byte("- define: shiny\n  value:\n    shininess: [1]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the scene error", func() bool { return strings.Contains(stderr.String(), "shininess: expected a number") })
+
+	os.Remove(out)
+	if err := ioutil.WriteFile(include, []byte("- define: shiny\n  value:\n    shininess: 50\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "a preview after the error was fixed", exists)
+	close(stop)
+	if err := <-finished; err != nil {
+		t.Errorf("wanted watch to stop cleanly, got %v", err)
+	}
+}
+
+func TestWatchMissingInclude(t *testing.T) {
+	path := writeScene(t, "- include: material.yml\n"+testScene+"  material: shiny\n")
+	include := filepath.Join(filepath.Dir(path), "material.yml")
+	out := filepath.Join(filepath.Dir(path), "preview.png")
+	o, err := parseArgs([]string{"-watch", "-poll", "5ms", "-preview", "0.5", "-o", out, path}, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log, stderr syncBuffer
+	stop := make(chan struct{})
+	finished := make(chan error)
+	go func() { finished <- watch(o, &log, &stderr, stop) }()
+	waitFor(t, "the missing include reported", func() bool { return strings.Contains(stderr.String(), "material.yml") })
+
+	if err := ioutil.WriteFile(include, []byte("- define: shiny\n  value:\n    shininess: 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "a preview after the include was created", func() bool {
+		_, err := os.Stat(out)
+		return err == nil
+	})
+	if !strings.Contains(log.String(), include+" changed") {
+		t.Errorf("wanted the new %s logged, got %q", include, log.String())
+	}
+	close(stop)
+	if err := <-finished; err != nil {
+		t.Errorf("wanted watch to stop cleanly, got %v", err)
+	}
+}
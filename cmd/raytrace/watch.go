@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+//fileState is what is compared to tell whether a file has changed
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func stat(files []string) map[string]fileState {
+	states := map[string]fileState{}
+	for _, path := range files {
+		states[path] = statFile(path)
+	}
+	return states
+}
+
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{true, info.Size(), info.ModTime()}
+}
+
+//changed returns the path of a file that has changed since it was stat'd,
+//or ""
+func changed(states map[string]fileState) string {
+	for path, s := range states {
+		if statFile(path) != s {
+			return path
+		}
+	}
+	return ""
+}
+
+//watch renders a preview of the scene now and whenever the scene file or a
+//file it uses changes, until stop is closed. A render still running when a
+//change is seen is canceled. Problems loading the scene are reported and
+//the files read before the problem, and any that could not be opened, are
+//watched for a fix.
+func watch(o *options, log, stderr io.Writer, stop <-chan struct{}) error {
+	var states map[string]fileState
+	var cancel, done chan struct{}
+	finish := func() {
+		if cancel != nil {
+			close(cancel)
+			<-done
+			cancel = nil
+		}
+	}
+	defer finish()
+	ticker := time.NewTicker(o.poll)
+	defer ticker.Stop()
+	for reload := true; ; {
+		if reload {
+			finish()
+			s, files, err := load(o, log)
+			if err != nil {
+				fmt.Fprintln(stderr, "raytrace:", err)
+			} else {
+				cancel, done = make(chan struct{}), make(chan struct{})
+				go func(cancel, done chan struct{}) {
+					defer close(done)
+					if err := render(s, o, true, cancel, log); err != nil && err != errCanceled {
+						fmt.Fprintln(stderr, "raytrace:", err)
+					}
+				}(cancel, done)
+			}
+			states = stat(files)
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			path := changed(states)
+			if reload = path != ""; reload {
+				finish()
+				fmt.Fprintf(log, "%s changed\n", path)
+			}
+		}
+	}
+}
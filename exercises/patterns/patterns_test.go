@@ -1,13 +1,12 @@
 package patterns
 
 import (
-	"fmt"
 	"math"
-	"os"
 	"testing"
 
 	"github.com/calbim/ray-tracer/src/camera"
 	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/golden"
 	"github.com/calbim/ray-tracer/src/light"
 	"github.com/calbim/ray-tracer/src/material"
 	"github.com/calbim/ray-tracer/src/pattern"
@@ -41,10 +40,5 @@ func TestPatternScene(t *testing.T) {
 	camera := camera.New(1000, 500, math.Pi/3)
 	camera.Transform = transforms.ViewTransform(tuple.Point(0, 5, -5), tuple.Point(10, 0, 10), tuple.Vector(0, 1, 0))
 	can := camera.Render(w)
-	ppm := can.ToPPM()
-	file, err := os.Create("pattern.ppm")
-	if err != nil {
-		fmt.Println(err)
-	}
-	fmt.Fprintf(file, ppm)
+	golden.Assert(t, can, "pattern.ppm", 2.0/255)
 }
@@ -1,13 +1,12 @@
 package patterns
 
 import (
-	"fmt"
 	"math"
-	"os"
 	"testing"
 
 	"github.com/calbim/ray-tracer/src/camera"
 	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/golden"
 	"github.com/calbim/ray-tracer/src/light"
 	"github.com/calbim/ray-tracer/src/material"
 	"github.com/calbim/ray-tracer/src/pattern"
@@ -32,10 +31,5 @@ func TestRadialGradient(t *testing.T) {
 	camera := camera.New(600, 300, math.Pi/2)
 	camera.Transform = transforms.ViewTransform(tuple.Point(10, 3, 10), tuple.Point(10.5, 0, 10), tuple.Vector(0, 1, 0))
 	can := camera.Render(w)
-	ppm := can.ToPPM()
-	file, err := os.Create("radialgradient.ppm")
-	if err != nil {
-		fmt.Println(err)
-	}
-	fmt.Fprintf(file, ppm)
+	golden.Assert(t, can, "radialgradient.ppm", 2.0/255)
 }
@@ -1,9 +1,7 @@
 package scene
 
 import (
-	"fmt"
 	"math"
-	"os"
 	"testing"
 
 	"github.com/calbim/ray-tracer/src/pattern"
@@ -14,6 +12,7 @@ import (
 	"github.com/calbim/ray-tracer/src/tuple"
 
 	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/golden"
 	"github.com/calbim/ray-tracer/src/material"
 	"github.com/calbim/ray-tracer/src/shape"
 	"github.com/calbim/ray-tracer/src/transforms"
@@ -73,10 +72,5 @@ func TestScene(t *testing.T) {
 	camera := camera.New(300, 150, math.Pi/3)
 	camera.Transform = transforms.ViewTransform(tuple.Point(0, 1.5, -5), tuple.Point(0, 1, 0), tuple.Vector(0, 1, 0))
 	can := camera.Render(w)
-	ppm := can.ToPPM()
-	file, err := os.Create("scene-shadow.ppm")
-	if err != nil {
-		fmt.Println(err)
-	}
-	fmt.Fprintf(file, ppm)
+	golden.Assert(t, can, "scene-shadow.ppm", 2.0/255)
 }
@@ -1,15 +1,13 @@
 package silhouette
 
 import (
-	"fmt"
-	"os"
 	"testing"
 
 	"github.com/calbim/ray-tracer/src/canvas"
 	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/golden"
 	"github.com/calbim/ray-tracer/src/light"
 	"github.com/calbim/ray-tracer/src/material"
-	"github.com/calbim/ray-tracer/src/pattern"
 	"github.com/calbim/ray-tracer/src/ray"
 	"github.com/calbim/ray-tracer/src/shape"
 	"github.com/calbim/ray-tracer/src/tuple"
@@ -40,21 +38,10 @@ func TestSilhouette(t *testing.T) {
 				p := r.Position(hit.Value)
 				normalv := shape.NormalAt(hit.Object, p)
 				eyev := r.Direction.Negate()
-				color := sphere.Material.Lighting(getPatternObject(sphere), light, p, eyev, *normalv, false)
+				color := sphere.Material.Lighting(sphere, light, p, eyev, *normalv, false)
 				c.WritePixel(x, y, color)
 			}
 		}
 	}
-	ppm := c.ToPPM()
-	file, err := os.Create("silhouette.ppm")
-	if err != nil {
-		fmt.Println(err)
-	}
-	fmt.Fprintf(file, ppm)
-}
-
-func getPatternObject(s shape.Shape) pattern.Object {
-	o := pattern.NewObject()
-	o.Transform = s.GetTransform()
-	return o
+	golden.Assert(t, &c, "silhouette.ppm", 2.0/255)
 }
@@ -0,0 +1,364 @@
+package animation
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/calbim/ray-tracer/src/camera"
+	"github.com/calbim/ray-tracer/src/canvas"
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/matrix"
+	"github.com/calbim/ray-tracer/src/shape"
+	"github.com/calbim/ray-tracer/src/transforms"
+	"github.com/calbim/ray-tracer/src/tuple"
+	"github.com/calbim/ray-tracer/src/world"
+)
+
+//Easing maps the time between two keys, from 0 at the first to 1 at the
+//second, to how far the value has moved from the first key's value to the
+//second's
+type Easing func(t float64) float64
+
+//Linear moves the value at a constant rate
+func Linear(t float64) float64 {
+	return t
+}
+
+//Ease starts and ends slowly, following the smoothstep curve
+func Ease(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+//Bezier returns the easing given by a cubic Bezier curve from (0,0) to
+//(1,1) with control points (x1,y1) and (x2,y2), like CSS's cubic-bezier.
+//x1 and x2 are clamped to [0,1] so that there is one value at each time.
+func Bezier(x1, y1, x2, y2 float64) Easing {
+	x1 = clamp(x1)
+	x2 = clamp(x2)
+	return func(t float64) float64 {
+		// x grows with s, so find the s giving x = t by bisection
+		lo, hi := 0.0, 1.0
+		for i := 0; i < 50; i++ {
+			s := (lo + hi) / 2
+			if bezier(x1, x2, s) < t {
+				lo = s
+			} else {
+				hi = s
+			}
+		}
+		return bezier(y1, y2, (lo+hi)/2)
+	}
+}
+
+//bezier returns one coordinate of a cubic Bezier curve from 0 to 1
+func bezier(p1, p2, s float64) float64 {
+	r := 1 - s
+	return 3*r*r*s*p1 + 3*r*s*s*p2 + s*s*s
+}
+
+func clamp(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+//Key is the value of a track at a time, in seconds
+type Key struct {
+	Time   float64
+	Value  []float64
+	Easing Easing //how the value moves on to the next key, Linear when nil
+}
+
+//NewKey returns a key with linear easing
+func NewKey(time float64, value ...float64) Key {
+	return Key{Time: time, Value: value}
+}
+
+//Track is a value that changes over time, given by keys in order of time
+//that all have the same number of values
+type Track []Key
+
+//At returns the value of a track at a time. Before the first key it is the
+//first key's value and after the last key it is the last key's. An empty
+//track has no value.
+func (t Track) At(time float64) []float64 {
+	if len(t) == 0 {
+		return nil
+	}
+	if time <= t[0].Time {
+		return t[0].Value
+	}
+	for i := 1; i < len(t); i++ {
+		if time >= t[i].Time {
+			continue
+		}
+		a, b := t[i-1], t[i]
+		easing := a.Easing
+		if easing == nil {
+			easing = Linear
+		}
+		e := easing((time - a.Time) / (b.Time - a.Time))
+		v := make([]float64, len(a.Value))
+		for j := range v {
+			v[j] = a.Value[j] + (b.Value[j]-a.Value[j])*e
+		}
+		return v
+	}
+	return t[len(t)-1].Value
+}
+
+//End returns the time of the last key, or 0 for an empty track
+func (t Track) End() float64 {
+	if len(t) == 0 {
+		return 0
+	}
+	return t[len(t)-1].Time
+}
+
+//check panics if the keys of a track do not have n values each
+func (t Track) check(name string, n int) {
+	for _, k := range t {
+		if len(k.Value) != n {
+			panic(fmt.Sprintf("animation: %s keys need %d values, got %d at time %v", name, n, len(k.Value), k.Time))
+		}
+	}
+}
+
+//Transform is a transformation keyed by its parts. At any time it scales,
+//then rotates about x, y and z in turn, then translates, then applies Base.
+type Transform struct {
+	Translate Track          //x, y and z, no translation when empty
+	Rotate    Track          //radians about x, y and z, no rotation when empty
+	Scale     Track          //x, y and z, no scaling when empty
+	Base      *matrix.Matrix //applied after the keyed parts, if set
+}
+
+//At returns the transformation at a time
+func (t Transform) At(time float64) *matrix.Matrix {
+	var list []*matrix.Matrix
+	if s := t.Scale.At(time); s != nil {
+		list = append(list, transforms.Scaling(s[0], s[1], s[2]))
+	}
+	if r := t.Rotate.At(time); r != nil {
+		list = append(list, transforms.RotationX(r[0]), transforms.RotationY(r[1]), transforms.RotationZ(r[2]))
+	}
+	if p := t.Translate.At(time); p != nil {
+		list = append(list, transforms.Translation(p[0], p[1], p[2]))
+	}
+	if t.Base != nil {
+		list = append(list, t.Base)
+	}
+	if len(list) == 0 {
+		return matrix.Identity
+	}
+	return transforms.Chain(list...)
+}
+
+//End returns the time of the last key of any part
+func (t Transform) End() float64 {
+	return math.Max(t.Translate.End(), math.Max(t.Rotate.End(), t.Scale.End()))
+}
+
+func (t Transform) check() {
+	t.Translate.check("translate", 3)
+	t.Rotate.check("rotate", 3)
+	t.Scale.check("scale", 3)
+}
+
+//Channel drives a property of a scene over time
+type Channel interface {
+	//Apply sets the property to its value at a time
+	Apply(time float64)
+	//End returns the time of the channel's last key
+	End() float64
+}
+
+type floatChannel struct {
+	target *float64
+	track  Track
+}
+
+//Float returns a channel setting a number, such as a material's diffuse or
+//reflective property, from a track of single values
+func Float(target *float64, track Track) Channel {
+	track.check("float", 1)
+	return &floatChannel{target, track}
+}
+
+func (c *floatChannel) Apply(time float64) {
+	if v := c.track.At(time); v != nil {
+		*c.target = v[0]
+	}
+}
+
+func (c *floatChannel) End() float64 {
+	return c.track.End()
+}
+
+type colorChannel struct {
+	target *color.Color
+	track  Track
+}
+
+//Color returns a channel setting a color, such as a material's or light's,
+//from a track of red, green and blue values
+func Color(target *color.Color, track Track) Channel {
+	track.check("color", 3)
+	return &colorChannel{target, track}
+}
+
+func (c *colorChannel) Apply(time float64) {
+	if v := c.track.At(time); v != nil {
+		*c.target = color.New(v[0], v[1], v[2])
+	}
+}
+
+func (c *colorChannel) End() float64 {
+	return c.track.End()
+}
+
+type positionChannel struct {
+	target *tuple.Tuple
+	track  Track
+}
+
+//Position returns a channel setting the x, y and z of a tuple, such as a
+//light's position, from a track of three values
+func Position(target *tuple.Tuple, track Track) Channel {
+	track.check("position", 3)
+	return &positionChannel{target, track}
+}
+
+func (c *positionChannel) Apply(time float64) {
+	if v := c.track.At(time); v != nil {
+		c.target.X, c.target.Y, c.target.Z = v[0], v[1], v[2]
+	}
+}
+
+func (c *positionChannel) End() float64 {
+	return c.track.End()
+}
+
+type transformChannel struct {
+	set       func(*matrix.Matrix)
+	transform Transform
+}
+
+//ShapeTransform returns a channel setting the transformation of a shape
+func ShapeTransform(s shape.Shape, t Transform) Channel {
+	t.check()
+	return &transformChannel{s.SetTransform, t}
+}
+
+//CameraTransform returns a channel setting the transformation of a camera.
+//With a view transformation as Base, keyed rotations turn the scene in
+//front of the camera.
+func CameraTransform(c *camera.Camera, t Transform) Channel {
+	t.check()
+	return &transformChannel{func(m *matrix.Matrix) { c.Transform = m }, t}
+}
+
+func (c *transformChannel) Apply(time float64) {
+	c.set(c.transform.At(time))
+}
+
+func (c *transformChannel) End() float64 {
+	return c.transform.End()
+}
+
+//Animation is a set of channels moving a scene together
+type Animation struct {
+	Channels []Channel
+}
+
+//Add adds channels to an animation
+func (a *Animation) Add(channels ...Channel) {
+	a.Channels = append(a.Channels, channels...)
+}
+
+//Apply sets every property of the animation to its value at a time
+func (a *Animation) Apply(time float64) {
+	for _, c := range a.Channels {
+		c.Apply(time)
+	}
+}
+
+//Duration returns the time of the last key of any channel
+func (a *Animation) Duration() float64 {
+	end := 0.0
+	for _, c := range a.Channels {
+		end = math.Max(end, c.End())
+	}
+	return end
+}
+
+//Sequence describes the frames of an animation to render
+type Sequence struct {
+	First, Last int     //frame numbers, inclusive
+	FPS         float64 //frames per second, so frame n shows time n/FPS
+	//Path is where frames are written, with a verb such as %04d for the
+	//frame number. Frames are PPM files if it ends in .ppm and PNG
+	//otherwise.
+	Path string
+	//Progress, if set, is called after each frame is written
+	Progress func(frame int, path string)
+}
+
+//Frames returns the number of frames needed to show an animation from
+//time 0 to its last key at fps frames per second
+func (a *Animation) Frames(fps float64) int {
+	return int(a.Duration()*fps+0.5) + 1
+}
+
+//Render renders a sequence of frames with a camera, applying the
+//animation to the scene before each one. The channels should drive the
+//camera and world given, which are left as they are at the last frame.
+func (a *Animation) Render(c *camera.Camera, w *world.World, s Sequence, o camera.Options) error {
+	if s.FPS <= 0 {
+		return errors.New("animation: frames per second must be positive")
+	}
+	if s.Last < s.First {
+		return fmt.Errorf("animation: last frame %d is before first frame %d", s.Last, s.First)
+	}
+	if !strings.Contains(s.Path, "%") {
+		return fmt.Errorf("animation: path %q has no verb for the frame number", s.Path)
+	}
+	for frame := s.First; frame <= s.Last; frame++ {
+		a.Apply(float64(frame) / s.FPS)
+		image := c.RenderWith(*w, o)
+		path := fmt.Sprintf(s.Path, frame)
+		if err := write(image, path); err != nil {
+			return err
+		}
+		if s.Progress != nil {
+			s.Progress(frame, path)
+		}
+	}
+	return nil
+}
+
+func write(image *canvas.Canvas, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".ppm" {
+		_, err = io.WriteString(f, image.ToPPM())
+	} else {
+		err = image.ToPNG(f)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,187 @@
+package animation
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/calbim/ray-tracer/src/camera"
+	"github.com/calbim/ray-tracer/src/canvas"
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/transforms"
+	"github.com/calbim/ray-tracer/src/tuple"
+	"github.com/calbim/ray-tracer/src/util"
+	"github.com/calbim/ray-tracer/src/world"
+)
+
+func TestEasing(t *testing.T) {
+	tests := []struct {
+		name   string
+		easing Easing
+		t      float64
+		want   float64
+	}{
+		{"linear", Linear, 0.25, 0.25},
+		{"ease", Ease, 0, 0},
+		{"ease", Ease, 0.25, 0.15625},
+		{"ease", Ease, 0.5, 0.5},
+		{"ease", Ease, 1, 1},
+		{"linear bezier", Bezier(0, 0, 1, 1), 0.3, 0.3},
+		{"bezier", Bezier(0.42, 0, 1, 1), 0, 0},
+		{"bezier", Bezier(0.42, 0, 1, 1), 1, 1},
+		{"bezier", Bezier(0.42, 0, 1, 1), 0.5, 0.3153},
+		{"bezier overshoot", Bezier(0.5, 1.5, 0.5, 1.5), 0.5, 1.25},
+	}
+	for _, test := range tests {
+		if got := test.easing(test.t); !util.Equals(got, test.want) {
+			t.Errorf("wanted %s easing at %v=%v, got %v", test.name, test.t, test.want, got)
+		}
+	}
+}
+
+func TestTrackAt(t *testing.T) {
+	eased := NewKey(2, 10, 20)
+	eased.Easing = Ease
+	track := Track{NewKey(1, 0, 0), eased, NewKey(4, 20, 20)}
+	tests := []struct {
+		time float64
+		want []float64
+	}{
+		{0, []float64{0, 0}},
+		{1, []float64{0, 0}},
+		{1.5, []float64{5, 10}},
+		{2, []float64{10, 20}},
+		{2.5, []float64{11.5625, 20}},
+		{3, []float64{15, 20}},
+		{5, []float64{20, 20}},
+	}
+	for _, test := range tests {
+		got := track.At(test.time)
+		if !util.Equals(got[0], test.want[0]) || !util.Equals(got[1], test.want[1]) {
+			t.Errorf("wanted value at %v=%v, got %v", test.time, test.want, got)
+		}
+	}
+	if track.End() != 4 {
+		t.Errorf("wanted end=%v, got %v", 4, track.End())
+	}
+	if v := (Track{}).At(1); v != nil {
+		t.Errorf("wanted no value for an empty track, got %v", v)
+	}
+}
+
+func TestTransformAt(t *testing.T) {
+	tr := Transform{
+		Translate: Track{NewKey(0, 0, 0, 0), NewKey(2, 4, 0, 0)},
+		Rotate:    Track{NewKey(0, 0, 0, 0), NewKey(1, 0, math.Pi, 0)},
+		Scale:     Track{NewKey(0, 2, 2, 2)},
+	}
+	want := transforms.Chain(transforms.Scaling(2, 2, 2), transforms.RotationY(math.Pi/2), transforms.Translation(1, 0, 0))
+	if got := tr.At(0.5); !got.Equals(want) {
+		t.Errorf("wanted transform at 0.5=%v, got %v", want, got)
+	}
+	if tr.End() != 2 {
+		t.Errorf("wanted end=%v, got %v", 2, tr.End())
+	}
+	if got := (Transform{}).At(3); !got.Equals(transforms.Translation(0, 0, 0)) {
+		t.Errorf("wanted an empty transform to be the identity, got %v", got)
+	}
+}
+
+func TestChannels(t *testing.T) {
+	w := world.Default()
+	c := camera.New(10, 10, math.Pi/2)
+	view := transforms.ViewTransform(tuple.Point(0, 0, -5), tuple.Point(0, 0, 0), tuple.Vector(0, 1, 0))
+	m := w.Objects[0].GetMaterial()
+	var a Animation
+	a.Add(
+		Float(&m.Diffuse, Track{NewKey(0, 0), NewKey(2, 1)}),
+		Color(&w.Light.Intensity, Track{NewKey(0, 1, 1, 1), NewKey(2, 0, 0, 0)}),
+		Position(&w.Light.Position, Track{NewKey(0, 0, 10, 0), NewKey(4, 0, 10, 8)}),
+		ShapeTransform(w.Objects[1], Transform{Translate: Track{NewKey(0, 0, 0, 0), NewKey(1, 0, 2, 0)}}),
+		CameraTransform(&c, Transform{Rotate: Track{NewKey(0, 0, 0, 0), NewKey(3, 0, 2*math.Pi, 0)}, Base: view}),
+	)
+	if a.Duration() != 4 {
+		t.Errorf("wanted duration=%v, got %v", 4, a.Duration())
+	}
+	a.Apply(1)
+	if m.Diffuse != 0.5 {
+		t.Errorf("wanted diffuse=%v, got %v", 0.5, m.Diffuse)
+	}
+	if i := w.Light.Intensity; !i.Equals(color.New(0.5, 0.5, 0.5)) {
+		t.Errorf("wanted light intensity=%v, got %v", color.New(0.5, 0.5, 0.5), i)
+	}
+	if p := w.Light.Position; !p.Equals(tuple.Point(0, 10, 2)) {
+		t.Errorf("wanted light position=%v, got %v", tuple.Point(0, 10, 2), p)
+	}
+	if tr := w.Objects[1].GetTransform(); !tr.Equals(transforms.Translation(0, 2, 0)) {
+		t.Errorf("wanted shape transform=%v, got %v", transforms.Translation(0, 2, 0), tr)
+	}
+	want := transforms.Chain(transforms.RotationY(2*math.Pi/3), view)
+	if !c.Transform.Equals(want) {
+		t.Errorf("wanted camera transform=%v, got %v", want, c.Transform)
+	}
+}
+
+func TestChannelPanicsOnWrongValueCount(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("wanted a panic for a color key with one value")
+		}
+	}()
+	var c color.Color
+	Color(&c, Track{NewKey(0, 1)})
+}
+
+func TestRender(t *testing.T) {
+	w := world.Default()
+	w.Objects = w.Objects[1:]
+	c := camera.New(9, 9, math.Pi/2)
+	c.Transform = transforms.ViewTransform(tuple.Point(0, 0, -5), tuple.Point(0, 0, 0), tuple.Vector(0, 1, 0))
+	var a Animation
+	a.Add(ShapeTransform(w.Objects[0], Transform{
+		Translate: Track{NewKey(0, 0, 0, 0), NewKey(1, 3, 0, 0)},
+		Scale:     Track{NewKey(0, 0.5, 0.5, 0.5)},
+	}))
+	if n := a.Frames(4); n != 5 {
+		t.Errorf("wanted 5 frames at 4 fps, got %v", n)
+	}
+	dir := t.TempDir()
+	var written []int
+	s := Sequence{First: 0, Last: 4, FPS: 4, Path: filepath.Join(dir, "frame%03d.ppm"), Progress: func(frame int, path string) {
+		written = append(written, frame)
+	}}
+	if err := a.Render(&c, &w, s, camera.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 5 {
+		t.Errorf("wanted progress for 5 frames, got %v", written)
+	}
+	var frames []*canvas.Canvas
+	for i := 0; i < 5; i++ {
+		f, err := os.Open(filepath.Join(dir, fmt.Sprintf("frame%03d.ppm", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		image, err := canvas.ReadPPM(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, image)
+	}
+	if frames[0].Pixels[4][4].Equals(color.Black) || !frames[4].Pixels[4][4].Equals(color.Black) {
+		t.Errorf("wanted the sphere to move out of the middle, got %v then %v", frames[0].Pixels[4][4], frames[4].Pixels[4][4])
+	}
+	bad := []Sequence{
+		{First: 0, Last: 1, FPS: 0, Path: s.Path},
+		{First: 2, Last: 1, FPS: 24, Path: s.Path},
+		{First: 0, Last: 1, FPS: 24, Path: filepath.Join(dir, "frame.png")},
+	}
+	for _, b := range bad {
+		if err := a.Render(&c, &w, b, camera.Options{}); err == nil {
+			t.Errorf("wanted an error for %+v", b)
+		}
+	}
+}
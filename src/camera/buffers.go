@@ -0,0 +1,131 @@
+package camera
+
+import (
+	"math"
+
+	"github.com/calbim/ray-tracer/src/canvas"
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/material"
+	"github.com/calbim/ray-tracer/src/shape"
+	"github.com/calbim/ray-tracer/src/world"
+)
+
+//Buffers holds the output variables rendered by RenderBuffers. Pixels
+//where no object was hit show the background in Color, are black in every
+//other canvas and 0 in the ID buffers.
+type Buffers struct {
+	Color      *canvas.Canvas
+	Depth      *canvas.Canvas //camera space depth, repeated in every channel, see DepthCanvas for export
+	Normal     *canvas.Canvas //world space normal, x, y and z from -1 to 1 as r, g and b from 0 to 1
+	Albedo     *canvas.Canvas //unlit surface color
+	Alpha      *canvas.Canvas //coverage, white where an object was hit
+	ObjectID   [][]int        //index of the hit object in World.Objects, plus one
+	MaterialID [][]int        //index of the hit object's material in Materials, plus one
+	Materials  []*material.Material
+}
+
+//RenderBuffers renders the world with a camera, producing the color image
+//along with the depth, normal, albedo, ID and coverage buffers in one pass
+func (c Camera) RenderBuffers(w world.World) *Buffers {
+	width, height := int(c.HSize), int(c.VSize)
+	b := &Buffers{
+		Color:      newCanvas(width, height),
+		Depth:      newCanvas(width, height),
+		Normal:     newCanvas(width, height),
+		Albedo:     newCanvas(width, height),
+		Alpha:      newCanvas(width, height),
+		ObjectID:   newIDs(width, height),
+		MaterialID: newIDs(width, height),
+	}
+	objectIDs := map[shape.Shape]int{}
+	for i, o := range w.Objects {
+		objectIDs[o] = i + 1
+	}
+	materialIDs := map[*material.Material]int{}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r := c.RayForPixel(x, y)
+			col, comps := w.Trace(*r)
+			b.Color.WritePixel(x, y, col)
+			if comps == nil {
+				b.Color.WriteAlpha(x, y, 0)
+				continue
+			}
+			p := c.Transform.MultiplyTuple(comps.Point)
+			b.Depth.WritePixel(x, y, color.New(-p.Z, -p.Z, -p.Z))
+			n := comps.Normal
+			b.Normal.WritePixel(x, y, color.New(n.X*0.5+0.5, n.Y*0.5+0.5, n.Z*0.5+0.5))
+			b.Albedo.WritePixel(x, y, w.Albedo(*comps))
+			b.Alpha.WritePixel(x, y, color.White)
+			b.ObjectID[y][x] = objectIDs[comps.Object]
+			m := comps.Object.GetMaterial()
+			if _, ok := materialIDs[m]; !ok {
+				b.Materials = append(b.Materials, m)
+				materialIDs[m] = len(b.Materials)
+			}
+			b.MaterialID[y][x] = materialIDs[m]
+		}
+	}
+	return b
+}
+
+//DepthCanvas returns the depth buffer scaled for export, with depth near
+//white and depth far black. When far is not beyond near, the nearest and
+//farthest hits in the buffer are used. Pixels where no object was hit stay
+//black.
+func (b *Buffers) DepthCanvas(near, far float64) *canvas.Canvas {
+	depth := b.Depth
+	image := newCanvas(depth.Width(), depth.Height())
+	if far <= near {
+		near, far = math.Inf(1), math.Inf(-1)
+		for y := range depth.Pixels {
+			for x, d := range depth.Pixels[y] {
+				if b.Alpha.Pixels[y][x].R > 0 {
+					near, far = math.Min(near, d.R), math.Max(far, d.R)
+				}
+			}
+		}
+	}
+	for y := range depth.Pixels {
+		for x, d := range depth.Pixels[y] {
+			if b.Alpha.Pixels[y][x].R == 0 {
+				continue
+			}
+			v := 1.0
+			if far > near {
+				v = 1 - math.Max(0, math.Min(1, (d.R-near)/(far-near)))
+			}
+			image.WritePixel(x, y, color.New(v, v, v))
+		}
+	}
+	return image
+}
+
+//IDCanvas returns a false color image of an ID buffer for export
+func IDCanvas(ids [][]int) *canvas.Canvas {
+	height := len(ids)
+	width := 0
+	if height > 0 {
+		width = len(ids[0])
+	}
+	image := newCanvas(width, height)
+	for y := range ids {
+		for x, id := range ids[y] {
+			image.WritePixel(x, y, color.FromID(id))
+		}
+	}
+	return image
+}
+
+func newCanvas(w, h int) *canvas.Canvas {
+	c := canvas.New(w, h)
+	return &c
+}
+
+func newIDs(w, h int) [][]int {
+	ids := make([][]int, h)
+	for i := range ids {
+		ids[i] = make([]int, w)
+	}
+	return ids
+}
@@ -13,13 +13,13 @@ import (
 
 //Camera represents a camera
 type Camera struct {
-	HSize       float64 //in pixels
-	VSize       float64
-	FieldOfView float64
-	Transform   *matrix.Matrix
-	PixelSize   float64
-	HalfHeight  float64
-	HalfWidth   float64
+	HSize       float64        `json:"hsize"` //in pixels
+	VSize       float64        `json:"vsize"`
+	FieldOfView float64        `json:"field_of_view"`
+	Transform   *matrix.Matrix `json:"transform"`
+	PixelSize   float64        `json:"-"` //derived from the size and field of view by New
+	HalfHeight  float64        `json:"-"`
+	HalfWidth   float64        `json:"-"`
 }
 
 // New returns a new camera
@@ -44,33 +44,49 @@ func New(hSize float64, vSize float64, fieldOfView float64) Camera {
 	return c
 }
 
+//Resize returns a camera with the same view and field of view rendering
+//an image of another size. If width or height is 0, it is chosen to keep
+//the aspect ratio.
+func (c Camera) Resize(width, height float64) Camera {
+	switch {
+	case width == 0 && height == 0:
+		return c
+	case width == 0:
+		width = math.Round(height * c.HSize / c.VSize)
+	case height == 0:
+		height = math.Round(width * c.VSize / c.HSize)
+	}
+	resized := New(math.Max(1, width), math.Max(1, height), c.FieldOfView)
+	resized.Transform = c.Transform
+	return resized
+}
+
 //RayForPixel returns the ray from the camera to point (x,y) on canvas
 func (c *Camera) RayForPixel(x, y int) *ray.Ray {
-	xOffset := (float64(x) + 0.5) * c.PixelSize
-	yOffset := (float64(y) + 0.5) * c.PixelSize
-	worldX := c.HalfWidth - xOffset
-	worldY := c.HalfHeight - yOffset
 	inverse, err := c.Transform.Inverse()
 	if err != nil {
 		return nil
 	}
+	r := c.rayThrough(inverse, float64(x)+0.5, float64(y)+0.5)
+	return &r
+}
+
+//rayThrough returns the ray through a point on the canvas given in pixels,
+//so that (x+0.5, y+0.5) is the middle of pixel (x,y). inverse is the
+//inverse of the camera's transform.
+func (c *Camera) rayThrough(inverse *matrix.Matrix, px, py float64) ray.Ray {
+	worldX := c.HalfWidth - px*c.PixelSize
+	worldY := c.HalfHeight - py*c.PixelSize
 	pixel := inverse.MultiplyTuple(tuple.Point(worldX, worldY, -1))
 	origin := inverse.MultiplyTuple(tuple.Point(0, 0, 0))
 	direction := pixel.Subtract(origin)
 	direction = direction.Normalize()
-	r := ray.New(origin, direction)
-	return &r
+	return ray.New(origin, direction)
 }
 
-//Render renders the world with a camera
+//Render renders the world with a camera. Pixels where no object was hit
+//show the world's background and are fully transparent in the canvas' alpha
+//channel.
 func (c Camera) Render(w world.World) *canvas.Canvas {
-	image := canvas.New(int(c.HSize), int(c.VSize))
-	for y := 0; y < int(c.VSize); y++ {
-		for x := 0; x < int(c.HSize); x++ {
-			r := c.RayForPixel(x, y)
-			col := w.ColorAt(*r)
-			image.WritePixel(x, y, col)
-		}
-	}
-	return &image
+	return c.RenderWith(w, Options{})
 }
@@ -4,10 +4,12 @@ import (
 	"github.com/calbim/ray-tracer/src/color"
 	"github.com/calbim/ray-tracer/src/world"
 	"fmt"
+	"image"
 	"math"
 	"testing"
 
 	"github.com/calbim/ray-tracer/src/matrix"
+	"github.com/calbim/ray-tracer/src/pattern"
 	"github.com/calbim/ray-tracer/src/transforms"
 	"github.com/calbim/ray-tracer/src/tuple"
 )
@@ -96,3 +98,257 @@ func TestRender(t *testing.T) {
 		t.Errorf("wanted pixel at 5,5 to =%v, but it is %v", color.New(0.38066, 0.47583, 0.2855), image.Pixels[5][5])
 	}
 }
+
+func TestRenderBuffers(t *testing.T) {
+	w := world.Default()
+	c := New(11, 11, math.Pi/2)
+	c.Transform = transforms.ViewTransform(tuple.Point(0, 0, -5), tuple.Point(0, 0, 0), tuple.Vector(0, 1, 0))
+	b := c.RenderBuffers(w)
+	if !b.Color.Pixels[5][5].Equals(color.New(0.38066, 0.47583, 0.2855)) {
+		t.Errorf("wanted color at 5,5=%v, got %v", color.New(0.38066, 0.47583, 0.2855), b.Color.Pixels[5][5])
+	}
+	if !b.Depth.Pixels[5][5].Equals(color.New(4, 4, 4)) {
+		t.Errorf("wanted depth at 5,5=%v, got %v", color.New(4, 4, 4), b.Depth.Pixels[5][5])
+	}
+	if !b.Normal.Pixels[5][5].Equals(color.New(0.5, 0.5, 0)) {
+		t.Errorf("wanted normal (0, 0, -1) at 5,5 encoded as %v, got %v", color.New(0.5, 0.5, 0), b.Normal.Pixels[5][5])
+	}
+	if !b.Albedo.Pixels[5][5].Equals(color.New(0.8, 1.0, 0.6)) {
+		t.Errorf("wanted albedo at 5,5=%v, got %v", color.New(0.8, 1.0, 0.6), b.Albedo.Pixels[5][5])
+	}
+	if !b.Alpha.Pixels[5][5].Equals(color.White) || !b.Alpha.Pixels[0][0].Equals(color.Black) {
+		t.Errorf("wanted coverage only where the sphere was hit")
+	}
+	if b.ObjectID[5][5] != 1 || b.ObjectID[0][0] != 0 {
+		t.Errorf("wanted object IDs 1 and 0, got %v and %v", b.ObjectID[5][5], b.ObjectID[0][0])
+	}
+	if b.MaterialID[5][5] != 1 || b.Materials[0] != w.Objects[0].GetMaterial() {
+		t.Errorf("wanted material ID 1 to be the outer sphere's material")
+	}
+}
+
+func TestDepthCanvas(t *testing.T) {
+	w := world.Default()
+	c := New(11, 11, math.Pi/2)
+	c.Transform = transforms.ViewTransform(tuple.Point(0, 0, -5), tuple.Point(0, 0, 0), tuple.Vector(0, 1, 0))
+	b := c.RenderBuffers(w)
+	fixed := b.DepthCanvas(3, 5)
+	if !fixed.Pixels[5][5].Equals(color.New(0.5, 0.5, 0.5)) || !fixed.Pixels[0][0].Equals(color.Black) {
+		t.Errorf("wanted depth 4 between 3 and 5 gray and misses black, got %v and %v", fixed.Pixels[5][5], fixed.Pixels[0][0])
+	}
+	auto := b.DepthCanvas(0, 0)
+	if !auto.Pixels[5][5].Equals(color.White) {
+		t.Errorf("wanted the nearest hit white, got %v", auto.Pixels[5][5])
+	}
+	darkest := color.White
+	for y := range auto.Pixels {
+		for x, p := range auto.Pixels[y] {
+			if b.Alpha.Pixels[y][x].R > 0 && p.R < darkest.R {
+				darkest = p
+			}
+		}
+	}
+	if !darkest.Equals(color.Black) {
+		t.Errorf("wanted the farthest hit black, got %v", darkest)
+	}
+}
+
+func TestIDCanvas(t *testing.T) {
+	image := IDCanvas([][]int{{0, 1, 1, 2}})
+	if !image.Pixels[0][0].Equals(color.Black) {
+		t.Errorf("wanted background=%v, got %v", color.Black, image.Pixels[0][0])
+	}
+	if !image.Pixels[0][1].Equals(image.Pixels[0][2]) || image.Pixels[0][1].Equals(image.Pixels[0][3]) {
+		t.Errorf("wanted equal IDs to share a color and distinct IDs to differ")
+	}
+}
+
+func TestRenderMarksMissedPixelsTransparent(t *testing.T) {
+	w := world.Default()
+	w.Background = world.Solid{Color: color.New(0, 0, 1)}
+	c := New(11, 11, math.Pi/2)
+	c.Transform = transforms.ViewTransform(tuple.Point(0, 0, -5), tuple.Point(0, 0, 0), tuple.Vector(0, 1, 0))
+	image := c.Render(w)
+	if image.Alpha[5][5] != 1 {
+		t.Errorf("wanted alpha at 5,5=%v, got %v", 1, image.Alpha[5][5])
+	}
+	if image.Alpha[0][0] != 0 {
+		t.Errorf("wanted alpha at 0,0=%v, got %v", 0, image.Alpha[0][0])
+	}
+	if !image.Pixels[0][0].Equals(color.New(0, 0, 1)) {
+		t.Errorf("wanted background at 0,0=%v, got %v", color.New(0, 0, 1), image.Pixels[0][0])
+	}
+}
+
+func TestRenderWithThreadsMatchesOneThread(t *testing.T) {
+	w := world.Default()
+	c := New(21, 15, math.Pi/2)
+	c.Transform = transforms.ViewTransform(tuple.Point(0, 0, -5), tuple.Point(0, 0, 0), tuple.Vector(0, 1, 0))
+	want := c.RenderWith(w, Options{Threads: 1})
+	got := c.RenderWith(w, Options{Threads: 4})
+	for y := range want.Pixels {
+		for x := range want.Pixels[y] {
+			if got.Pixels[y][x] != want.Pixels[y][x] || got.Alpha[y][x] != want.Alpha[y][x] {
+				t.Fatalf("wanted pixel at %d,%d=%v, got %v", x, y, want.Pixels[y][x], got.Pixels[y][x])
+			}
+		}
+	}
+}
+
+func TestRenderWithSamples(t *testing.T) {
+	w := world.Default()
+	w.Background = world.Solid{Color: color.New(0, 0, 1)}
+	c := New(11, 11, math.Pi/2)
+	c.Transform = transforms.ViewTransform(tuple.Point(0, 0, -5), tuple.Point(0, 0, 0), tuple.Vector(0, 1, 0))
+	one := c.Render(w)
+	many := c.RenderWith(w, Options{Samples: 16})
+	if !many.Pixels[0][0].Equals(one.Pixels[0][0]) || many.Alpha[0][0] != 0 {
+		t.Errorf("wanted a missed pixel to stay background, got %v with alpha %v", many.Pixels[0][0], many.Alpha[0][0])
+	}
+	edges := 0
+	for y := range many.Alpha {
+		for x := range many.Alpha[y] {
+			if a := many.Alpha[y][x]; a > 0 && a < 1 {
+				edges++
+			}
+		}
+	}
+	if edges == 0 {
+		t.Errorf("wanted partly covered pixels along the edge of the sphere")
+	}
+}
+
+func TestRenderWithProgress(t *testing.T) {
+	c := New(5, 7, math.Pi/2)
+	var calls []int
+	c.RenderWith(world.Default(), Options{Threads: 3, Progress: func(done, total int) {
+		if total != 7 {
+			t.Errorf("wanted total=%v, got %v", 7, total)
+		}
+		calls = append(calls, done)
+	}})
+	for i, done := range calls {
+		if done != i+1 {
+			t.Errorf("wanted progress calls 1 to 7 in order, got %v", calls)
+			break
+		}
+	}
+	if len(calls) != 7 {
+		t.Errorf("wanted %v progress calls, got %v", 7, len(calls))
+	}
+}
+
+func TestRenderWithCancel(t *testing.T) {
+	c := New(11, 11, math.Pi/2)
+	c.Transform = transforms.ViewTransform(tuple.Point(0, 0, -5), tuple.Point(0, 0, 0), tuple.Vector(0, 1, 0))
+	cancel := make(chan struct{})
+	close(cancel)
+	image := c.RenderWith(world.Default(), Options{Cancel: cancel})
+	if !image.Pixels[5][5].Equals(color.Black) {
+		t.Errorf("wanted a canceled render to leave pixels black, got %v", image.Pixels[5][5])
+	}
+}
+
+func TestRenderWithPanic(t *testing.T) {
+	c := New(11, 11, math.Pi/2)
+	c.Transform = transforms.ViewTransform(tuple.Point(0, 0, -5), tuple.Point(0, 0, 0), tuple.Vector(0, 1, 0))
+	w := world.Default()
+	w.Objects[0].GetMaterial().SetPattern(&pattern.Perturbed{})
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("wanted the panic of a thread raised again")
+		}
+	}()
+	c.RenderWith(w, Options{Threads: 4})
+}
+
+func TestRenderTile(t *testing.T) {
+	w := world.Default()
+	c := New(21, 15, math.Pi/2)
+	c.Transform = transforms.ViewTransform(tuple.Point(0, 0, -5), tuple.Point(0, 0, 0), tuple.Vector(0, 1, 0))
+	want := c.RenderWith(w, Options{Samples: 4})
+	tiles := []image.Rectangle{image.Rect(3, 4, 13, 9), image.Rect(15, 10, 30, 30)}
+	for _, tile := range tiles {
+		got := c.RenderTile(w, tile, Options{Samples: 4})
+		tile = tile.Intersect(image.Rect(0, 0, 21, 15))
+		if got.Width() != tile.Dx() || got.Height() != tile.Dy() {
+			t.Fatalf("wanted a %vx%v canvas for tile %v, got %vx%v", tile.Dx(), tile.Dy(), tile, got.Width(), got.Height())
+		}
+		for y := 0; y < got.Height(); y++ {
+			for x := 0; x < got.Width(); x++ {
+				wx, wy := tile.Min.X+x, tile.Min.Y+y
+				if got.Pixels[y][x] != want.Pixels[wy][wx] || got.Alpha[y][x] != want.Alpha[wy][wx] {
+					t.Fatalf("wanted pixel %d,%d of tile %v=%v, got %v", x, y, tile, want.Pixels[wy][wx], got.Pixels[y][x])
+				}
+			}
+		}
+	}
+}
+
+func TestRenderWithStats(t *testing.T) {
+	w := world.Default()
+	c := New(11, 11, math.Pi/2)
+	c.Transform = transforms.ViewTransform(tuple.Point(0, 0, -5), tuple.Point(0, 0, 0), tuple.Vector(0, 1, 0))
+	one := w
+	one.Stats = &world.Stats{}
+	c.RenderWith(one, Options{Samples: 2, Threads: 1})
+	many := w
+	many.Stats = &world.Stats{}
+	c.RenderWith(many, Options{Samples: 2, Threads: 4})
+	if many.Stats.PrimaryRays != 242 {
+		t.Errorf("wanted a primary ray per sample, 242, got %v", many.Stats.PrimaryRays)
+	}
+	if many.Stats.ShadowRays != one.Stats.ShadowRays || many.Stats.Tests["sphere"] != one.Stats.Tests["sphere"] {
+		t.Errorf("wanted the same counts on 4 threads as on 1, got %+v and %+v", many.Stats, one.Stats)
+	}
+	if many.Stats.Rendering <= 0 {
+		t.Errorf("wanted the render time recorded, got %v", many.Stats.Rendering)
+	}
+}
+
+func TestPick(t *testing.T) {
+	w := world.Default()
+	c := New(11, 11, math.Pi/2)
+	c.Transform = transforms.ViewTransform(tuple.Point(0, 0, -5), tuple.Point(0, 0, 0), tuple.Vector(0, 1, 0))
+	p := c.Pick(w, 5, 5)
+	if p == nil {
+		t.Fatalf("wanted the sphere under the middle pixel, got nothing")
+	}
+	if p.Object != w.Objects[0] || p.Distance != 4 {
+		t.Errorf("wanted the outer sphere at distance 4, got %v at %v", p.Object, p.Distance)
+	}
+	if !p.Point.Equals(tuple.Point(0, 0, -1)) || !p.Normal.Equals(tuple.Vector(0, 0, -1)) {
+		t.Errorf("wanted point=%v and normal=%v, got %v and %v", tuple.Point(0, 0, -1), tuple.Vector(0, 0, -1), p.Point, p.Normal)
+	}
+	if p.Computation.Object != p.Object || !p.Computation.Eyev.Equals(tuple.Vector(0, 0, -1)) {
+		t.Errorf("wanted the full computations of the hit, got %+v", p.Computation)
+	}
+	for _, px := range [][2]int{{0, 0}, {-1, 5}, {5, 11}} {
+		if p := c.Pick(w, px[0], px[1]); p != nil {
+			t.Errorf("wanted nothing picked at %v, got %v", px, p.Object)
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	c := New(200, 100, math.Pi/2)
+	c.Transform = transforms.Translation(1, 2, 3)
+	tests := []struct {
+		width, height float64
+		wantW, wantH  float64
+	}{
+		{0, 0, 200, 100},
+		{50, 0, 50, 25},
+		{0, 30, 60, 30},
+		{10, 10, 10, 10},
+	}
+	for _, test := range tests {
+		r := c.Resize(test.width, test.height)
+		if r.HSize != test.wantW || r.VSize != test.wantH {
+			t.Errorf("wanted %vx%v resized to %vx%v, got %vx%v", test.width, test.height, test.wantW, test.wantH, r.HSize, r.VSize)
+		}
+		if r.Transform != c.Transform || r.FieldOfView != c.FieldOfView {
+			t.Errorf("wanted a resized camera to keep its view")
+		}
+	}
+}
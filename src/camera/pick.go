@@ -0,0 +1,40 @@
+package camera
+
+import (
+	"github.com/calbim/ray-tracer/src/shape"
+	"github.com/calbim/ray-tracer/src/tuple"
+	"github.com/calbim/ray-tracer/src/world"
+)
+
+//Pick describes the object seen through a pixel
+type Pick struct {
+	Object      shape.Shape
+	Point       tuple.Tuple //where the object is hit, in world space
+	Normal      tuple.Tuple //the surface normal there, facing the camera
+	Distance    float64     //from the camera to Point
+	Computation shape.Computation
+}
+
+//Pick returns the object seen through the middle of pixel (x, y) without
+//shading anything, so that tools can find what is under a cursor. It
+//returns nil for a pixel outside the image or one where no object is hit.
+func (c Camera) Pick(w world.World, x, y int) *Pick {
+	if x < 0 || y < 0 || x >= int(c.HSize) || y >= int(c.VSize) {
+		return nil
+	}
+	r := c.RayForPixel(x, y)
+	if r == nil {
+		return nil
+	}
+	comps := w.FirstHit(*r)
+	if comps == nil {
+		return nil
+	}
+	return &Pick{
+		Object:      comps.Object,
+		Point:       comps.Point,
+		Normal:      comps.Normal,
+		Distance:    comps.Value,
+		Computation: *comps,
+	}
+}
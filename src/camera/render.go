@@ -0,0 +1,150 @@
+package camera
+
+import (
+	"image"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/calbim/ray-tracer/src/canvas"
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/matrix"
+	"github.com/calbim/ray-tracer/src/world"
+)
+
+//Options control how an image is rendered. The zero value traces one ray
+//through the middle of each pixel, using every CPU.
+type Options struct {
+	Samples int //rays per pixel, spread over the pixel's area, 1 when zero
+	Threads int //rows rendered at once, runtime.NumCPU() when zero
+	//Progress, if set, is called after each row is finished with the
+	//number of rows done so far. Calls are made one at a time.
+	Progress func(done, total int)
+	//Cancel, if set, stops the render early when closed, leaving the rows
+	//not yet started black
+	Cancel <-chan struct{}
+}
+
+//goldenRatioConjugate is 1/φ, used to spread samples over a pixel
+const goldenRatioConjugate = 0.6180339887498949
+
+//RenderWith renders the world like Render, with more control over how.
+//The color of a pixel is the average of its samples and its alpha is the
+//fraction of them that hit an object.
+func (c Camera) RenderWith(w world.World, o Options) *canvas.Canvas {
+	return c.RenderTile(w, image.Rect(0, 0, int(c.HSize), int(c.VSize)), o)
+}
+
+//RenderTile renders the pixels of the image within tile, which is clipped
+//to the image, to a canvas the size of the tile. Pixel (0, 0) of the canvas
+//is pixel tile.Min of the image, so that tiles rendered separately can be
+//put together into the same image RenderWith would give. Progress counts
+//the tile's rows. If the world has Stats, each thread counts its own and
+//they are added to the world's when the render is done. A panic while
+//rendering stops the other threads and is raised again here, where the
+//caller can recover from it.
+func (c Camera) RenderTile(w world.World, tile image.Rectangle, o Options) *canvas.Canvas {
+	tile = tile.Intersect(image.Rect(0, 0, int(c.HSize), int(c.VSize)))
+	width, height := tile.Dx(), tile.Dy()
+	out := canvas.New(width, height)
+	inverse, err := c.Transform.Inverse()
+	if err != nil {
+		return &out
+	}
+	samples := o.Samples
+	if samples < 1 {
+		samples = 1
+	}
+	threads := o.Threads
+	if threads < 1 {
+		threads = runtime.NumCPU()
+	}
+	rows := make(chan int, height)
+	for y := 0; y < height; y++ {
+		rows <- y
+	}
+	close(rows)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	done := 0
+	var panicked interface{}
+	start := time.Now()
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					mu.Lock()
+					if panicked == nil {
+						panicked = r
+					}
+					mu.Unlock()
+				}
+			}()
+			w := w
+			if w.Stats != nil {
+				stats := w.Stats
+				w.Stats = &world.Stats{}
+				defer func() {
+					mu.Lock()
+					stats.Add(w.Stats)
+					mu.Unlock()
+				}()
+			}
+			for y := range rows {
+				select {
+				case <-o.Cancel:
+					return
+				default:
+				}
+				mu.Lock()
+				failed := panicked != nil
+				mu.Unlock()
+				if failed {
+					return
+				}
+				for x := 0; x < width; x++ {
+					col, alpha := c.samplePixel(w, inverse, tile.Min.X+x, tile.Min.Y+y, samples)
+					out.WritePixel(x, y, col)
+					out.WriteAlpha(x, y, alpha)
+				}
+				if o.Progress != nil {
+					mu.Lock()
+					done++
+					o.Progress(done, height)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if panicked != nil {
+		panic(panicked)
+	}
+	if w.Stats != nil {
+		w.Stats.Rendering += time.Since(start)
+	}
+	return &out
+}
+
+//samplePixel returns the average color of a pixel and the fraction of its
+//samples that hit an object. Samples lie on a Fibonacci lattice over the
+//pixel, so that a single sample is taken from its middle.
+func (c *Camera) samplePixel(w world.World, inverse *matrix.Matrix, x, y, samples int) (color.Color, float64) {
+	sum := color.Black
+	hits := 0
+	n := float64(samples)
+	for i := 0; i < samples; i++ {
+		dx := (float64(i) + 0.5) / n
+		dy := math.Mod(0.5+float64(i)*goldenRatioConjugate, 1)
+		r := c.rayThrough(inverse, float64(x)+dx, float64(y)+dy)
+		col, comps := w.Trace(r)
+		sum = sum.Add(col)
+		if comps != nil {
+			hits++
+		}
+	}
+	return sum.Multiply(1 / n), float64(hits) / n
+}
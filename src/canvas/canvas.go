@@ -1,7 +1,18 @@
 package canvas
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"image"
+	imagecolor "image/color"
+	_ "image/jpeg" // register the JPEG decoder for Load
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/calbim/ray-tracer/src/color"
@@ -9,19 +20,29 @@ import (
 
 // Canvas is a collection of pixels
 type Canvas struct {
-	width  int
-	height int
-	Pixels [][]color.Color
+	width   int
+	height  int
+	Pixels  [][]color.Color
+	Alpha   [][]float64 // coverage of each pixel, from 0 (transparent) to 1
+	ToneMap ToneMap
 }
 
-// New returns a new Canvas with width w and height h
+// New returns a new opaque Canvas with width w and height h
 func New(w, h int) Canvas {
 	pixels := make([][]color.Color, h)
+	alpha := make([][]float64, h)
 	for i := 0; i < h; i++ {
 		pixels[i] = make([]color.Color, w)
+		alpha[i] = make([]float64, w)
+		for j := range alpha[i] {
+			alpha[i][j] = 1
+		}
 	}
 	return Canvas{
-		w, h, pixels,
+		width:  w,
+		height: h,
+		Pixels: pixels,
+		Alpha:  alpha,
 	}
 }
 
@@ -30,6 +51,11 @@ func (c *Canvas) WritePixel(x int, y int, col color.Color) {
 	c.Pixels[y][x] = col
 }
 
+// WriteAlpha writes the coverage of the pixel at width x and height y
+func (c *Canvas) WriteAlpha(x int, y int, a float64) {
+	c.Alpha[y][x] = a
+}
+
 // ToPPM converts a canvas to a PPM image type
 func (c *Canvas) ToPPM() string {
 	var b strings.Builder
@@ -39,8 +65,8 @@ func (c *Canvas) ToPPM() string {
 	length := 0
 	for i := 0; i < c.height; i++ {
 		for j := 0; j < c.width; j++ {
-			p := c.Pixels[i][j]
-			pix := p.ToPPMPixel()
+			red, green, blue := c.ToneMap.Quantize(j, i, c.Pixels[i][j])
+			pix := fmt.Sprintf("%d %d %d ", red, green, blue)
 			b.WriteString(pix)
 			length = length + len(pix)
 			if length > 56 {
@@ -54,3 +80,151 @@ func (c *Canvas) ToPPM() string {
 	}
 	return b.String()
 }
+
+// Load reads an image file into a new canvas. PPM files are read with
+// ReadPPM, anything else is decoded with the standard image decoders.
+func Load(path string) (*Canvas, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if strings.ToLower(filepath.Ext(path)) == ".ppm" {
+		return ReadPPM(file)
+	}
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return FromImage(img), nil
+}
+
+// FromImage converts an image to a new canvas, keeping its alpha channel
+func FromImage(img image.Image) *Canvas {
+	bounds := img.Bounds()
+	c := New(bounds.Dx(), bounds.Dy())
+	for y := 0; y < c.height; y++ {
+		for x := 0; x < c.width; x++ {
+			p := imagecolor.NRGBA64Model.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(imagecolor.NRGBA64)
+			c.WritePixel(x, y, color.New(float64(p.R)/0xffff, float64(p.G)/0xffff, float64(p.B)/0xffff))
+			c.WriteAlpha(x, y, float64(p.A)/0xffff)
+		}
+	}
+	return &c
+}
+
+// Width returns the width of a canvas in pixels
+func (c *Canvas) Width() int {
+	return c.width
+}
+
+// Height returns the height of a canvas in pixels
+func (c *Canvas) Height() int {
+	return c.height
+}
+
+// ReadPPM reads a plain (P3) or raw (P6) PPM image into a new canvas
+func ReadPPM(r io.Reader) (*Canvas, error) {
+	br := bufio.NewReader(r)
+	magic, err := ppmToken(br)
+	if err != nil {
+		return nil, err
+	}
+	if magic != "P3" && magic != "P6" {
+		return nil, fmt.Errorf("unsupported PPM format %q", magic)
+	}
+	header := make([]int, 3)
+	for i := range header {
+		tok, err := ppmToken(br)
+		if err != nil {
+			return nil, err
+		}
+		header[i], err = strconv.Atoi(tok)
+		if err != nil || header[i] <= 0 {
+			return nil, fmt.Errorf("invalid PPM header value %q", tok)
+		}
+	}
+	w, h, max := header[0], header[1], float64(header[2])
+	if magic == "P6" && max > 255 {
+		return nil, errors.New("16-bit raw PPM images are not supported")
+	}
+	c := New(w, h)
+	raw := make([]byte, 3)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var v [3]float64
+			if magic == "P6" {
+				if _, err := io.ReadFull(br, raw); err != nil {
+					return nil, err
+				}
+				for i := range v {
+					v[i] = float64(raw[i]) / max
+				}
+			} else {
+				for i := range v {
+					tok, err := ppmToken(br)
+					if err != nil {
+						return nil, err
+					}
+					n, err := strconv.Atoi(tok)
+					if err != nil {
+						return nil, fmt.Errorf("invalid PPM pixel value %q", tok)
+					}
+					v[i] = float64(n) / max
+				}
+			}
+			c.WritePixel(x, y, color.New(v[0], v[1], v[2]))
+		}
+	}
+	return &c, nil
+}
+
+// ppmToken returns the next whitespace separated token of a PPM header or
+// plain PPM body, skipping comments. The single whitespace character that
+// ends the token is consumed.
+func ppmToken(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		ch, err := r.ReadByte()
+		if err == io.EOF && b.Len() > 0 {
+			return b.String(), nil
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		switch {
+		case ch == '#' && b.Len() == 0:
+			if _, err := r.ReadString('\n'); err != nil {
+				return "", err
+			}
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			if b.Len() > 0 {
+				return b.String(), nil
+			}
+		default:
+			b.WriteByte(ch)
+		}
+	}
+}
+
+// ToImage converts a canvas to an 8-bit image with straight alpha that can
+// be passed to any of the standard library encoders
+func (c *Canvas) ToImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, c.width, c.height))
+	for y := 0; y < c.height; y++ {
+		for x := 0; x < c.width; x++ {
+			r, g, b := c.ToneMap.Quantize(x, y, c.Pixels[y][x])
+			a := uint8(math.Round(clamp(c.Alpha[y][x]) * 255))
+			img.SetNRGBA(x, y, imagecolor.NRGBA{R: r, G: g, B: b, A: a})
+		}
+	}
+	return img
+}
+
+// ToPNG writes a canvas to w as a PNG image, keeping its alpha channel
+func (c *Canvas) ToPNG(w io.Writer) error {
+	return png.Encode(w, c.ToImage())
+}
@@ -1,11 +1,17 @@
 package canvas
 
 import (
+	"bytes"
 	"fmt"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/util"
 )
 
 func TestCanvas(t *testing.T) {
@@ -67,3 +73,246 @@ func TestCanvastoPPM(t *testing.T) {
 		t.Errorf("Line 3: Incorect PPM conversion")
 	}
 }
+
+func TestToneMapDefaultClamps(t *testing.T) {
+	tm := ToneMap{}
+	r, g, b := tm.Quantize(0, 0, color.New(1.5, 0.5, -0.5))
+	if r != 255 || g != 128 || b != 0 {
+		t.Errorf("wanted 255 128 0, got %v %v %v", r, g, b)
+	}
+}
+
+func TestReinhard(t *testing.T) {
+	c := Reinhard(color.New(1, 3, 0))
+	if !c.Equals(color.New(0.5, 0.75, 0)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.5, 0.75, 0), c)
+	}
+}
+
+func TestACES(t *testing.T) {
+	c := ACES(color.New(0, 0.18, 100))
+	expected := color.New(0, 0.26690, 1)
+	if !c.Equals(expected) {
+		t.Errorf("wanted color=%v, got %v", expected, c)
+	}
+}
+
+func TestExposure(t *testing.T) {
+	op := Exposure(1)
+	c := op(color.New(0, 1, 2))
+	expected := color.New(0, 0.63212, 0.86466)
+	if !c.Equals(expected) {
+		t.Errorf("wanted color=%v, got %v", expected, c)
+	}
+}
+
+func TestToneMapGamma(t *testing.T) {
+	tm := ToneMap{Gamma: true}
+	c := tm.Apply(color.New(0.5, 0.002, 1))
+	expected := color.New(0.73536, 0.02584, 1)
+	if !c.Equals(expected) {
+		t.Errorf("wanted color=%v, got %v", expected, c)
+	}
+}
+
+func TestToneMapDither(t *testing.T) {
+	tm := ToneMap{Dither: true}
+	sum := 0
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			r, _, _ := tm.Quantize(x, y, color.New(0.5/255, 0, 0))
+			sum += int(r)
+		}
+	}
+	if sum != 8 {
+		t.Errorf("wanted half of a 4x4 block to round up, got %v pixels", sum)
+	}
+}
+
+func TestCanvasToPPMUsesToneMap(t *testing.T) {
+	c := New(1, 1)
+	c.ToneMap.Operator = Reinhard
+	c.WritePixel(0, 0, color.New(1, 1, 1))
+	ppm := c.ToPPM()
+	line := strings.Trim(strings.Split(ppm, "\n")[3], " ")
+	if line != "128 128 128" {
+		t.Errorf("wanted pixel=%v, got %v", "128 128 128", line)
+	}
+}
+
+func TestCanvasToImage(t *testing.T) {
+	c := New(2, 1)
+	c.WritePixel(1, 0, color.New(1, 0.5, 0))
+	img := c.ToImage()
+	if img.Bounds().Dx() != 2 || img.Bounds().Dy() != 1 {
+		t.Errorf("wanted image bounds 2x1, got %v", img.Bounds())
+	}
+	p := img.NRGBAAt(1, 0)
+	if p.R != 255 || p.G != 128 || p.B != 0 || p.A != 255 {
+		t.Errorf("wanted pixel=%v, got %v", "255 128 0 255", p)
+	}
+}
+
+func TestReadPlainPPM(t *testing.T) {
+	ppm := "P3\n# a comment\n2 1\n255\n255 0 0 0 128\n255\n"
+	c, err := ReadPPM(strings.NewReader(ppm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.width != 2 || c.height != 1 {
+		t.Errorf("wanted canvas of 2x1, got %vx%v", c.width, c.height)
+	}
+	if !c.Pixels[0][0].Equals(color.New(1, 0, 0)) {
+		t.Errorf("wanted pixel=%v, got %v", color.New(1, 0, 0), c.Pixels[0][0])
+	}
+	if !c.Pixels[0][1].Equals(color.New(0, 0.50196, 1)) {
+		t.Errorf("wanted pixel=%v, got %v", color.New(0, 0.50196, 1), c.Pixels[0][1])
+	}
+}
+
+func TestReadRawPPM(t *testing.T) {
+	ppm := "P6\n1 1\n255\n\xff\x00\x33"
+	c, err := ReadPPM(strings.NewReader(ppm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Pixels[0][0].Equals(color.New(1, 0, 0.2)) {
+		t.Errorf("wanted pixel=%v, got %v", color.New(1, 0, 0.2), c.Pixels[0][0])
+	}
+}
+
+func TestReadPPMRoundTrip(t *testing.T) {
+	c := New(5, 3)
+	c.WritePixel(0, 0, color.New(1.5, 0, 0))
+	c.WritePixel(2, 1, color.New(0, 0.5, 0))
+	c.WritePixel(4, 2, color.New(-0.5, 0, 1))
+	read, err := ReadPPM(strings.NewReader(c.ToPPM()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmp, err := Compare(&c, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmp.MaxError > 0.5/255 {
+		t.Errorf("wanted round trip error below half a step, got %v", cmp.MaxError)
+	}
+}
+
+func TestReadInvalidPPM(t *testing.T) {
+	if _, err := ReadPPM(strings.NewReader("P2\n1 1\n255\n0\n")); err == nil {
+		t.Errorf("wanted an error for an unsupported format")
+	}
+	if _, err := ReadPPM(strings.NewReader("P3\n2 1\n255\n0 0 0\n")); err == nil {
+		t.Errorf("wanted an error for truncated pixel data")
+	}
+}
+
+func TestCompareIdenticalCanvases(t *testing.T) {
+	a := New(2, 2)
+	b := New(2, 2)
+	a.WritePixel(1, 1, color.White)
+	b.WritePixel(1, 1, color.White)
+	cmp, err := Compare(&a, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmp.MaxError != 0 || cmp.RMSE != 0 || !math.IsInf(cmp.PSNR, 1) {
+		t.Errorf("wanted identical canvases, got max error=%v rmse=%v psnr=%v", cmp.MaxError, cmp.RMSE, cmp.PSNR)
+	}
+}
+
+func TestCompareDifferentCanvases(t *testing.T) {
+	a := New(2, 1)
+	b := New(2, 1)
+	a.WritePixel(0, 0, color.New(0.5, 0, 0))
+	cmp, err := Compare(&a, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmp.Errors[0][0] != 0.5 || cmp.Errors[0][1] != 0 {
+		t.Errorf("wanted per pixel errors [0.5 0], got %v", cmp.Errors[0])
+	}
+	if !util.Equals(cmp.RMSE, math.Sqrt(0.25/6)) {
+		t.Errorf("wanted rmse=%v, got %v", math.Sqrt(0.25/6), cmp.RMSE)
+	}
+	if !util.Equals(cmp.PSNR, 13.80211) {
+		t.Errorf("wanted psnr=%v, got %v", 13.80211, cmp.PSNR)
+	}
+	if !cmp.Diff.Pixels[0][0].Equals(color.New(0.75, 0, 0)) {
+		t.Errorf("wanted differing pixel highlighted as %v, got %v", color.New(0.75, 0, 0), cmp.Diff.Pixels[0][0])
+	}
+	if !cmp.Diff.Pixels[0][1].Equals(color.Black) {
+		t.Errorf("wanted matching pixel=%v, got %v", color.Black, cmp.Diff.Pixels[0][1])
+	}
+}
+
+func TestCompareDifferentSizes(t *testing.T) {
+	a := New(2, 1)
+	b := New(1, 2)
+	if _, err := Compare(&a, &b); err == nil {
+		t.Errorf("wanted an error comparing canvases of different sizes")
+	}
+}
+
+func TestCanvasIsOpaqueByDefault(t *testing.T) {
+	c := New(3, 2)
+	for i := 0; i < c.height; i++ {
+		for j := 0; j < c.width; j++ {
+			if c.Alpha[i][j] != 1 {
+				t.Errorf("wanted alpha at (%v,%v)=%v, got %v", j, i, 1, c.Alpha[i][j])
+			}
+		}
+	}
+}
+
+func TestCanvasToPNGKeepsAlpha(t *testing.T) {
+	c := New(2, 1)
+	c.WritePixel(0, 0, color.New(1, 0, 0))
+	c.WriteAlpha(1, 0, 0)
+	var b bytes.Buffer
+	if err := c.ToPNG(&b); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _, _, a := img.At(0, 0).RGBA()
+	if r != 0xffff || a != 0xffff {
+		t.Errorf("wanted opaque red pixel, got r=%v a=%v", r, a)
+	}
+	_, _, _, a = img.At(1, 0).RGBA()
+	if a != 0 {
+		t.Errorf("wanted transparent pixel, got a=%v", a)
+	}
+}
+
+func TestLoadPNG(t *testing.T) {
+	c := New(2, 1)
+	c.WritePixel(0, 0, color.New(1, 0, 0))
+	c.WritePixel(1, 0, color.New(0, 0.2, 1))
+	c.WriteAlpha(1, 0, 0.4)
+	path := filepath.Join(t.TempDir(), "image.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ToPNG(file); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Width() != 2 || loaded.Height() != 1 {
+		t.Errorf("wanted canvas of 2x1, got %vx%v", loaded.Width(), loaded.Height())
+	}
+	if !loaded.Pixels[0][1].Equals(color.New(0, 0.2, 1)) {
+		t.Errorf("wanted pixel=%v, got %v", color.New(0, 0.2, 1), loaded.Pixels[0][1])
+	}
+	if !util.Equals(loaded.Alpha[0][1], 0.4) || loaded.Alpha[0][0] != 1 {
+		t.Errorf("wanted alpha 1 and 0.4, got %v and %v", loaded.Alpha[0][0], loaded.Alpha[0][1])
+	}
+}
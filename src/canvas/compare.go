@@ -0,0 +1,55 @@
+package canvas
+
+import (
+	"errors"
+	"math"
+
+	"github.com/calbim/ray-tracer/src/color"
+)
+
+// Comparison is the result of comparing two canvases pixel by pixel
+type Comparison struct {
+	Errors   [][]float64 // largest absolute channel difference of each pixel
+	MaxError float64
+	RMSE     float64
+	PSNR     float64 // in decibels, +Inf when the canvases are identical
+	Diff     *Canvas // the reference dimmed to grey, with differing pixels in red
+}
+
+// Compare compares canvas c against the reference canvas ref. Colors are
+// compared after each canvas' tone map is applied, so a render can be
+// compared directly with an image read back from disk.
+func Compare(c, ref *Canvas) (*Comparison, error) {
+	if c.width != ref.width || c.height != ref.height {
+		return nil, errors.New("canvases have different dimensions")
+	}
+	diff := New(c.width, c.height)
+	errs := make([][]float64, c.height)
+	maxError, sum := 0.0, 0.0
+	for y := 0; y < c.height; y++ {
+		errs[y] = make([]float64, c.width)
+		for x := 0; x < c.width; x++ {
+			a := c.ToneMap.Apply(c.Pixels[y][x])
+			b := ref.ToneMap.Apply(ref.Pixels[y][x])
+			d := a.Subtract(b)
+			sum += d.R*d.R + d.G*d.G + d.B*d.B
+			e := math.Max(math.Abs(d.R), math.Max(math.Abs(d.G), math.Abs(d.B)))
+			errs[y][x] = e
+			maxError = math.Max(maxError, e)
+			if e > 0 {
+				diff.WritePixel(x, y, color.New(0.5+e/2, 0, 0))
+			} else {
+				grey := (0.2126*b.R + 0.7152*b.G + 0.0722*b.B) / 4
+				diff.WritePixel(x, y, color.New(grey, grey, grey))
+			}
+		}
+	}
+	mse := sum / float64(3*c.width*c.height)
+	return &Comparison{
+		Errors:   errs,
+		MaxError: maxError,
+		RMSE:     math.Sqrt(mse),
+		PSNR:     10 * math.Log10(1/mse),
+		Diff:     &diff,
+	}, nil
+}
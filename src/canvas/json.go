@@ -0,0 +1,72 @@
+package canvas
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/calbim/ray-tracer/src/color"
+)
+
+// jsonCanvas is the encoded form of a canvas. Pixels holds the red, green
+// and blue of each pixel in turn, row by row, and Alpha is left out when
+// every pixel is opaque.
+type jsonCanvas struct {
+	Width  int       `json:"width"`
+	Height int       `json:"height"`
+	Pixels []float64 `json:"pixels"`
+	Alpha  []float64 `json:"alpha,omitempty"`
+}
+
+// MarshalJSON encodes the size, colors and coverage of a canvas. The tone
+// map is a setting for exporting and is not included.
+func (c *Canvas) MarshalJSON() ([]byte, error) {
+	j := jsonCanvas{
+		Width:  c.width,
+		Height: c.height,
+		Pixels: make([]float64, 0, 3*c.width*c.height),
+	}
+	opaque := true
+	for y := 0; y < c.height; y++ {
+		for x := 0; x < c.width; x++ {
+			p := c.Pixels[y][x]
+			j.Pixels = append(j.Pixels, p.R, p.G, p.B)
+			opaque = opaque && c.Alpha[y][x] == 1
+		}
+	}
+	if !opaque {
+		for y := 0; y < c.height; y++ {
+			j.Alpha = append(j.Alpha, c.Alpha[y]...)
+		}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a canvas written by MarshalJSON
+func (c *Canvas) UnmarshalJSON(data []byte) error {
+	var j jsonCanvas
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Width < 0 || j.Height < 0 {
+		return fmt.Errorf("canvas: invalid size %dx%d", j.Width, j.Height)
+	}
+	if len(j.Pixels) != 3*j.Width*j.Height {
+		return fmt.Errorf("canvas: expected %d pixel values, got %d", 3*j.Width*j.Height, len(j.Pixels))
+	}
+	if j.Alpha != nil && len(j.Alpha) != j.Width*j.Height {
+		return fmt.Errorf("canvas: expected %d alpha values, got %d", j.Width*j.Height, len(j.Alpha))
+	}
+	n := New(j.Width, j.Height)
+	for y := 0; y < j.Height; y++ {
+		for x := 0; x < j.Width; x++ {
+			i := y*j.Width + x
+			n.Pixels[y][x] = color.New(j.Pixels[3*i], j.Pixels[3*i+1], j.Pixels[3*i+2])
+			if j.Alpha != nil {
+				n.Alpha[y][x] = j.Alpha[i]
+			}
+		}
+	}
+	n.ToneMap = c.ToneMap
+	*c = n
+	return nil
+}
@@ -0,0 +1,90 @@
+package canvas
+
+import (
+	"math"
+
+	"github.com/calbim/ray-tracer/src/color"
+)
+
+// Operator compresses a linear color into the displayable [0,1] range
+type Operator func(c color.Color) color.Color
+
+// ToneMap describes how the linear colors of a canvas are turned into
+// 8-bit values when the canvas is exported
+type ToneMap struct {
+	Operator Operator // defaults to Clamp when nil
+	Gamma    bool     // encode with the sRGB transfer curve
+	Dither   bool     // use ordered dithering instead of rounding
+}
+
+// bayer is a 4x4 ordered dithering matrix
+var bayer = [4][4]float64{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// Clamp clips each channel to [0,1]
+func Clamp(c color.Color) color.Color {
+	return color.New(clamp(c.R), clamp(c.G), clamp(c.B))
+}
+
+// Reinhard maps each channel x to x/(1+x)
+func Reinhard(c color.Color) color.Color {
+	f := func(x float64) float64 {
+		x = math.Max(x, 0)
+		return x / (1 + x)
+	}
+	return color.New(f(c.R), f(c.G), f(c.B))
+}
+
+// ACES applies Narkowicz's fit of the ACES filmic curve
+func ACES(c color.Color) color.Color {
+	f := func(x float64) float64 {
+		x = math.Max(x, 0)
+		return clamp((x * (2.51*x + 0.03)) / (x*(2.43*x+0.59) + 0.14))
+	}
+	return color.New(f(c.R), f(c.G), f(c.B))
+}
+
+// Exposure returns an operator that maps each channel x to 1-e^(-k*x),
+// mimicking the response of film exposed for k units of time
+func Exposure(k float64) Operator {
+	return func(c color.Color) color.Color {
+		f := func(x float64) float64 {
+			return 1 - math.Exp(-k*math.Max(x, 0))
+		}
+		return color.New(f(c.R), f(c.G), f(c.B))
+	}
+}
+
+// Apply returns the tone mapped, and optionally gamma encoded, color
+func (t ToneMap) Apply(c color.Color) color.Color {
+	op := t.Operator
+	if op == nil {
+		op = Clamp
+	}
+	c = Clamp(op(c))
+	if t.Gamma {
+		c = c.ToSRGB()
+	}
+	return c
+}
+
+// Quantize converts the color of pixel (x,y) to 8-bit channel values
+func (t ToneMap) Quantize(x, y int, c color.Color) (uint8, uint8, uint8) {
+	c = t.Apply(c)
+	offset := 0.5
+	if t.Dither {
+		offset = (bayer[y%4][x%4] + 0.5) / 16
+	}
+	q := func(v float64) uint8 {
+		return uint8(math.Min(math.Floor(v*255+offset), 255))
+	}
+	return q(c.R), q(c.G), q(c.B)
+}
+
+func clamp(x float64) float64 {
+	return math.Max(0, math.Min(1, x))
+}
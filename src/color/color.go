@@ -1,6 +1,8 @@
 package color
 
 import (
+	"encoding/json"
+	"fmt"
 	"math"
 	"strconv"
 
@@ -58,12 +60,43 @@ func (c *Color) ToPPMPixel() string {
 	return r + " " + g + " " + b + " "
 }
 
+// ToSRGB encodes a linear color with the sRGB transfer curve
+func (c *Color) ToSRGB() Color {
+	return New(toSRGB(c.R), toSRGB(c.G), toSRGB(c.B))
+}
+
 // FromHex returns a color from a hex string
 func FromHex(hex string) Color {
 	rgb := util.HexToRGB(hex)
 	return New(rgb[0], rgb[1], rgb[2])
 }
 
+// FromID returns a distinct and stable false color for an identifier, with
+// 0 mapping to black
+func FromID(id int) Color {
+	if id == 0 {
+		return Black
+	}
+	h := math.Mod(float64(id)*0.618033988749895, 1) * 6
+	x := 1 - math.Abs(math.Mod(h, 2)-1)
+	var c Color
+	switch int(h) {
+	case 0:
+		c = New(1, x, 0)
+	case 1:
+		c = New(x, 1, 0)
+	case 2:
+		c = New(0, 1, x)
+	case 3:
+		c = New(0, x, 1)
+	case 4:
+		c = New(x, 0, 1)
+	default:
+		c = New(1, 0, x)
+	}
+	return New(0.2+0.75*c.R, 0.2+0.75*c.G, 0.2+0.75*c.B)
+}
+
 // ToInt converts a float color to a number lying between 0 and 255
 func ToInt(c float64) string {
 	i := math.Round(c * 255)
@@ -75,3 +108,28 @@ func ToInt(c float64) string {
 	}
 	return strconv.Itoa(int(i))
 }
+
+func toSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// MarshalJSON encodes a color as an array of its red, green and blue parts
+func (c Color) MarshalJSON() ([]byte, error) {
+	return json.Marshal([3]float64{c.R, c.G, c.B})
+}
+
+// UnmarshalJSON decodes a color from an array of three numbers
+func (c *Color) UnmarshalJSON(data []byte) error {
+	var v []float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if len(v) != 3 {
+		return fmt.Errorf("color: expected 3 values, got %d", len(v))
+	}
+	c.R, c.G, c.B = v[0], v[1], v[2]
+	return nil
+}
@@ -0,0 +1,184 @@
+package distributed
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"image"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/calbim/ray-tracer/src/canvas"
+	"github.com/calbim/ray-tracer/src/scene"
+)
+
+//Options control how a render is split between workers. The zero value
+//sends 32x32 pixel tiles with one sample per pixel and waits for workers
+//for as long as they take.
+type Options struct {
+	TileSize int //width and height of the tiles in pixels, 32 when zero
+	Samples  int //rays per pixel, 1 when zero
+	//Timeout, if set, is how long a worker may take to answer before it is
+	//given up on and its tile handed to another
+	Timeout time.Duration
+	//Progress, if set, is called after each tile is put in place with the
+	//number of tiles done so far. Calls are made one at a time.
+	Progress func(done, total int)
+}
+
+//job is a render being handed out to workers
+type job struct {
+	id     string
+	scene  []byte
+	o      Options
+	queue  chan image.Rectangle //tiles waiting for a worker
+	mu     sync.Mutex
+	image  canvas.Canvas
+	done   int
+	total  int
+	alive  int //workers still taking tiles
+	err    error
+	finish chan struct{} //closed when every tile is done or no workers are left
+}
+
+//Render renders a scene on the workers listening at addrs and puts their
+//tiles together into the image camera.RenderWith would give. A worker that
+//cannot be reached, fails or times out is given no more tiles, and the tile
+//it had is handed to another. An error is returned only when no workers are
+//left before the image is done.
+func Render(s *scene.Scene, addrs []string, o Options) (*canvas.Canvas, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("distributed: no workers")
+	}
+	data, err := scene.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	if o.TileSize < 1 {
+		o.TileSize = 32
+	}
+	if o.Samples < 1 {
+		o.Samples = 1
+	}
+	width, height := int(s.Camera.HSize), int(s.Camera.VSize)
+	bounds := image.Rect(0, 0, width, height)
+	var tiles []image.Rectangle
+	for y := 0; y < height; y += o.TileSize {
+		for x := 0; x < width; x += o.TileSize {
+			tiles = append(tiles, image.Rect(x, y, x+o.TileSize, y+o.TileSize).Intersect(bounds))
+		}
+	}
+	j := &job{
+		id:     fmt.Sprintf("%x", sha256.Sum256(data)),
+		scene:  data,
+		o:      o,
+		queue:  make(chan image.Rectangle, len(tiles)),
+		image:  canvas.New(width, height),
+		total:  len(tiles),
+		alive:  len(addrs),
+		finish: make(chan struct{}),
+	}
+	if len(tiles) == 0 {
+		return &j.image, nil
+	}
+	for _, t := range tiles {
+		j.queue <- t
+	}
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			j.work(addr)
+		}(addr)
+	}
+	<-j.finish
+	wg.Wait()
+	if j.err != nil {
+		return nil, j.err
+	}
+	return &j.image, nil
+}
+
+//work hands tiles to one worker until the job is finished or the worker
+//fails
+func (j *job) work(addr string) {
+	err := j.serve(addr)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.alive--
+	if j.alive == 0 && j.done < j.total {
+		j.err = fmt.Errorf("distributed: no workers left with %d of %d tiles done, last error: %v", j.done, j.total, err)
+		close(j.finish)
+	}
+}
+
+func (j *job) serve(addr string) error {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	client := rpc.NewClient(conn)
+	defer client.Close()
+	var ok bool
+	if err := j.call(client, "Worker.Load", LoadArgs{ID: j.id, Scene: j.scene}, &ok); err != nil {
+		return fmt.Errorf("%s: %v", addr, err)
+	}
+	for {
+		select {
+		case <-j.finish:
+			return nil
+		case tile := <-j.queue:
+			var reply TileReply
+			err := j.call(client, "Worker.Render", TileArgs{Scene: j.id, Tile: tile, Samples: j.o.Samples}, &reply)
+			if err == nil && (len(reply.Pixels) != tile.Dx()*tile.Dy() || len(reply.Alpha) != len(reply.Pixels)) {
+				err = fmt.Errorf("got %d pixels for a %dx%d tile", len(reply.Pixels), tile.Dx(), tile.Dy())
+			}
+			if err != nil {
+				j.queue <- tile
+				return fmt.Errorf("%s: %v", addr, err)
+			}
+			j.put(tile, &reply)
+		}
+	}
+}
+
+//call makes a remote call, giving up after the job's timeout
+func (j *job) call(client *rpc.Client, method string, args, reply interface{}) error {
+	call := client.Go(method, args, reply, make(chan *rpc.Call, 1))
+	if j.o.Timeout <= 0 {
+		<-call.Done
+		return call.Error
+	}
+	timer := time.NewTimer(j.o.Timeout)
+	defer timer.Stop()
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-timer.C:
+		return fmt.Errorf("no answer after %v", j.o.Timeout)
+	}
+}
+
+//put copies a finished tile into the image
+func (j *job) put(tile image.Rectangle, reply *TileReply) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	i := 0
+	for y := tile.Min.Y; y < tile.Max.Y; y++ {
+		for x := tile.Min.X; x < tile.Max.X; x++ {
+			j.image.WritePixel(x, y, reply.Pixels[i])
+			j.image.WriteAlpha(x, y, reply.Alpha[i])
+			i++
+		}
+	}
+	j.done++
+	if j.o.Progress != nil {
+		j.o.Progress(j.done, j.total)
+	}
+	if j.done == j.total {
+		close(j.finish)
+	}
+}
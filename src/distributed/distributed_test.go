@@ -0,0 +1,214 @@
+package distributed
+
+import (
+	"errors"
+	"image"
+	"math"
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/calbim/ray-tracer/src/camera"
+	"github.com/calbim/ray-tracer/src/canvas"
+	"github.com/calbim/ray-tracer/src/scene"
+	"github.com/calbim/ray-tracer/src/transforms"
+	"github.com/calbim/ray-tracer/src/tuple"
+	"github.com/calbim/ray-tracer/src/world"
+)
+
+func testScene() *scene.Scene {
+	c := camera.New(45, 30, math.Pi/2)
+	c.Transform = transforms.ViewTransform(tuple.Point(0, 0, -5), tuple.Point(0, 0, 0), tuple.Vector(0, 1, 0))
+	return &scene.Scene{World: world.Default(), Camera: c}
+}
+
+//startWorker serves a worker on a free local port until the test ends
+func startWorker(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go Serve(l, &Worker{Threads: 2})
+	return l.Addr().String()
+}
+
+//dyingWorker renders its first tile and then shuts down, closing its
+//listener and connections as if its process were killed
+type dyingWorker struct {
+	*Worker
+	l     net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+	tiles int
+}
+
+func (d *dyingWorker) Render(args TileArgs, reply *TileReply) error {
+	d.mu.Lock()
+	d.tiles++
+	dead := d.tiles > 1
+	d.mu.Unlock()
+	if !dead {
+		return d.Worker.Render(args, reply)
+	}
+	d.l.Close()
+	d.mu.Lock()
+	for _, c := range d.conns {
+		c.Close()
+	}
+	d.mu.Unlock()
+	return errors.New("killed")
+}
+
+func startDyingWorker(t *testing.T) (string, *dyingWorker) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	d := &dyingWorker{Worker: &Worker{}, l: l}
+	s := rpc.NewServer()
+	s.RegisterName("Worker", d)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			d.mu.Lock()
+			d.conns = append(d.conns, conn)
+			d.mu.Unlock()
+			go s.ServeConn(conn)
+		}
+	}()
+	return l.Addr().String(), d
+}
+
+//hangingWorker loads scenes but never answers for a tile
+type hangingWorker struct {
+	*Worker
+	stop chan struct{}
+}
+
+func (h *hangingWorker) Render(args TileArgs, reply *TileReply) error {
+	<-h.stop
+	return errors.New("stopped")
+}
+
+func startHangingWorker(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &hangingWorker{Worker: &Worker{}, stop: make(chan struct{})}
+	t.Cleanup(func() {
+		close(h.stop)
+		l.Close()
+	})
+	s := rpc.NewServer()
+	s.RegisterName("Worker", h)
+	go s.Accept(l)
+	return l.Addr().String()
+}
+
+//unreachable returns an address nothing is listening on
+func unreachable(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func checkImage(t *testing.T, want, got *canvas.Canvas) {
+	t.Helper()
+	if got.Width() != want.Width() || got.Height() != want.Height() {
+		t.Fatalf("wanted a %vx%v image, got %vx%v", want.Width(), want.Height(), got.Width(), got.Height())
+	}
+	for y := range want.Pixels {
+		for x := range want.Pixels[y] {
+			if got.Pixels[y][x] != want.Pixels[y][x] || got.Alpha[y][x] != want.Alpha[y][x] {
+				t.Fatalf("wanted pixel at %d,%d=%v, got %v", x, y, want.Pixels[y][x], got.Pixels[y][x])
+			}
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	s := testScene()
+	want := s.Camera.RenderWith(s.World, camera.Options{Samples: 2})
+	workers := []string{startWorker(t), startWorker(t), startWorker(t)}
+	var calls []int
+	got, err := Render(s, workers, Options{TileSize: 8, Samples: 2, Progress: func(done, total int) {
+		if total != 24 {
+			t.Errorf("wanted total=%v, got %v", 24, total)
+		}
+		calls = append(calls, done)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, want, got)
+	if len(calls) != 24 || calls[23] != 24 {
+		t.Errorf("wanted progress calls 1 to 24, got %v", calls)
+	}
+}
+
+func TestRenderReschedulesTiles(t *testing.T) {
+	s := testScene()
+	want := s.Camera.RenderWith(s.World, camera.Options{})
+	dying, d := startDyingWorker(t)
+	workers := []string{dying, unreachable(t), startHangingWorker(t), startWorker(t)}
+	got, err := Render(s, workers, Options{TileSize: 5, Timeout: 500 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, want, got)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.tiles < 2 {
+		t.Errorf("wanted the dying worker to be given a second tile, got %v", d.tiles)
+	}
+}
+
+func TestRenderWithoutWorkers(t *testing.T) {
+	s := testScene()
+	if _, err := Render(s, nil, Options{}); err == nil {
+		t.Errorf("wanted an error with no workers")
+	}
+	if _, err := Render(s, []string{unreachable(t), unreachable(t)}, Options{}); err == nil {
+		t.Errorf("wanted an error when no worker can be reached")
+	}
+	dying, _ := startDyingWorker(t)
+	if _, err := Render(s, []string{dying}, Options{TileSize: 5}); err == nil {
+		t.Errorf("wanted an error when the only worker dies")
+	}
+}
+
+func TestWorkerKeepsRecentScenes(t *testing.T) {
+	data, err := scene.Marshal(testScene())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &Worker{}
+	var ok bool
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		if err := w.Load(LoadArgs{ID: id, Scene: data}, &ok); err != nil || !ok {
+			t.Fatalf("wanted scene %s loaded, got %v", id, err)
+		}
+	}
+	var reply TileReply
+	if err := w.Render(TileArgs{Scene: "a"}, &reply); err == nil {
+		t.Errorf("wanted the oldest scene forgotten")
+	}
+	if err := w.Render(TileArgs{Scene: "e", Tile: image.Rect(0, 0, 2, 3)}, &reply); err != nil || len(reply.Pixels) != 6 {
+		t.Errorf("wanted 6 pixels, got %v, %v", len(reply.Pixels), err)
+	}
+	if err := w.Load(LoadArgs{ID: "x", Scene: []byte("{")}, &ok); err == nil {
+		t.Errorf("wanted an error for a bad scene")
+	}
+}
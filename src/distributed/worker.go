@@ -0,0 +1,109 @@
+//Package distributed renders a scene on several machines at once. Worker
+//processes serve tiles of an image over net/rpc and Render, the
+//coordinator, hands tiles out to them and puts the image back together.
+package distributed
+
+import (
+	"fmt"
+	"image"
+	"net"
+	"net/rpc"
+	"sync"
+
+	"github.com/calbim/ray-tracer/src/camera"
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/scene"
+)
+
+//maxScenes is the number of scenes a worker keeps loaded
+const maxScenes = 4
+
+//Worker renders tiles for coordinators. Scenes are sent to it once with
+//Load and named in each tile asked for after, and the last few are kept.
+type Worker struct {
+	Threads int //rows of a tile rendered at once, runtime.NumCPU() when zero
+
+	mu     sync.Mutex
+	scenes map[string]*scene.Scene
+	order  []string //scene IDs, oldest first
+}
+
+//LoadArgs sends a scene to a worker
+type LoadArgs struct {
+	ID    string //names the scene in TileArgs
+	Scene []byte //the scene as written by scene.Marshal
+}
+
+//TileArgs asks a worker for the pixels of a loaded scene within Tile
+type TileArgs struct {
+	Scene   string
+	Tile    image.Rectangle
+	Samples int
+}
+
+//TileReply holds the colors and alphas of a tile's pixels, row by row
+type TileReply struct {
+	Pixels []color.Color
+	Alpha  []float64
+}
+
+//Load decodes a scene and keeps it under its ID
+func (w *Worker) Load(args LoadArgs, ok *bool) error {
+	w.mu.Lock()
+	_, loaded := w.scenes[args.ID]
+	w.mu.Unlock()
+	if !loaded {
+		s, err := scene.Unmarshal(args.Scene)
+		if err != nil {
+			return fmt.Errorf("distributed: bad scene: %v", err)
+		}
+		w.mu.Lock()
+		if w.scenes == nil {
+			w.scenes = map[string]*scene.Scene{}
+		}
+		if _, loaded := w.scenes[args.ID]; !loaded {
+			w.scenes[args.ID] = s
+			w.order = append(w.order, args.ID)
+		}
+		for len(w.order) > maxScenes {
+			delete(w.scenes, w.order[0])
+			w.order = w.order[1:]
+		}
+		w.mu.Unlock()
+	}
+	*ok = true
+	return nil
+}
+
+//Render renders a tile of a loaded scene
+func (w *Worker) Render(args TileArgs, reply *TileReply) error {
+	w.mu.Lock()
+	s, ok := w.scenes[args.Scene]
+	w.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("distributed: unknown scene %s", args.Scene)
+	}
+	tile := s.Camera.RenderTile(s.World, args.Tile, camera.Options{Samples: args.Samples, Threads: w.Threads})
+	reply.Pixels = make([]color.Color, 0, tile.Width()*tile.Height())
+	reply.Alpha = make([]float64, 0, tile.Width()*tile.Height())
+	for y := range tile.Pixels {
+		reply.Pixels = append(reply.Pixels, tile.Pixels[y]...)
+		reply.Alpha = append(reply.Alpha, tile.Alpha[y]...)
+	}
+	return nil
+}
+
+//Serve answers coordinators connecting on l with w, until l is closed
+func Serve(l net.Listener, w *Worker) error {
+	s := rpc.NewServer()
+	if err := s.RegisterName("Worker", w); err != nil {
+		return err
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
@@ -0,0 +1,53 @@
+package golden
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/calbim/ray-tracer/src/canvas"
+)
+
+var update = flag.Bool("update", false, "rewrite golden images instead of comparing against them")
+
+// Assert compares a rendered canvas against the reference PPM image at path.
+// The test fails if any pixel differs by more than tolerance in any channel,
+// in which case the render and a difference image are written next to the
+// reference as <name>.actual.ppm and <name>.diff.ppm. Running the test with
+// -update rewrites the reference instead.
+func Assert(t testing.TB, c *canvas.Canvas, path string, tolerance float64) {
+	t.Helper()
+	if *update {
+		if err := ioutil.WriteFile(path, []byte(c.ToPPM()), 0644); err != nil {
+			t.Fatalf("could not update golden image: %v", err)
+		}
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("could not open golden image: %v", err)
+	}
+	defer file.Close()
+	ref, err := canvas.ReadPPM(file)
+	if err != nil {
+		t.Fatalf("could not read golden image %v: %v", path, err)
+	}
+	cmp, err := canvas.Compare(c, ref)
+	if err != nil {
+		t.Fatalf("could not compare with golden image %v: %v", path, err)
+	}
+	if cmp.MaxError <= tolerance {
+		return
+	}
+	base := strings.TrimSuffix(path, ".ppm")
+	if err := ioutil.WriteFile(base+".actual.ppm", []byte(c.ToPPM()), 0644); err != nil {
+		t.Errorf("could not write render: %v", err)
+	}
+	if err := ioutil.WriteFile(base+".diff.ppm", []byte(cmp.Diff.ToPPM()), 0644); err != nil {
+		t.Errorf("could not write difference image: %v", err)
+	}
+	t.Errorf("render differs from %v: max error=%v (tolerance %v), rmse=%v, psnr=%vdB, diff written to %v",
+		path, cmp.MaxError, tolerance, cmp.RMSE, cmp.PSNR, base+".diff.ppm")
+}
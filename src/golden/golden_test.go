@@ -0,0 +1,118 @@
+package golden
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/calbim/ray-tracer/src/canvas"
+	"github.com/calbim/ray-tracer/src/color"
+)
+
+// recorder stands in for a test so that failures can be checked
+type recorder struct {
+	testing.TB
+	failed bool
+	msg    string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failed = true
+	r.msg += fmt.Sprintf(format, args...) + "\n"
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// assert runs Assert against a recorder
+func assert(c *canvas.Canvas, path string, tolerance float64) *recorder {
+	r := &recorder{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Assert(r, c, path, tolerance)
+	}()
+	<-done
+	return r
+}
+
+// gray returns a canvas filled with a gray of level out of 255, which a PPM
+// holds exactly
+func gray(width, height, level int) *canvas.Canvas {
+	c := canvas.New(width, height)
+	v := float64(level) / 255
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c.WritePixel(x, y, color.New(v, v, v))
+		}
+	}
+	return &c
+}
+
+func writeGolden(t *testing.T, c *canvas.Canvas) string {
+	path := filepath.Join(t.TempDir(), "ref.ppm")
+	if err := ioutil.WriteFile(path, []byte(c.ToPPM()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAssertWithinTolerance(t *testing.T) {
+	path := writeGolden(t, gray(4, 3, 100))
+	if r := assert(gray(4, 3, 102), path, 0.01); r.failed {
+		t.Errorf("wanted a difference of 2/255 to pass with tolerance 0.01, got %q", r.msg)
+	}
+	if _, err := os.Stat(strings.TrimSuffix(path, ".ppm") + ".diff.ppm"); !os.IsNotExist(err) {
+		t.Errorf("wanted no difference image for a pass, got %v", err)
+	}
+}
+
+func TestAssertOverTolerance(t *testing.T) {
+	path := writeGolden(t, gray(4, 3, 100))
+	r := assert(gray(4, 3, 102), path, 0.005)
+	if !r.failed || !strings.Contains(r.msg, "render differs from") {
+		t.Errorf("wanted a difference of 2/255 to fail with tolerance 0.005, got %q", r.msg)
+	}
+	base := strings.TrimSuffix(path, ".ppm")
+	for _, name := range []string{base + ".actual.ppm", base + ".diff.ppm"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("wanted %s written, got %v", name, err)
+		}
+	}
+}
+
+func TestAssertSizeMismatch(t *testing.T) {
+	path := writeGolden(t, gray(4, 3, 100))
+	r := assert(gray(3, 4, 100), path, 1)
+	if !r.failed || !strings.Contains(r.msg, "could not compare") {
+		t.Errorf("wanted a failure for a render of the wrong size, got %q", r.msg)
+	}
+}
+
+func TestAssertMissingGolden(t *testing.T) {
+	r := assert(gray(2, 2, 0), filepath.Join(t.TempDir(), "missing.ppm"), 0)
+	if !r.failed || !strings.Contains(r.msg, "could not open golden image") {
+		t.Errorf("wanted a failure for a missing golden image, got %q", r.msg)
+	}
+}
+
+func TestAssertUpdate(t *testing.T) {
+	*update = true
+	defer func() { *update = false }()
+	path := writeGolden(t, gray(4, 3, 100))
+	if r := assert(gray(4, 3, 200), path, 0); r.failed {
+		t.Fatalf("wanted -update to rewrite the golden image, got %q", r.msg)
+	}
+	*update = false
+	if r := assert(gray(4, 3, 200), path, 0); r.failed {
+		t.Errorf("wanted the rewritten golden image to match, got %q", r.msg)
+	}
+}
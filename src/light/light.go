@@ -8,8 +8,8 @@ import (
 
 //Light represents a light of given intensity at a position
 type Light struct {
-	Intensity color.Color
-	Position  tuple.Tuple
+	Intensity color.Color `json:"intensity"`
+	Position  tuple.Tuple `json:"position"`
 }
 
 //PointLight returns a light originating at point p and intensity i
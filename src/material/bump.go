@@ -0,0 +1,80 @@
+package material
+
+import (
+	"math"
+
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/pattern"
+	"github.com/calbim/ray-tracer/src/tuple"
+)
+
+//bumpDelta is the distance between the height samples used to find the
+//slope of a bump pattern
+const bumpDelta = 0.001
+
+//ShadingNormal returns the normal used for shading a point on an object,
+//which is the geometric normal perturbed by the material's bump pattern and
+//normal map, if it has them
+func (m *Material) ShadingNormal(object pattern.Object, point tuple.Tuple, normal tuple.Tuple) tuple.Tuple {
+	if m.Bump == nil && m.NormalMap == nil {
+		return normal
+	}
+	t, b := tangents(normal)
+	n := normal
+	if m.NormalMap != nil {
+		// map colors in [0,1] to tangent space directions in [-1,1]
+		c := pattern.AtObject(m.NormalMap, object, point)
+		x, y, z := 2*c.R-1, 2*c.G-1, 2*c.B-1
+		n = t.Multiply(x)
+		n = n.Add(b.Multiply(y))
+		n = n.Add(normal.Multiply(z))
+		n = n.Normalize()
+		t, b = tangents(n)
+	}
+	if m.Bump != nil {
+		dt := m.slope(object, point, t)
+		db := m.slope(object, point, b)
+		d := t.Multiply(dt)
+		d = d.Add(b.Multiply(db))
+		n = n.Subtract(d.Multiply(m.bumpScale()))
+		n = n.Normalize()
+	}
+	return n
+}
+
+func (m *Material) bumpScale() float64 {
+	if m.BumpScale == 0 {
+		return 1
+	}
+	return m.BumpScale
+}
+
+//slope returns the rate of change of the bump height along direction d
+func (m *Material) slope(object pattern.Object, point tuple.Tuple, d tuple.Tuple) float64 {
+	offset := d.Multiply(bumpDelta)
+	ahead := point.Add(offset)
+	behind := point.Subtract(offset)
+	return (height(m.Bump, object, ahead) - height(m.Bump, object, behind)) / (2 * bumpDelta)
+}
+
+//height returns the brightness of a bump pattern at a point
+func height(p pattern.Pattern, object pattern.Object, point tuple.Tuple) float64 {
+	return luminance(*pattern.AtObject(p, object, point))
+}
+
+//tangents returns two unit vectors perpendicular to n and each other. The
+//first runs around the y axis, or along x where n is nearly vertical.
+func tangents(n tuple.Tuple) (tuple.Tuple, tuple.Tuple) {
+	axis := tuple.Vector(0, 1, 0)
+	if math.Abs(n.Y) > 0.999 {
+		axis = tuple.Vector(0, 0, -1)
+	}
+	t := axis.CrossProduct(n)
+	t = t.Normalize()
+	b := n.CrossProduct(t)
+	return t, b
+}
+
+func luminance(c color.Color) float64 {
+	return 0.2126*c.R + 0.7152*c.G + 0.0722*c.B
+}
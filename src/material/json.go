@@ -0,0 +1,147 @@
+package material
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/pattern"
+)
+
+//jsonMaterial is the encoded form of a material. Patterns are encoded with
+//pattern.Marshal and left out when not set.
+type jsonMaterial struct {
+	Color         color.Color     `json:"color"`
+	Ambient       float64         `json:"ambient"`
+	Diffuse       float64         `json:"diffuse"`
+	Specular      float64         `json:"specular"`
+	Shininess     float64         `json:"shininess"`
+	Pattern       json.RawMessage `json:"pattern,omitempty"`
+	Reflective    float64         `json:"reflective"`
+	Bump          json.RawMessage `json:"bump,omitempty"`
+	BumpScale     float64         `json:"bump_scale"`
+	NormalMap     json.RawMessage `json:"normal_map,omitempty"`
+	AmbientMap    json.RawMessage `json:"ambient_map,omitempty"`
+	DiffuseMap    json.RawMessage `json:"diffuse_map,omitempty"`
+	SpecularMap   json.RawMessage `json:"specular_map,omitempty"`
+	ShininessMap  json.RawMessage `json:"shininess_map,omitempty"`
+	ReflectiveMap json.RawMessage `json:"reflective_map,omitempty"`
+	Emission      color.Color     `json:"emission"`
+	LightSamples  int             `json:"light_samples"`
+	Model         Model           `json:"model"`
+	Metallic      float64         `json:"metallic"`
+	Roughness     float64         `json:"roughness"`
+	MetallicMap   json.RawMessage `json:"metallic_map,omitempty"`
+	RoughnessMap  json.RawMessage `json:"roughness_map,omitempty"`
+}
+
+//MarshalJSON encodes a material and its patterns
+func (m *Material) MarshalJSON() ([]byte, error) {
+	j := jsonMaterial{
+		Color:        m.Color,
+		Ambient:      m.Ambient,
+		Diffuse:      m.Diffuse,
+		Specular:     m.Specular,
+		Shininess:    m.Shininess,
+		Reflective:   m.Reflective,
+		BumpScale:    m.BumpScale,
+		Emission:     m.Emission,
+		LightSamples: m.LightSamples,
+		Model:        m.Model,
+		Metallic:     m.Metallic,
+		Roughness:    m.Roughness,
+	}
+	var p pattern.Pattern
+	if m.hasPattern {
+		p = *m.Pattern
+	}
+	patterns := []struct {
+		p   pattern.Pattern
+		raw *json.RawMessage
+	}{
+		{p, &j.Pattern}, {m.Bump, &j.Bump}, {m.NormalMap, &j.NormalMap},
+		{m.AmbientMap, &j.AmbientMap}, {m.DiffuseMap, &j.DiffuseMap},
+		{m.SpecularMap, &j.SpecularMap}, {m.ShininessMap, &j.ShininessMap},
+		{m.ReflectiveMap, &j.ReflectiveMap}, {m.MetallicMap, &j.MetallicMap},
+		{m.RoughnessMap, &j.RoughnessMap},
+	}
+	for _, f := range patterns {
+		if f.p == nil {
+			continue
+		}
+		data, err := pattern.Marshal(f.p)
+		if err != nil {
+			return nil, err
+		}
+		*f.raw = data
+	}
+	return json.Marshal(j)
+}
+
+//UnmarshalJSON decodes a material written by MarshalJSON
+func (m *Material) UnmarshalJSON(data []byte) error {
+	var j jsonMaterial
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*m = Material{
+		Color:        j.Color,
+		Ambient:      j.Ambient,
+		Diffuse:      j.Diffuse,
+		Specular:     j.Specular,
+		Shininess:    j.Shininess,
+		Reflective:   j.Reflective,
+		BumpScale:    j.BumpScale,
+		Emission:     j.Emission,
+		LightSamples: j.LightSamples,
+		Model:        j.Model,
+		Metallic:     j.Metallic,
+		Roughness:    j.Roughness,
+	}
+	var p pattern.Pattern
+	patterns := []struct {
+		raw json.RawMessage
+		p   *pattern.Pattern
+	}{
+		{j.Pattern, &p}, {j.Bump, &m.Bump}, {j.NormalMap, &m.NormalMap},
+		{j.AmbientMap, &m.AmbientMap}, {j.DiffuseMap, &m.DiffuseMap},
+		{j.SpecularMap, &m.SpecularMap}, {j.ShininessMap, &m.ShininessMap},
+		{j.ReflectiveMap, &m.ReflectiveMap}, {j.MetallicMap, &m.MetallicMap},
+		{j.RoughnessMap, &m.RoughnessMap},
+	}
+	for _, f := range patterns {
+		if f.raw == nil {
+			continue
+		}
+		var err error
+		if *f.p, err = pattern.Unmarshal(f.raw); err != nil {
+			return err
+		}
+	}
+	if p != nil {
+		m.SetPattern(p)
+	}
+	return nil
+}
+
+var models = map[string]Model{"phong": Phong, "metallic-roughness": MetallicRoughness}
+
+//MarshalText encodes a reflection model by name
+func (model Model) MarshalText() ([]byte, error) {
+	for name, m := range models {
+		if m == model {
+			return []byte(name), nil
+		}
+	}
+	return nil, fmt.Errorf("material: unknown model %d", model)
+}
+
+//UnmarshalText decodes a reflection model by name
+func (model *Model) UnmarshalText(text []byte) error {
+	m, ok := models[string(text)]
+	if !ok {
+		return fmt.Errorf("material: unknown model %q", text)
+	}
+	*model = m
+	return nil
+}
@@ -0,0 +1,39 @@
+package material
+
+import (
+	"github.com/calbim/ray-tracer/src/pattern"
+	"github.com/calbim/ray-tracer/src/tuple"
+)
+
+//AmbientAt returns the ambient reflection of the material at a point on an object
+func (m *Material) AmbientAt(object pattern.Object, point tuple.Tuple) float64 {
+	return scalarAt(m.Ambient, m.AmbientMap, object, point)
+}
+
+//DiffuseAt returns the diffuse reflection of the material at a point on an object
+func (m *Material) DiffuseAt(object pattern.Object, point tuple.Tuple) float64 {
+	return scalarAt(m.Diffuse, m.DiffuseMap, object, point)
+}
+
+//SpecularAt returns the specular reflection of the material at a point on an object
+func (m *Material) SpecularAt(object pattern.Object, point tuple.Tuple) float64 {
+	return scalarAt(m.Specular, m.SpecularMap, object, point)
+}
+
+//ShininessAt returns the shininess of the material at a point on an object
+func (m *Material) ShininessAt(object pattern.Object, point tuple.Tuple) float64 {
+	return scalarAt(m.Shininess, m.ShininessMap, object, point)
+}
+
+//ReflectiveAt returns the reflectivity of the material at a point on an object
+func (m *Material) ReflectiveAt(object pattern.Object, point tuple.Tuple) float64 {
+	return scalarAt(m.Reflective, m.ReflectiveMap, object, point)
+}
+
+//scalarAt scales value by the brightness of map p at a point, if there is a map
+func scalarAt(value float64, p pattern.Pattern, object pattern.Object, point tuple.Tuple) float64 {
+	if p == nil {
+		return value
+	}
+	return value * luminance(*pattern.AtObject(p, object, point))
+}
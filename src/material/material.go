@@ -21,6 +21,30 @@ type Material struct {
 	Pattern    *pattern.Pattern
 	hasPattern bool
 	Reflective float64
+	Bump       pattern.Pattern //heights, taken from the pattern's brightness
+	BumpScale  float64         //how strongly the bump pattern tilts the normal, 1 if 0
+	NormalMap  pattern.Pattern //tangent space normals, x, y and z as r, g and b
+	//Maps optionally vary the scalar properties over the surface. The
+	//brightness of a map at a point scales the corresponding property.
+	AmbientMap    pattern.Pattern
+	DiffuseMap    pattern.Pattern
+	SpecularMap   pattern.Pattern
+	ShininessMap  pattern.Pattern
+	ReflectiveMap pattern.Pattern
+	//Emission is light given off by the material, added to its shade
+	//whether or not it is lit. If LightSamples is more than 0, emissive
+	//shapes that can be sampled also light other objects, using that many
+	//points on their surface.
+	Emission     color.Color
+	LightSamples int
+	//Model selects the reflection model, Phong by default. The metallic
+	//roughness model uses Metallic and Roughness in place of Diffuse,
+	//Specular and Shininess.
+	Model        Model
+	Metallic     float64
+	Roughness    float64
+	MetallicMap  pattern.Pattern
+	RoughnessMap pattern.Pattern
 }
 
 //New returns a default material
@@ -40,29 +64,48 @@ func (m *Material) SetPattern(p pattern.Pattern) {
 	m.hasPattern = true
 }
 
-//Lighting returns the shade of an object under various light properties
-func (m *Material) Lighting(object pattern.Object, light light.Light, point tuple.Tuple, eyev tuple.Tuple, normalv tuple.Tuple, inShadow bool) color.Color {
-	c := m.Color
+//ColorAt returns the unlit color of the material at a point on an object
+func (m *Material) ColorAt(object pattern.Object, point tuple.Tuple) color.Color {
 	if m.hasPattern {
-		tmp := pattern.AtObject(*m.Pattern, object, point)
-		c = *tmp
+		return *pattern.AtObject(*m.Pattern, object, point)
+	}
+	return m.Color
+}
+
+//Lighting returns the shade of an object under various light properties.
+//Patterns are looked up in the object space of object, usually the shape hit.
+func (m *Material) Lighting(object pattern.Object, light light.Light, point tuple.Tuple, eyev tuple.Tuple, normalv tuple.Tuple, inShadow bool) color.Color {
+	c := m.ColorAt(object, point)
+	effectiveColor := c.MultiplyColor(light.Intensity)
+	ambient := effectiveColor.Multiply(m.AmbientAt(object, point))
+	sum := ambient.Add(m.Emission)
+	if inShadow {
+		return sum
+	}
+	return sum.Add(m.DirectLighting(object, light, point, eyev, normalv))
+}
+
+//DirectLighting returns the diffuse and specular light reflected by an
+//object from a light that is not in shadow
+func (m *Material) DirectLighting(object pattern.Object, light light.Light, point tuple.Tuple, eyev tuple.Tuple, normalv tuple.Tuple) color.Color {
+	if m.Model == MetallicRoughness {
+		return m.cookTorrance(object, light, point, eyev, normalv)
 	}
+	c := m.ColorAt(object, point)
 	effectiveColor := c.MultiplyColor(light.Intensity)
 	lightv := light.Position.Subtract(point)
 	lightv = lightv.Normalize()
-	ambient := effectiveColor.Multiply(m.Ambient)
 	lightDotNormal := lightv.DotProduct(normalv)
 	diffuse, specular := color.Black, color.Black
-	if lightDotNormal >= 0 && !inShadow {
-		diffuse = effectiveColor.Multiply(m.Diffuse * lightDotNormal)
+	if lightDotNormal >= 0 {
+		diffuse = effectiveColor.Multiply(m.DiffuseAt(object, point) * lightDotNormal)
 		reflectv := lightv.Negate()
 		reflectv = reflectv.Reflect(normalv)
 		reflectDotEye := reflectv.DotProduct(eyev)
 		if reflectDotEye > 0 {
-			factor := math.Pow(reflectDotEye, m.Shininess)
-			specular = light.Intensity.Multiply(m.Specular * factor)
+			factor := math.Pow(reflectDotEye, m.ShininessAt(object, point))
+			specular = light.Intensity.Multiply(m.SpecularAt(object, point) * factor)
 		}
 	}
-	sum := diffuse.Add(specular)
-	return sum.Add(ambient)
+	return diffuse.Add(specular)
 }
@@ -1,10 +1,13 @@
-package material
+package material_test
 
 import (
 	"math"
 	"testing"
 
+	"github.com/calbim/ray-tracer/src/material"
 	"github.com/calbim/ray-tracer/src/pattern"
+	"github.com/calbim/ray-tracer/src/shape"
+	"github.com/calbim/ray-tracer/src/transforms"
 	"github.com/calbim/ray-tracer/src/tuple"
 
 	"github.com/calbim/ray-tracer/src/color"
@@ -12,7 +15,7 @@ import (
 )
 
 func TestMaterial(t *testing.T) {
-	m := New()
+	m := material.New()
 	if !m.Color.Equals(color.New(1, 1, 1)) {
 		t.Errorf("wanted color=%v, should be %v", color.New(1, 1, 1), m.Color)
 	}
@@ -31,68 +34,68 @@ func TestMaterial(t *testing.T) {
 }
 
 func TestLightingEyeBetweenLightAndSurface(t *testing.T) {
-	m := New()
+	m := material.New()
 	position := tuple.Point(0, 0, 0)
 	light := light.PointLight(tuple.Point(0, 0, -10), color.White)
 	eyev := tuple.Vector(0, 0, -1)
 	normalv := tuple.Vector(0, 0, -1)
-	result := m.Lighting(pattern.NewObject(), light, position, eyev, normalv, false)
+	result := m.Lighting(shape.NewSphere(), light, position, eyev, normalv, false)
 	if !result.Equals(color.New(1.9, 1.9, 1.9)) {
 		t.Errorf("wanted lighting=%v, got %v", color.New(1.9, 1.9, 1.9), result)
 	}
 }
 
 func TestLightingEyeOffset45BetweenLightAndSurface(t *testing.T) {
-	m := New()
+	m := material.New()
 	position := tuple.Point(0, 0, 0)
 	light := light.PointLight(tuple.Point(0, 0, -10), color.White)
 	eyev := tuple.Vector(0, math.Sqrt(2)/2, -math.Sqrt(2)/2)
 	normalv := tuple.Vector(0, 0, -1)
-	result := m.Lighting(pattern.NewObject(), light, position, eyev, normalv, false)
+	result := m.Lighting(shape.NewSphere(), light, position, eyev, normalv, false)
 	if !result.Equals(color.White) {
 		t.Errorf("wanted lighting=%v, got %v", color.White, result)
 	}
 }
 
 func TestLightingEyeInPathOfReflectionVector(t *testing.T) {
-	m := New()
+	m := material.New()
 	position := tuple.Point(0, 0, 0)
 	light := light.PointLight(tuple.Point(0, 10, -10), color.New(1, 1, 1))
 	eyev := tuple.Vector(0, -math.Sqrt(2)/2, -math.Sqrt(2)/2)
 	normalv := tuple.Vector(0, 0, -1)
-	result := m.Lighting(pattern.NewObject(), light, position, eyev, normalv, false)
+	result := m.Lighting(shape.NewSphere(), light, position, eyev, normalv, false)
 	if !result.Equals(color.New(1.6364, 1.6364, 1.6364)) {
 		t.Errorf("wanted lighting=%v, got %v", color.New(1.6364, 1.6364, 1.6364), result)
 	}
 }
 
 func TestLightingBehindSurface(t *testing.T) {
-	m := New()
+	m := material.New()
 	position := tuple.Point(0, 0, 0)
 	light := light.PointLight(tuple.Point(0, 0, 10), color.New(1, 1, 1))
 	eyev := tuple.Vector(0, 0, -1)
 	normalv := tuple.Vector(0, 0, -1)
-	result := m.Lighting(pattern.NewObject(), light, position, eyev, normalv, false)
+	result := m.Lighting(shape.NewSphere(), light, position, eyev, normalv, false)
 	if !result.Equals(color.New(0.1, 0.1, 0.1)) {
 		t.Errorf("wanted lighting=%v, got %v", color.New(0.1, 0.1, 0.1), result)
 	}
 }
 
 func TestLightingSurfaceInShadow(t *testing.T) {
-	m := New()
+	m := material.New()
 	position := tuple.Point(0, 0, 0)
 	eyev := tuple.Vector(0, 0, -1)
 	normalv := tuple.Vector(0, 0, -1)
 	light := light.PointLight(tuple.Point(0, 0, -10), color.New(1, 1, 1))
 	inShadow := true
-	result := m.Lighting(pattern.NewObject(), light, position, eyev, normalv, inShadow)
+	result := m.Lighting(shape.NewSphere(), light, position, eyev, normalv, inShadow)
 	if !result.Equals(color.New(0.1, 0.1, 0.1)) {
 		t.Errorf("wanted lighting=%v, got %v", result, color.New(0.1, 0.1, 0.1))
 	}
 }
 
 func TestLightingWithPattern(t *testing.T) {
-	m := New()
+	m := material.New()
 	m.SetPattern(pattern.NewStripe(color.White, color.Black))
 	m.Ambient = 1
 	m.Diffuse = 0
@@ -100,8 +103,8 @@ func TestLightingWithPattern(t *testing.T) {
 	eyev := tuple.Vector(0, 0, -1)
 	normalv := tuple.Vector(0, 0, -1)
 	l := light.PointLight(tuple.Point(0, 0, -10), color.White)
-	c1 := m.Lighting(pattern.NewObject(), l, tuple.Point(0.9, 0, 0), eyev, normalv, false)
-	c2 := m.Lighting(pattern.NewObject(), l, tuple.Point(1.1, 0, 0), eyev, normalv, false)
+	c1 := m.Lighting(shape.NewSphere(), l, tuple.Point(0.9, 0, 0), eyev, normalv, false)
+	c2 := m.Lighting(shape.NewSphere(), l, tuple.Point(1.1, 0, 0), eyev, normalv, false)
 	if !c1.Equals(color.White) {
 		t.Errorf("wanted c1=%v, got %v", color.White, c1)
 	}
@@ -111,7 +114,7 @@ func TestLightingWithPattern(t *testing.T) {
 }
 
 func TestLightingWithPatternApplied(t *testing.T) {
-	m := New()
+	m := material.New()
 	m.SetPattern(pattern.NewStripe(color.White, color.Black))
 	m.Ambient = 1
 	m.Diffuse = 0
@@ -119,8 +122,8 @@ func TestLightingWithPatternApplied(t *testing.T) {
 	eyev := tuple.Vector(0, 0, -1)
 	normalv := tuple.Vector(0, 0, -1)
 	l := light.PointLight(tuple.Point(0, 0, -10), color.White)
-	c1 := m.Lighting(pattern.NewObject(), l, tuple.Point(0.9, 0, 0), eyev, normalv, false)
-	c2 := m.Lighting(pattern.NewObject(), l, tuple.Point(1.1, 0, 0), eyev, normalv, false)
+	c1 := m.Lighting(shape.NewSphere(), l, tuple.Point(0.9, 0, 0), eyev, normalv, false)
+	c2 := m.Lighting(shape.NewSphere(), l, tuple.Point(1.1, 0, 0), eyev, normalv, false)
 	if !c1.Equals(color.White) {
 		t.Errorf("wanted c1=%v, got %v", color.White, c1)
 	}
@@ -130,8 +133,185 @@ func TestLightingWithPatternApplied(t *testing.T) {
 }
 
 func TestReflectivityForDefaultMaterial(t *testing.T) {
-	m := New()
+	m := material.New()
 	if m.Reflective != 0.0 {
 		t.Errorf("wanted default reflectivity=%v, got %v", m.Reflective, 0.0)
 	}
 }
+
+func TestShadingNormalWithoutBumpIsGeometricNormal(t *testing.T) {
+	m := material.New()
+	n := tuple.Vector(0, 0, 1)
+	if got := m.ShadingNormal(shape.NewSphere(), tuple.Point(0.5, 0, 0), n); !got.Equals(n) {
+		t.Errorf("wanted shading normal=%v, got %v", n, got)
+	}
+	m.Bump = pattern.NewSolid(color.White)
+	if got := m.ShadingNormal(shape.NewSphere(), tuple.Point(0.5, 0, 0), n); !got.Equals(n) {
+		t.Errorf("wanted a flat bump pattern to keep normal=%v, got %v", n, got)
+	}
+}
+
+func TestBumpTiltsNormalDownSlope(t *testing.T) {
+	m := material.New()
+	m.Bump = pattern.NewGradient(color.Black, color.White)
+	n := m.ShadingNormal(shape.NewSphere(), tuple.Point(0.5, 0, 0), tuple.Vector(0, 0, 1))
+	want := tuple.Vector(-math.Sqrt2/2, 0, math.Sqrt2/2)
+	if !n.Equals(want) {
+		t.Errorf("wanted shading normal=%v, got %v", want, n)
+	}
+	m.BumpScale = 0.5
+	n = m.ShadingNormal(shape.NewSphere(), tuple.Point(0.5, 0, 0), tuple.Vector(0, 0, 1))
+	want = tuple.Vector(-1/math.Sqrt(5), 0, 2/math.Sqrt(5))
+	if !n.Equals(want) {
+		t.Errorf("wanted shading normal=%v, got %v", want, n)
+	}
+}
+
+func TestBumpUsesObjectSpace(t *testing.T) {
+	m := material.New()
+	m.Bump = pattern.NewGradient(color.Black, color.White)
+	o := shape.NewSphere()
+	o.Transform = transforms.Scaling(2, 2, 2)
+	n := m.ShadingNormal(o, tuple.Point(1, 0, 0), tuple.Vector(0, 0, 1))
+	want := tuple.Vector(-1/math.Sqrt(5), 0, 2/math.Sqrt(5))
+	if !n.Equals(want) {
+		t.Errorf("wanted shading normal=%v, got %v", want, n)
+	}
+}
+
+func TestNormalMap(t *testing.T) {
+	tests := []struct {
+		c    color.Color
+		want tuple.Tuple
+	}{
+		{color.New(0.5, 0.5, 1), tuple.Vector(0, 0, 1)},
+		{color.New(1, 0.5, 0.5), tuple.Vector(1, 0, 0)},
+		{color.New(0.5, 1, 0.5), tuple.Vector(0, 1, 0)},
+		{color.New(0, 0.5, 1), tuple.Vector(-math.Sqrt2/2, 0, math.Sqrt2/2)},
+	}
+	for _, test := range tests {
+		m := material.New()
+		m.NormalMap = pattern.NewSolid(test.c)
+		n := m.ShadingNormal(shape.NewSphere(), tuple.Point(0, 0, 0), tuple.Vector(0, 0, 1))
+		if !n.Equals(test.want) {
+			t.Errorf("wanted normal map color %v to give normal=%v, got %v", test.c, test.want, n)
+		}
+	}
+}
+
+func TestLightingWithScalarMaps(t *testing.T) {
+	l := light.PointLight(tuple.Point(0, 0, -10), color.White)
+	eyev := tuple.Vector(0, 0, -1)
+	normalv := tuple.Vector(0, 0, -1)
+	tests := []struct {
+		setMap func(m *material.Material)
+		want   color.Color
+	}{
+		{func(m *material.Material) {}, color.New(1.9, 1.9, 1.9)},
+		{func(m *material.Material) { m.SpecularMap = pattern.NewSolid(color.Black) }, color.New(1, 1, 1)},
+		{func(m *material.Material) { m.DiffuseMap = pattern.NewSolid(color.New(0.5, 0.5, 0.5)) }, color.New(1.45, 1.45, 1.45)},
+		{func(m *material.Material) { m.AmbientMap = pattern.NewStripe(color.Black, color.White) }, color.New(1.8, 1.8, 1.8)},
+	}
+	for i, test := range tests {
+		m := material.New()
+		test.setMap(&m)
+		result := m.Lighting(shape.NewSphere(), l, tuple.Point(0, 0, 0), eyev, normalv, false)
+		if !result.Equals(test.want) {
+			t.Errorf("wanted lighting %v=%v, got %v", i, test.want, result)
+		}
+	}
+}
+
+func TestScalarMapsUseObjectSpace(t *testing.T) {
+	m := material.New()
+	m.ShininessMap = pattern.NewGradient(color.Black, color.White)
+	o := shape.NewSphere()
+	o.Transform = transforms.Scaling(2, 2, 2)
+	if got := m.ShininessAt(o, tuple.Point(0.5, 0, 0)); math.Abs(got-50) > 1e-9 {
+		t.Errorf("wanted shininess=%v, got %v", 50, got)
+	}
+	if got := m.ReflectiveAt(o, tuple.Point(0.5, 0, 0)); got != 0 {
+		t.Errorf("wanted reflective=%v, got %v", 0, got)
+	}
+}
+
+func TestLightingWithEmissionIgnoresShadow(t *testing.T) {
+	m := material.New()
+	m.Emission = color.New(0.5, 0.25, 0)
+	l := light.PointLight(tuple.Point(0, 0, -10), color.White)
+	eyev := tuple.Vector(0, 0, -1)
+	normalv := tuple.Vector(0, 0, -1)
+	result := m.Lighting(shape.NewSphere(), l, tuple.Point(0, 0, 0), eyev, normalv, true)
+	if !result.Equals(color.New(0.6, 0.35, 0.1)) {
+		t.Errorf("wanted lighting=%v, got %v", color.New(0.6, 0.35, 0.1), result)
+	}
+	result = m.Lighting(shape.NewSphere(), l, tuple.Point(0, 0, 0), eyev, normalv, false)
+	if !result.Equals(color.New(2.4, 2.15, 1.9)) {
+		t.Errorf("wanted lighting=%v, got %v", color.New(2.4, 2.15, 1.9), result)
+	}
+}
+
+func TestMetallicRoughnessDielectricHeadOn(t *testing.T) {
+	m := material.New()
+	m.Model = material.MetallicRoughness
+	m.Roughness = 1
+	l := light.PointLight(tuple.Point(0, 0, -10), color.White)
+	eyev := tuple.Vector(0, 0, -1)
+	normalv := tuple.Vector(0, 0, -1)
+	result := m.DirectLighting(shape.NewSphere(), l, tuple.Point(0, 0, 0), eyev, normalv)
+	if !result.Equals(color.New(0.97, 0.97, 0.97)) {
+		t.Errorf("wanted lighting=%v, got %v", color.New(0.97, 0.97, 0.97), result)
+	}
+	result = m.Lighting(shape.NewSphere(), l, tuple.Point(0, 0, 0), eyev, normalv, true)
+	if !result.Equals(color.New(0.1, 0.1, 0.1)) {
+		t.Errorf("wanted shadowed lighting=%v, got %v", color.New(0.1, 0.1, 0.1), result)
+	}
+}
+
+func TestMetallicRoughnessMetalTintsReflection(t *testing.T) {
+	m := material.New()
+	m.Model = material.MetallicRoughness
+	m.Color = color.New(1, 0.5, 0)
+	m.Metallic = 1
+	m.Roughness = 1
+	l := light.PointLight(tuple.Point(0, 0, -10), color.White)
+	eyev := tuple.Vector(0, 0, -1)
+	normalv := tuple.Vector(0, 0, -1)
+	result := m.DirectLighting(shape.NewSphere(), l, tuple.Point(0, 0, 0), eyev, normalv)
+	if !result.Equals(color.New(0.25, 0.125, 0)) {
+		t.Errorf("wanted lighting=%v, got %v", color.New(0.25, 0.125, 0), result)
+	}
+}
+
+func TestMetallicRoughnessHighlightSharpensWhenSmooth(t *testing.T) {
+	l := light.PointLight(tuple.Point(0, 10, -10), color.White)
+	normalv := tuple.Vector(0, 1, 0)
+	mirror := tuple.Vector(0, math.Sqrt2/2, math.Sqrt2/2)
+	away := tuple.Vector(0, math.Sqrt2/2, -math.Sqrt2/2)
+	previous := 0.0
+	for _, roughness := range []float64{0.7, 0.4, 0.2} {
+		m := material.New()
+		m.Model = material.MetallicRoughness
+		m.Metallic = 1
+		m.Roughness = roughness
+		peak := m.DirectLighting(shape.NewSphere(), l, tuple.Point(0, 0, 0), mirror, normalv)
+		off := m.DirectLighting(shape.NewSphere(), l, tuple.Point(0, 0, 0), away, normalv)
+		if peak.R <= previous {
+			t.Errorf("wanted highlight at roughness %v brighter than %v, got %v", roughness, previous, peak.R)
+		}
+		if off.R >= peak.R {
+			t.Errorf("wanted highlight at roughness %v to peak in the mirror direction, got %v off peak and %v on", roughness, off.R, peak.R)
+		}
+		previous = peak.R
+	}
+}
+
+func TestMetallicRoughnessUnlitFromBehind(t *testing.T) {
+	m := material.New()
+	m.Model = material.MetallicRoughness
+	l := light.PointLight(tuple.Point(0, 0, 10), color.White)
+	result := m.DirectLighting(shape.NewSphere(), l, tuple.Point(0, 0, 0), tuple.Vector(0, 0, -1), tuple.Vector(0, 0, -1))
+	if !result.Equals(color.Black) {
+		t.Errorf("wanted lighting=%v, got %v", color.Black, result)
+	}
+}
@@ -0,0 +1,98 @@
+package material
+
+import (
+	"math"
+
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/light"
+	"github.com/calbim/ray-tracer/src/pattern"
+	"github.com/calbim/ray-tracer/src/tuple"
+)
+
+//Model is the reflection model a material is shaded with
+type Model int
+
+//Reflection models
+const (
+	Phong             Model = iota //classic Phong with ambient, diffuse and specular terms
+	MetallicRoughness              //physically based GGX microfacets with Cook-Torrance specular
+)
+
+//minRoughness keeps perfectly smooth surfaces from giving an infinitely
+//bright highlight
+const minRoughness = 0.04
+
+//MetallicAt returns the metalness of the material at a point on an object
+func (m *Material) MetallicAt(object pattern.Object, point tuple.Tuple) float64 {
+	return scalarAt(m.Metallic, m.MetallicMap, object, point)
+}
+
+//RoughnessAt returns the roughness of the material at a point on an object
+func (m *Material) RoughnessAt(object pattern.Object, point tuple.Tuple) float64 {
+	return scalarAt(m.Roughness, m.RoughnessMap, object, point)
+}
+
+//cookTorrance returns the light reflected by a metallic roughness material.
+//Light intensity is taken as the irradiance delivered head on, so a rough
+//white dielectric lit head on reflects about its base color, as it would
+//with Phong and a diffuse of 1.
+func (m *Material) cookTorrance(object pattern.Object, light light.Light, point tuple.Tuple, eyev tuple.Tuple, normalv tuple.Tuple) color.Color {
+	lightv := light.Position.Subtract(point)
+	lightv = lightv.Normalize()
+	nDotL := normalv.DotProduct(lightv)
+	nDotV := normalv.DotProduct(eyev)
+	if nDotL <= 0 || nDotV <= 0 {
+		return color.Black
+	}
+	halfway := lightv.Add(eyev)
+	halfway = halfway.Normalize()
+	nDotH := math.Max(normalv.DotProduct(halfway), 0)
+	vDotH := math.Max(eyev.DotProduct(halfway), 0)
+
+	base := m.ColorAt(object, point)
+	metallic := math.Max(0, math.Min(1, m.MetallicAt(object, point)))
+	roughness := math.Max(minRoughness, math.Min(1, m.RoughnessAt(object, point)))
+
+	// dielectrics reflect about 4% head on, metals reflect their base color
+	dielectric := color.New(0.04, 0.04, 0.04)
+	f0 := lerp(dielectric, base, metallic)
+	fresnel := schlick(f0, vDotH)
+
+	d := ggx(nDotH, roughness)
+	g := smith(nDotV, roughness) * smith(nDotL, roughness)
+	specular := fresnel.Multiply(d * g / (4 * nDotL * nDotV))
+
+	// whatever is not reflected at the surface is diffused, except by metals
+	kd := color.White.Subtract(fresnel)
+	kd = kd.Multiply(1 - metallic)
+	diffuse := kd.MultiplyColor(base)
+	diffuse = diffuse.Multiply(1 / math.Pi)
+
+	brdf := diffuse.Add(specular)
+	radiance := light.Intensity.Multiply(math.Pi * nDotL)
+	return brdf.MultiplyColor(radiance)
+}
+
+//ggx is the Trowbridge-Reitz normal distribution function
+func ggx(nDotH, roughness float64) float64 {
+	a2 := math.Pow(roughness, 4)
+	denom := nDotH*nDotH*(a2-1) + 1
+	return a2 / (math.Pi * denom * denom)
+}
+
+//smith is the Schlick-GGX geometry term for one direction
+func smith(nDotX, roughness float64) float64 {
+	k := (roughness + 1) * (roughness + 1) / 8
+	return nDotX / (nDotX*(1-k) + k)
+}
+
+//schlick is Schlick's approximation of Fresnel reflectance
+func schlick(f0 color.Color, cosTheta float64) color.Color {
+	rest := color.White.Subtract(f0)
+	return f0.Add(rest.Multiply(math.Pow(1-cosTheta, 5)))
+}
+
+func lerp(a, b color.Color, t float64) color.Color {
+	diff := b.Subtract(a)
+	return a.Add(diff.Multiply(t))
+}
@@ -1,7 +1,9 @@
 package matrix
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"math"
 
 	"github.com/calbim/ray-tracer/src/tuple"
@@ -175,3 +177,28 @@ func (m *Matrix) Inverse() (*Matrix, error) {
 	inv := New(b)
 	return inv, nil
 }
+
+// MarshalJSON encodes a matrix as an array of its rows
+func (m *Matrix) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Values)
+}
+
+// UnmarshalJSON decodes a matrix from an array of four rows of four values,
+// the only size a transform can be
+func (m *Matrix) UnmarshalJSON(data []byte) error {
+	var rows [][]float64
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return err
+	}
+	if len(rows) != 4 {
+		return fmt.Errorf("matrix has %d rows, not 4", len(rows))
+	}
+	for _, row := range rows {
+		if len(row) != 4 {
+			return fmt.Errorf("matrix row has %d values, not 4", len(row))
+		}
+	}
+	m.N = len(rows)
+	m.Values = rows
+	return nil
+}
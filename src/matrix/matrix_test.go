@@ -1,6 +1,7 @@
 package matrix
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/calbim/ray-tracer/src/tuple"
@@ -245,3 +246,23 @@ func TestInverse(t *testing.T) {
 		t.Errorf("wanted inverse=%v, got %v", expected, b)
 	}
 }
+
+func TestMatrixJSON(t *testing.T) {
+	m := New([]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Matrix
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equals(m) {
+		t.Errorf("wanted matrix=%v, got %v", m, got)
+	}
+	for _, bad := range []string{`[]`, `[[1,0],[0,1]]`, `[[1,0,0],[0,1,0],[0,0,1]]`, `[[1,0,0,0],[0,1,0,0],[0,0,1,0],[0,0,1]]`} {
+		if err := json.Unmarshal([]byte(bad), &got); err == nil {
+			t.Errorf("wanted an error for %s", bad)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package noise
+
+import "math"
+
+// Func is a 3D noise function returning values roughly in [-1,1]
+type Func func(x, y, z float64) float64
+
+// perm is Ken Perlin's reference permutation, repeated to avoid wrapping
+var perm [512]int
+
+var permutation = [256]int{
+	151, 160, 137, 91, 90, 15, 131, 13, 201, 95, 96, 53, 194, 233, 7, 225,
+	140, 36, 103, 30, 69, 142, 8, 99, 37, 240, 21, 10, 23, 190, 6, 148,
+	247, 120, 234, 75, 0, 26, 197, 62, 94, 252, 219, 203, 117, 35, 11, 32,
+	57, 177, 33, 88, 237, 149, 56, 87, 174, 20, 125, 136, 171, 168, 68, 175,
+	74, 165, 71, 134, 139, 48, 27, 166, 77, 146, 158, 231, 83, 111, 229, 122,
+	60, 211, 133, 230, 220, 105, 92, 41, 55, 46, 245, 40, 244, 102, 143, 54,
+	65, 25, 63, 161, 1, 216, 80, 73, 209, 76, 132, 187, 208, 89, 18, 169,
+	200, 196, 135, 130, 116, 188, 159, 86, 164, 100, 109, 198, 173, 186, 3, 64,
+	52, 217, 226, 250, 124, 123, 5, 202, 38, 147, 118, 126, 255, 82, 85, 212,
+	207, 206, 59, 227, 47, 16, 58, 17, 182, 189, 28, 42, 223, 183, 170, 213,
+	119, 248, 152, 2, 44, 154, 163, 70, 221, 153, 101, 155, 167, 43, 172, 9,
+	129, 22, 39, 253, 19, 98, 108, 110, 79, 113, 224, 232, 178, 185, 112, 104,
+	218, 246, 97, 228, 251, 34, 242, 193, 238, 210, 144, 12, 191, 179, 162, 241,
+	81, 51, 145, 235, 249, 14, 239, 107, 49, 192, 214, 31, 181, 199, 106, 157,
+	184, 84, 204, 176, 115, 121, 50, 45, 127, 4, 150, 254, 138, 236, 205, 93,
+	222, 114, 67, 29, 24, 72, 243, 141, 128, 195, 78, 66, 215, 61, 156, 180,
+}
+
+// grad3 holds the gradient directions used by simplex noise
+var grad3 = [12][3]float64{
+	{1, 1, 0}, {-1, 1, 0}, {1, -1, 0}, {-1, -1, 0},
+	{1, 0, 1}, {-1, 0, 1}, {1, 0, -1}, {-1, 0, -1},
+	{0, 1, 1}, {0, -1, 1}, {0, 1, -1}, {0, -1, -1},
+}
+
+func init() {
+	for i := range perm {
+		perm[i] = permutation[i&255]
+	}
+}
+
+// Perlin returns Ken Perlin's improved gradient noise at (x,y,z). It is zero
+// at every integer lattice point.
+func Perlin(x, y, z float64) float64 {
+	fx, fy, fz := math.Floor(x), math.Floor(y), math.Floor(z)
+	xi, yi, zi := int(fx)&255, int(fy)&255, int(fz)&255
+	x, y, z = x-fx, y-fy, z-fz
+	u, v, w := fade(x), fade(y), fade(z)
+
+	a := perm[xi] + yi
+	aa, ab := perm[a]+zi, perm[a+1]+zi
+	b := perm[xi+1] + yi
+	ba, bb := perm[b]+zi, perm[b+1]+zi
+
+	return lerp(w,
+		lerp(v,
+			lerp(u, grad(perm[aa], x, y, z), grad(perm[ba], x-1, y, z)),
+			lerp(u, grad(perm[ab], x, y-1, z), grad(perm[bb], x-1, y-1, z))),
+		lerp(v,
+			lerp(u, grad(perm[aa+1], x, y, z-1), grad(perm[ba+1], x-1, y, z-1)),
+			lerp(u, grad(perm[ab+1], x, y-1, z-1), grad(perm[bb+1], x-1, y-1, z-1))))
+}
+
+// Simplex returns 3D simplex noise at (x,y,z), which has fewer directional
+// artifacts than Perlin noise and is cheaper to evaluate
+func Simplex(x, y, z float64) float64 {
+	const f3 = 1.0 / 3.0
+	const g3 = 1.0 / 6.0
+	s := (x + y + z) * f3
+	i, j, k := math.Floor(x+s), math.Floor(y+s), math.Floor(z+s)
+	t := (i + j + k) * g3
+	x0, y0, z0 := x-(i-t), y-(j-t), z-(k-t)
+
+	// find which of the six tetrahedra of the skewed cube the point lies in
+	var i1, j1, k1, i2, j2, k2 int
+	switch {
+	case x0 >= y0 && y0 >= z0:
+		i1, j1, k1, i2, j2, k2 = 1, 0, 0, 1, 1, 0
+	case x0 >= y0 && x0 >= z0:
+		i1, j1, k1, i2, j2, k2 = 1, 0, 0, 1, 0, 1
+	case x0 >= y0:
+		i1, j1, k1, i2, j2, k2 = 0, 0, 1, 1, 0, 1
+	case y0 < z0:
+		i1, j1, k1, i2, j2, k2 = 0, 0, 1, 0, 1, 1
+	case x0 < z0:
+		i1, j1, k1, i2, j2, k2 = 0, 1, 0, 0, 1, 1
+	default:
+		i1, j1, k1, i2, j2, k2 = 0, 1, 0, 1, 1, 0
+	}
+
+	corners := [4][3]float64{
+		{x0, y0, z0},
+		{x0 - float64(i1) + g3, y0 - float64(j1) + g3, z0 - float64(k1) + g3},
+		{x0 - float64(i2) + 2*g3, y0 - float64(j2) + 2*g3, z0 - float64(k2) + 2*g3},
+		{x0 - 1 + 3*g3, y0 - 1 + 3*g3, z0 - 1 + 3*g3},
+	}
+	ii, jj, kk := int(i)&255, int(j)&255, int(k)&255
+	offsets := [4][3]int{{0, 0, 0}, {i1, j1, k1}, {i2, j2, k2}, {1, 1, 1}}
+
+	n := 0.0
+	for c, p := range corners {
+		o := offsets[c]
+		t := 0.6 - p[0]*p[0] - p[1]*p[1] - p[2]*p[2]
+		if t < 0 {
+			continue
+		}
+		g := grad3[perm[ii+o[0]+perm[jj+o[1]+perm[kk+o[2]]]]%12]
+		t *= t
+		n += t * t * (g[0]*p[0] + g[1]*p[1] + g[2]*p[2])
+	}
+	return 32 * n
+}
+
+// FBM returns fractional Brownian motion: the sum of octaves of a noise
+// function, each at twice the frequency and half the amplitude of the
+// previous one, normalized to the range of the noise function
+func FBM(n Func, x, y, z float64, octaves int) float64 {
+	return fractal(n, x, y, z, octaves, func(v float64) float64 { return v })
+}
+
+// Turbulence is like FBM but sums the absolute value of each octave, giving
+// values in [0,1] with sharp creases where the noise crosses zero
+func Turbulence(n Func, x, y, z float64, octaves int) float64 {
+	return fractal(n, x, y, z, octaves, math.Abs)
+}
+
+// Worley returns cellular noise at (x,y,z): the distances to the nearest
+// and second nearest of a set of feature points scattered one per unit cell
+func Worley(x, y, z float64) (float64, float64) {
+	fx, fy, fz := math.Floor(x), math.Floor(y), math.Floor(z)
+	f1, f2 := math.Inf(1), math.Inf(1)
+	for i := -1.0; i <= 1; i++ {
+		for j := -1.0; j <= 1; j++ {
+			for k := -1.0; k <= 1; k++ {
+				cx, cy, cz := fx+i, fy+j, fz+k
+				h := hash(int(cx), int(cy), int(cz))
+				px := cx + float64(perm[h])/255
+				py := cy + float64(perm[h+1])/255
+				pz := cz + float64(perm[h+2])/255
+				d := math.Sqrt((x-px)*(x-px) + (y-py)*(y-py) + (z-pz)*(z-pz))
+				if d < f1 {
+					f1, f2 = d, f1
+				} else if d < f2 {
+					f2 = d
+				}
+			}
+		}
+	}
+	return f1, f2
+}
+
+func hash(x, y, z int) int {
+	return perm[perm[perm[x&255]+y&255]+z&255]
+}
+
+func fractal(n Func, x, y, z float64, octaves int, f func(float64) float64) float64 {
+	if octaves < 1 {
+		octaves = 1
+	}
+	sum, amplitude, total, frequency := 0.0, 1.0, 0.0, 1.0
+	for i := 0; i < octaves; i++ {
+		sum += amplitude * f(n(x*frequency, y*frequency, z*frequency))
+		total += amplitude
+		amplitude /= 2
+		frequency *= 2
+	}
+	return sum / total
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+func grad(hash int, x, y, z float64) float64 {
+	h := hash & 15
+	u := y
+	if h < 8 {
+		u = x
+	}
+	v := z
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
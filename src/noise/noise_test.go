@@ -0,0 +1,96 @@
+package noise
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPerlinIsZeroAtLatticePoints(t *testing.T) {
+	for _, p := range [][3]float64{{0, 0, 0}, {1, 2, 3}, {-4, 7, -1}} {
+		if n := Perlin(p[0], p[1], p[2]); n != 0 {
+			t.Errorf("wanted perlin noise at %v=%v, got %v", p, 0, n)
+		}
+	}
+}
+
+func TestNoiseIsDeterministic(t *testing.T) {
+	for _, f := range []Func{Perlin, Simplex} {
+		if f(0.3, 1.7, -2.2) != f(0.3, 1.7, -2.2) {
+			t.Errorf("wanted noise to return the same value for the same point")
+		}
+	}
+}
+
+func TestNoiseRange(t *testing.T) {
+	for name, f := range map[string]Func{"perlin": Perlin, "simplex": Simplex} {
+		min, max := math.Inf(1), math.Inf(-1)
+		for i := 0; i < 20000; i++ {
+			x := float64(i%41) * 0.173
+			y := float64(i%37) * 0.219
+			z := float64(i/41) * 0.031
+			n := f(x, y, z)
+			min = math.Min(min, n)
+			max = math.Max(max, n)
+		}
+		if min < -1.01 || max > 1.01 {
+			t.Errorf("wanted %v noise within [-1,1], got [%v,%v]", name, min, max)
+		}
+		if min > -0.3 || max < 0.3 {
+			t.Errorf("wanted %v noise to vary, got [%v,%v]", name, min, max)
+		}
+	}
+}
+
+func TestNoiseIsContinuous(t *testing.T) {
+	for _, f := range []Func{Perlin, Simplex} {
+		a := f(1.5, 2.5, 3.5)
+		b := f(1.5001, 2.5, 3.5)
+		if math.Abs(a-b) > 0.01 {
+			t.Errorf("wanted nearby points to have similar noise, got %v and %v", a, b)
+		}
+	}
+}
+
+func TestFBMWithOneOctaveIsNoise(t *testing.T) {
+	if FBM(Perlin, 0.4, 0.5, 0.6, 1) != Perlin(0.4, 0.5, 0.6) {
+		t.Errorf("wanted one octave of fBm to equal the noise")
+	}
+}
+
+func TestFBMAddsOctaves(t *testing.T) {
+	n := Perlin(0.4, 0.5, 0.6)
+	n2 := Perlin(0.8, 1.0, 1.2)
+	expected := (n + 0.5*n2) / 1.5
+	if f := FBM(Perlin, 0.4, 0.5, 0.6, 2); math.Abs(f-expected) > 1e-12 {
+		t.Errorf("wanted fbm=%v, got %v", expected, f)
+	}
+}
+
+func TestTurbulenceIsNonNegative(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		v := Turbulence(Simplex, float64(i)*0.37, float64(i)*0.11, 0.5, 4)
+		if v < 0 || v > 1 {
+			t.Errorf("wanted turbulence within [0,1], got %v", v)
+		}
+	}
+}
+
+func TestWorleyDistances(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		x, y, z := float64(i)*0.37, float64(i%13)*0.71, float64(i%7)*1.3
+		f1, f2 := Worley(x, y, z)
+		if f1 < 0 || f1 > f2 || f1 > math.Sqrt(3) {
+			t.Errorf("wanted 0 <= f1 <= f2 and f1 <= sqrt(3), got f1=%v f2=%v", f1, f2)
+		}
+	}
+}
+
+func TestWorleyIsZeroAtFeaturePoint(t *testing.T) {
+	h := hash(2, 3, 4)
+	x := 2 + float64(perm[h])/255
+	y := 3 + float64(perm[h+1])/255
+	z := 4 + float64(perm[h+2])/255
+	if f1, _ := Worley(x, y, z); f1 != 0 {
+		t.Errorf("wanted f1 at a feature point=%v, got %v", 0, f1)
+	}
+}
@@ -0,0 +1,129 @@
+package pattern
+
+import (
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/matrix"
+	"github.com/calbim/ray-tracer/src/tuple"
+)
+
+//Blend pattern is the weighted average of two patterns
+type Blend struct {
+	A         Pattern
+	B         Pattern
+	Weight    float64 //0 is all of A, 1 is all of B
+	Transform *matrix.Matrix
+	inverse
+}
+
+//Mask pattern chooses between two patterns using the brightness of a third,
+//showing A where the mask is black and B where it is white
+type Mask struct {
+	A         Pattern
+	B         Pattern
+	Mask      Pattern
+	Transform *matrix.Matrix
+	inverse
+}
+
+//Multiply pattern is the product of two patterns' colors
+type Multiply struct {
+	A         Pattern
+	B         Pattern
+	Transform *matrix.Matrix
+	inverse
+}
+
+//Add pattern is the sum of two patterns' colors
+type Add struct {
+	A         Pattern
+	B         Pattern
+	Transform *matrix.Matrix
+	inverse
+}
+
+//NewBlend returns a pattern blending a and b equally
+func NewBlend(a Pattern, b Pattern) *Blend {
+	return &Blend{A: a, B: b, Weight: 0.5, Transform: matrix.Identity}
+}
+
+//GetTransform returns a blend pattern's transformation matrix
+func (p *Blend) GetTransform() *matrix.Matrix {
+	return p.Transform
+}
+
+//SetTransform sets a blend pattern's transformation matrix
+func (p *Blend) SetTransform(m *matrix.Matrix) {
+	p.Transform = m
+	p.setInverse(m)
+}
+
+//PatternAt returns the color of a blend pattern at a point
+func (p *Blend) PatternAt(point tuple.Tuple) *color.Color {
+	return mix(*At(p.A, point), *At(p.B, point), p.Weight)
+}
+
+//NewMask returns a pattern showing a or b according to mask
+func NewMask(a Pattern, b Pattern, mask Pattern) *Mask {
+	return &Mask{A: a, B: b, Mask: mask, Transform: matrix.Identity}
+}
+
+//GetTransform returns a mask pattern's transformation matrix
+func (p *Mask) GetTransform() *matrix.Matrix {
+	return p.Transform
+}
+
+//SetTransform sets a mask pattern's transformation matrix
+func (p *Mask) SetTransform(m *matrix.Matrix) {
+	p.Transform = m
+	p.setInverse(m)
+}
+
+//PatternAt returns the color of a mask pattern at a point
+func (p *Mask) PatternAt(point tuple.Tuple) *color.Color {
+	m := At(p.Mask, point)
+	return mix(*At(p.A, point), *At(p.B, point), 0.2126*m.R+0.7152*m.G+0.0722*m.B)
+}
+
+//NewMultiply returns a pattern that is the product of a and b
+func NewMultiply(a Pattern, b Pattern) *Multiply {
+	return &Multiply{A: a, B: b, Transform: matrix.Identity}
+}
+
+//GetTransform returns a multiply pattern's transformation matrix
+func (p *Multiply) GetTransform() *matrix.Matrix {
+	return p.Transform
+}
+
+//SetTransform sets a multiply pattern's transformation matrix
+func (p *Multiply) SetTransform(m *matrix.Matrix) {
+	p.Transform = m
+	p.setInverse(m)
+}
+
+//PatternAt returns the color of a multiply pattern at a point
+func (p *Multiply) PatternAt(point tuple.Tuple) *color.Color {
+	c := At(p.A, point).MultiplyColor(*At(p.B, point))
+	return &c
+}
+
+//NewAdd returns a pattern that is the sum of a and b
+func NewAdd(a Pattern, b Pattern) *Add {
+	return &Add{A: a, B: b, Transform: matrix.Identity}
+}
+
+//GetTransform returns an add pattern's transformation matrix
+func (p *Add) GetTransform() *matrix.Matrix {
+	return p.Transform
+}
+
+//SetTransform sets an add pattern's transformation matrix
+func (p *Add) SetTransform(m *matrix.Matrix) {
+	p.Transform = m
+	p.setInverse(m)
+}
+
+//PatternAt returns the color of an add pattern at a point
+func (p *Add) PatternAt(point tuple.Tuple) *color.Color {
+	c := At(p.A, point).Add(*At(p.B, point))
+	return &c
+}
@@ -0,0 +1,152 @@
+package pattern
+
+import (
+	"math"
+	"sync"
+
+	"github.com/calbim/ray-tracer/src/canvas"
+	"github.com/calbim/ray-tracer/src/color"
+)
+
+//Filter is how an image texture is sampled between pixels
+type Filter int
+
+//Texture filters
+const (
+	Nearest   Filter = iota //the pixel containing the sample point
+	Bilinear                //weighted average of the four nearest pixels
+	Trilinear               //bilinear samples from two mip-map levels blended together
+)
+
+//Wrap is how an image texture is addressed outside [0,1]
+type Wrap int
+
+//Texture addressing modes
+const (
+	Clamp  Wrap = iota //repeat the edge pixels
+	Repeat             //tile the image
+	Mirror             //tile the image, flipping every other copy
+)
+
+//UVImage pattern looks colors up in an image
+type UVImage struct {
+	Canvas *canvas.Canvas
+	Filter Filter
+	Wrap   Wrap
+	//Level is the mip-map level used by trilinear filtering, where 0 is the
+	//full image and each level above it is half the size. There are no ray
+	//differentials to estimate it from, so it is set by hand to suit how
+	//large the texture appears on screen.
+	Level   float64
+	mipmaps []*canvas.Canvas
+	once    sync.Once
+}
+
+//NewUVImage returns a pattern showing an image across texture space, with
+//nearest filtering and clamped addressing
+func NewUVImage(c *canvas.Canvas) *UVImage {
+	return &UVImage{Canvas: c}
+}
+
+//LoadUVImage reads a PPM, PNG or JPEG file into an image pattern
+func LoadUVImage(path string) (*UVImage, error) {
+	c, err := canvas.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewUVImage(c), nil
+}
+
+//UVPatternAt returns the color of the image at (u,v)
+func (p *UVImage) UVPatternAt(u, v float64) *color.Color {
+	var c color.Color
+	switch p.Filter {
+	case Bilinear:
+		c = p.bilinear(p.Canvas, u, v)
+	case Trilinear:
+		c = p.trilinear(u, v)
+	default:
+		c = p.texel(p.Canvas, int(math.Floor(u*float64(p.Canvas.Width()))), int(math.Floor((1-v)*float64(p.Canvas.Height()))))
+	}
+	return &c
+}
+
+//Mipmaps returns the image followed by successively halved copies of it,
+//down to a single pixel. They are built the first time they are needed.
+func (p *UVImage) Mipmaps() []*canvas.Canvas {
+	p.once.Do(func() {
+		p.mipmaps = []*canvas.Canvas{p.Canvas}
+		for c := p.Canvas; c.Width() > 1 || c.Height() > 1; {
+			c = downsample(c)
+			p.mipmaps = append(p.mipmaps, c)
+		}
+	})
+	return p.mipmaps
+}
+
+func (p *UVImage) trilinear(u, v float64) color.Color {
+	levels := p.Mipmaps()
+	level := math.Max(0, math.Min(p.Level, float64(len(levels)-1)))
+	l := int(level)
+	c := p.bilinear(levels[l], u, v)
+	if l == len(levels)-1 {
+		return c
+	}
+	return *mix(c, p.bilinear(levels[l+1], u, v), level-float64(l))
+}
+
+func (p *UVImage) bilinear(image *canvas.Canvas, u, v float64) color.Color {
+	// pixel centers are at half-integer coordinates
+	x := u*float64(image.Width()) - 0.5
+	y := (1-v)*float64(image.Height()) - 0.5
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	i, j := int(x0), int(y0)
+	top := mix(p.texel(image, i, j), p.texel(image, i+1, j), fx)
+	bottom := mix(p.texel(image, i, j+1), p.texel(image, i+1, j+1), fx)
+	return *mix(*top, *bottom, fy)
+}
+
+//texel returns the pixel at (x,y), addressed according to the wrap mode
+func (p *UVImage) texel(image *canvas.Canvas, x, y int) color.Color {
+	x = address(x, image.Width(), p.Wrap)
+	y = address(y, image.Height(), p.Wrap)
+	return image.Pixels[y][x]
+}
+
+func address(i, n int, w Wrap) int {
+	switch w {
+	case Repeat:
+		return (i%n + n) % n
+	case Mirror:
+		i = (i%(2*n) + 2*n) % (2 * n)
+		if i >= n {
+			i = 2*n - 1 - i
+		}
+		return i
+	}
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+//downsample returns an image half the size of c, each pixel the average of
+//a 2x2 block
+func downsample(c *canvas.Canvas) *canvas.Canvas {
+	w, h := (c.Width()+1)/2, (c.Height()+1)/2
+	d := canvas.New(w, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sum := color.Black
+			for _, o := range [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+				sum = sum.Add(c.Pixels[address(2*y+o[1], c.Height(), Clamp)][address(2*x+o[0], c.Width(), Clamp)])
+			}
+			d.WritePixel(x, y, sum.Multiply(0.25))
+		}
+	}
+	return &d
+}
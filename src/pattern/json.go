@@ -0,0 +1,720 @@
+package pattern
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/calbim/ray-tracer/src/canvas"
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/matrix"
+	"github.com/calbim/ray-tracer/src/noise"
+)
+
+//Patterns are encoded as JSON objects whose "type" key names the registered
+//type and whose other keys are the pattern's own fields. Patterns nested in
+//other patterns are encoded the same way.
+var (
+	patterns     = map[string]func() Pattern{}
+	patternNames = map[reflect.Type]string{}
+	uvPatterns   = map[string]func() UVPattern{}
+	uvNames      = map[reflect.Type]string{}
+)
+
+//noises and mappings name the functions that can be encoded
+var (
+	noises = map[string]noise.Func{
+		"perlin":  noise.Perlin,
+		"simplex": noise.Simplex,
+	}
+	mappings = map[string]Mapping{
+		"spherical":   SphericalMap,
+		"planar":      PlanarMap,
+		"cylindrical": CylindricalMap,
+	}
+)
+
+func init() {
+	Register("solid", func() Pattern { return &Solid{} })
+	Register("stripes", func() Pattern { return &Stripe{} })
+	Register("gradient", func() Pattern { return &Gradient{} })
+	Register("rings", func() Pattern { return &Ring{} })
+	Register("checkers", func() Pattern { return &Checkers{} })
+	Register("radial-gradient", func() Pattern { return &RadialGradient{} })
+	Register("marble", func() Pattern { return &Marble{} })
+	Register("wood", func() Pattern { return &Wood{} })
+	Register("granite", func() Pattern { return &Granite{} })
+	Register("cellular", func() Pattern { return &Cellular{} })
+	Register("noise", func() Pattern { return &Noise{} })
+	Register("blend", func() Pattern { return &Blend{} })
+	Register("mask", func() Pattern { return &Mask{} })
+	Register("multiply", func() Pattern { return &Multiply{} })
+	Register("add", func() Pattern { return &Add{} })
+	Register("perturbed", func() Pattern { return &Perturbed{} })
+	Register("texture-map", func() Pattern { return &TextureMap{} })
+	Register("cube-map", func() Pattern { return &CubeMap{} })
+	RegisterUV("checkers", func() UVPattern { return &UVCheckers{} })
+	RegisterUV("align-check", func() UVPattern { return &UVAlignCheck{} })
+	RegisterUV("image", func() UVPattern { return &UVImage{} })
+}
+
+//Register makes a pattern type known to Marshal and Unmarshal by name. new
+//returns an empty pattern of the type for decoding into. Types with
+//unexported or interface fields should implement json.Marshaler and
+//json.Unmarshaler.
+func Register(name string, new func() Pattern) {
+	if _, ok := patterns[name]; ok {
+		panic("pattern: Register called twice for " + name)
+	}
+	patterns[name] = new
+	patternNames[reflect.TypeOf(new())] = name
+}
+
+//RegisterUV makes a UV pattern type known to MarshalUV and UnmarshalUV by
+//name, in the same way as Register
+func RegisterUV(name string, new func() UVPattern) {
+	if _, ok := uvPatterns[name]; ok {
+		panic("pattern: RegisterUV called twice for " + name)
+	}
+	uvPatterns[name] = new
+	uvNames[reflect.TypeOf(new())] = name
+}
+
+//Marshal encodes a pattern of a registered type as JSON. A nil pattern is
+//encoded as null.
+func Marshal(p Pattern) ([]byte, error) {
+	if p == nil {
+		return []byte("null"), nil
+	}
+	return marshalTyped(patternNames, p)
+}
+
+//Unmarshal decodes a pattern written by Marshal
+func Unmarshal(data []byte) (Pattern, error) {
+	name, err := typeOf(data)
+	if name == "" || err != nil {
+		return nil, err
+	}
+	new, ok := patterns[name]
+	if !ok {
+		return nil, fmt.Errorf("pattern: unknown type %q", name)
+	}
+	p := new()
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+//MarshalUV encodes a UV pattern of a registered type as JSON
+func MarshalUV(p UVPattern) ([]byte, error) {
+	if p == nil {
+		return []byte("null"), nil
+	}
+	return marshalTyped(uvNames, p)
+}
+
+//UnmarshalUV decodes a UV pattern written by MarshalUV
+func UnmarshalUV(data []byte) (UVPattern, error) {
+	name, err := typeOf(data)
+	if name == "" || err != nil {
+		return nil, err
+	}
+	new, ok := uvPatterns[name]
+	if !ok {
+		return nil, fmt.Errorf("pattern: unknown UV pattern type %q", name)
+	}
+	p := new()
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+//marshalTyped encodes v and adds its registered name as the "type" key
+func marshalTyped(names map[reflect.Type]string, v interface{}) ([]byte, error) {
+	name, ok := names[reflect.TypeOf(v)]
+	if !ok {
+		return nil, fmt.Errorf("pattern: type %T is not registered", v)
+	}
+	fields, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) < 2 || fields[0] != '{' {
+		return nil, fmt.Errorf("pattern: %T is not encoded as an object", v)
+	}
+	data, _ := json.Marshal(name)
+	data = append([]byte(`{"type":`), data...)
+	if len(fields) > 2 {
+		data = append(data, ',')
+	}
+	return append(data, fields[1:]...), nil
+}
+
+//typeOf returns the "type" key of an encoded object, or "" for null
+func typeOf(data []byte) (string, error) {
+	var v *struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "", err
+	}
+	if v == nil {
+		return "", nil
+	}
+	if v.Type == "" {
+		return "", errors.New("pattern: missing type")
+	}
+	return v.Type, nil
+}
+
+//jsonPattern encodes a nested pattern with Marshal and Unmarshal
+type jsonPattern struct {
+	Pattern
+}
+
+func (j jsonPattern) MarshalJSON() ([]byte, error) {
+	return Marshal(j.Pattern)
+}
+
+func (j *jsonPattern) UnmarshalJSON(data []byte) (err error) {
+	j.Pattern, err = Unmarshal(data)
+	return err
+}
+
+//jsonUVPattern encodes a nested UV pattern with MarshalUV and UnmarshalUV
+type jsonUVPattern struct {
+	UVPattern
+}
+
+func (j jsonUVPattern) MarshalJSON() ([]byte, error) {
+	return MarshalUV(j.UVPattern)
+}
+
+func (j *jsonUVPattern) UnmarshalJSON(data []byte) (err error) {
+	j.UVPattern, err = UnmarshalUV(data)
+	return err
+}
+
+//funcName returns the name under which f is listed in a map of functions
+func funcName(f interface{}, funcs interface{}) (string, error) {
+	if reflect.ValueOf(f).IsNil() {
+		return "", nil
+	}
+	ptr := reflect.ValueOf(f).Pointer()
+	iter := reflect.ValueOf(funcs).MapRange()
+	for iter.Next() {
+		if iter.Value().Pointer() == ptr {
+			return iter.Key().String(), nil
+		}
+	}
+	return "", fmt.Errorf("pattern: cannot encode %T that is not one of the built in functions", f)
+}
+
+//noiseFunc returns the noise function with a name, noise.Perlin when the
+//name is left out
+func noiseFunc(name string) (noise.Func, error) {
+	if name == "" {
+		return noise.Perlin, nil
+	}
+	f, ok := noises[name]
+	if !ok {
+		return nil, fmt.Errorf("pattern: unknown noise %q", name)
+	}
+	return f, nil
+}
+
+func mapping(name string) (Mapping, error) {
+	if name == "" {
+		return nil, errors.New("pattern: texture map is missing its mapping")
+	}
+	m, ok := mappings[name]
+	if !ok {
+		return nil, fmt.Errorf("pattern: unknown mapping %q", name)
+	}
+	return m, nil
+}
+
+//orIdentity returns the identity matrix in place of a missing transform
+func orIdentity(m *matrix.Matrix) *matrix.Matrix {
+	if m == nil {
+		return matrix.Identity
+	}
+	return m
+}
+
+//MarshalText encodes a filter by name
+func (f Filter) MarshalText() ([]byte, error) {
+	for name, v := range filters {
+		if v == f {
+			return []byte(name), nil
+		}
+	}
+	return nil, fmt.Errorf("pattern: unknown filter %d", f)
+}
+
+//UnmarshalText decodes a filter by name
+func (f *Filter) UnmarshalText(text []byte) error {
+	v, ok := filters[string(text)]
+	if !ok {
+		return fmt.Errorf("pattern: unknown filter %q", text)
+	}
+	*f = v
+	return nil
+}
+
+var filters = map[string]Filter{"nearest": Nearest, "bilinear": Bilinear, "trilinear": Trilinear}
+
+//MarshalText encodes a wrap mode by name
+func (w Wrap) MarshalText() ([]byte, error) {
+	for name, v := range wraps {
+		if v == w {
+			return []byte(name), nil
+		}
+	}
+	return nil, fmt.Errorf("pattern: unknown wrap %d", w)
+}
+
+//UnmarshalText decodes a wrap mode by name
+func (w *Wrap) UnmarshalText(text []byte) error {
+	v, ok := wraps[string(text)]
+	if !ok {
+		return fmt.Errorf("pattern: unknown wrap %q", text)
+	}
+	*w = v
+	return nil
+}
+
+var wraps = map[string]Wrap{"clamp": Clamp, "repeat": Repeat, "mirror": Mirror}
+
+type jsonSolid struct {
+	Color     color.Color    `json:"color"`
+	Transform *matrix.Matrix `json:"transform"`
+}
+
+//MarshalJSON encodes a solid pattern
+func (p *Solid) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonSolid{p.Color, p.Transform})
+}
+
+//UnmarshalJSON decodes a solid pattern
+func (p *Solid) UnmarshalJSON(data []byte) error {
+	var j jsonSolid
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	p.Color = j.Color
+	p.SetTransform(orIdentity(j.Transform))
+	return nil
+}
+
+//jsonPair is the encoding of the patterns that alternate between two others
+type jsonPair struct {
+	A         jsonPattern    `json:"a"`
+	B         jsonPattern    `json:"b"`
+	Transform *matrix.Matrix `json:"transform"`
+}
+
+func marshalPair(a, b Pattern, transform *matrix.Matrix) ([]byte, error) {
+	return json.Marshal(jsonPair{jsonPattern{a}, jsonPattern{b}, transform})
+}
+
+func unmarshalPair(data []byte, a, b *Pattern, p Pattern) error {
+	var j jsonPair
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := required("a", j.A.Pattern, "b", j.B.Pattern); err != nil {
+		return err
+	}
+	*a, *b = j.A.Pattern, j.B.Pattern
+	p.SetTransform(orIdentity(j.Transform))
+	return nil
+}
+
+//required takes pairs of keys and the nested patterns decoded from them,
+//and returns an error for the first that was left out or null
+func required(keysAndPatterns ...interface{}) error {
+	for i := 0; i < len(keysAndPatterns); i += 2 {
+		if p := keysAndPatterns[i+1]; p == nil || reflect.ValueOf(p).IsNil() {
+			return fmt.Errorf("pattern: missing %s", keysAndPatterns[i])
+		}
+	}
+	return nil
+}
+
+//MarshalJSON encodes a stripe pattern
+func (p *Stripe) MarshalJSON() ([]byte, error) {
+	return marshalPair(p.a, p.b, p.Transform)
+}
+
+//UnmarshalJSON decodes a stripe pattern
+func (p *Stripe) UnmarshalJSON(data []byte) error {
+	return unmarshalPair(data, &p.a, &p.b, p)
+}
+
+//MarshalJSON encodes a gradient pattern
+func (p *Gradient) MarshalJSON() ([]byte, error) {
+	return marshalPair(p.a, p.b, p.Transform)
+}
+
+//UnmarshalJSON decodes a gradient pattern
+func (p *Gradient) UnmarshalJSON(data []byte) error {
+	return unmarshalPair(data, &p.a, &p.b, p)
+}
+
+//MarshalJSON encodes a ring pattern
+func (p *Ring) MarshalJSON() ([]byte, error) {
+	return marshalPair(p.a, p.b, p.Transform)
+}
+
+//UnmarshalJSON decodes a ring pattern
+func (p *Ring) UnmarshalJSON(data []byte) error {
+	return unmarshalPair(data, &p.a, &p.b, p)
+}
+
+//MarshalJSON encodes a checkers pattern
+func (p *Checkers) MarshalJSON() ([]byte, error) {
+	return marshalPair(p.a, p.b, p.Transform)
+}
+
+//UnmarshalJSON decodes a checkers pattern
+func (p *Checkers) UnmarshalJSON(data []byte) error {
+	return unmarshalPair(data, &p.a, &p.b, p)
+}
+
+//MarshalJSON encodes a radial gradient pattern
+func (p *RadialGradient) MarshalJSON() ([]byte, error) {
+	return marshalPair(p.a, p.b, p.Transform)
+}
+
+//UnmarshalJSON decodes a radial gradient pattern
+func (p *RadialGradient) UnmarshalJSON(data []byte) error {
+	return unmarshalPair(data, &p.a, &p.b, p)
+}
+
+//jsonProcedural is the encoding of the noise based patterns
+type jsonProcedural struct {
+	A          color.Color    `json:"a"`
+	B          color.Color    `json:"b"`
+	Noise      string         `json:"noise,omitempty"`
+	Scale      float64        `json:"scale"`
+	Turbulence float64        `json:"turbulence"`
+	Octaves    int            `json:"octaves"`
+	Transform  *matrix.Matrix `json:"transform"`
+}
+
+func unmarshalProcedural(data []byte) (jsonProcedural, error) {
+	var j jsonProcedural
+	err := json.Unmarshal(data, &j)
+	j.Transform = orIdentity(j.Transform)
+	return j, err
+}
+
+//MarshalJSON encodes a marble pattern
+func (p *Marble) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonProcedural{A: p.a, B: p.b, Scale: p.Scale, Turbulence: p.Turbulence, Octaves: p.Octaves, Transform: p.Transform})
+}
+
+//UnmarshalJSON decodes a marble pattern
+func (p *Marble) UnmarshalJSON(data []byte) error {
+	j, err := unmarshalProcedural(data)
+	*p = Marble{a: j.A, b: j.B, Scale: j.Scale, Turbulence: j.Turbulence, Octaves: j.Octaves}
+	p.SetTransform(j.Transform)
+	return err
+}
+
+//MarshalJSON encodes a wood pattern
+func (p *Wood) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonProcedural{A: p.a, B: p.b, Scale: p.Scale, Turbulence: p.Turbulence, Octaves: p.Octaves, Transform: p.Transform})
+}
+
+//UnmarshalJSON decodes a wood pattern
+func (p *Wood) UnmarshalJSON(data []byte) error {
+	j, err := unmarshalProcedural(data)
+	*p = Wood{a: j.A, b: j.B, Scale: j.Scale, Turbulence: j.Turbulence, Octaves: j.Octaves}
+	p.SetTransform(j.Transform)
+	return err
+}
+
+//MarshalJSON encodes a granite pattern
+func (p *Granite) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonProcedural{A: p.a, B: p.b, Scale: p.Scale, Turbulence: p.Turbulence, Octaves: p.Octaves, Transform: p.Transform})
+}
+
+//UnmarshalJSON decodes a granite pattern
+func (p *Granite) UnmarshalJSON(data []byte) error {
+	j, err := unmarshalProcedural(data)
+	*p = Granite{a: j.A, b: j.B, Scale: j.Scale, Turbulence: j.Turbulence, Octaves: j.Octaves}
+	p.SetTransform(j.Transform)
+	return err
+}
+
+//MarshalJSON encodes a cellular pattern
+func (p *Cellular) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonProcedural{A: p.a, B: p.b, Scale: p.Scale, Turbulence: p.Turbulence, Octaves: p.Octaves, Transform: p.Transform})
+}
+
+//UnmarshalJSON decodes a cellular pattern
+func (p *Cellular) UnmarshalJSON(data []byte) error {
+	j, err := unmarshalProcedural(data)
+	*p = Cellular{a: j.A, b: j.B, Scale: j.Scale, Turbulence: j.Turbulence, Octaves: j.Octaves}
+	p.SetTransform(j.Transform)
+	return err
+}
+
+//MarshalJSON encodes a noise pattern
+func (p *Noise) MarshalJSON() ([]byte, error) {
+	name, err := funcName(p.Func, noises)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonProcedural{A: p.a, B: p.b, Noise: name, Scale: p.Scale, Octaves: p.Octaves, Transform: p.Transform})
+}
+
+//UnmarshalJSON decodes a noise pattern
+func (p *Noise) UnmarshalJSON(data []byte) error {
+	j, err := unmarshalProcedural(data)
+	if err != nil {
+		return err
+	}
+	f, err := noiseFunc(j.Noise)
+	*p = Noise{a: j.A, b: j.B, Func: f, Scale: j.Scale, Octaves: j.Octaves}
+	p.SetTransform(j.Transform)
+	return err
+}
+
+type jsonCompose struct {
+	A         jsonPattern    `json:"a"`
+	B         jsonPattern    `json:"b"`
+	Mask      *jsonPattern   `json:"mask,omitempty"`
+	Weight    *float64       `json:"weight,omitempty"`
+	Transform *matrix.Matrix `json:"transform"`
+}
+
+//MarshalJSON encodes a blend pattern
+func (p *Blend) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonCompose{A: jsonPattern{p.A}, B: jsonPattern{p.B}, Weight: &p.Weight, Transform: p.Transform})
+}
+
+//UnmarshalJSON decodes a blend pattern
+func (p *Blend) UnmarshalJSON(data []byte) error {
+	var j jsonCompose
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := required("a", j.A.Pattern, "b", j.B.Pattern); err != nil {
+		return err
+	}
+	*p = Blend{A: j.A.Pattern, B: j.B.Pattern, Weight: 0.5}
+	p.SetTransform(orIdentity(j.Transform))
+	if j.Weight != nil {
+		p.Weight = *j.Weight
+	}
+	return nil
+}
+
+//MarshalJSON encodes a mask pattern
+func (p *Mask) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonCompose{A: jsonPattern{p.A}, B: jsonPattern{p.B}, Mask: &jsonPattern{p.Mask}, Transform: p.Transform})
+}
+
+//UnmarshalJSON decodes a mask pattern
+func (p *Mask) UnmarshalJSON(data []byte) error {
+	var j jsonCompose
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	var mask Pattern
+	if j.Mask != nil {
+		mask = j.Mask.Pattern
+	}
+	if err := required("a", j.A.Pattern, "b", j.B.Pattern, "mask", mask); err != nil {
+		return err
+	}
+	*p = Mask{A: j.A.Pattern, B: j.B.Pattern, Mask: mask}
+	p.SetTransform(orIdentity(j.Transform))
+	return nil
+}
+
+//MarshalJSON encodes a multiply pattern
+func (p *Multiply) MarshalJSON() ([]byte, error) {
+	return marshalPair(p.A, p.B, p.Transform)
+}
+
+//UnmarshalJSON decodes a multiply pattern
+func (p *Multiply) UnmarshalJSON(data []byte) error {
+	return unmarshalPair(data, &p.A, &p.B, p)
+}
+
+//MarshalJSON encodes an add pattern
+func (p *Add) MarshalJSON() ([]byte, error) {
+	return marshalPair(p.A, p.B, p.Transform)
+}
+
+//UnmarshalJSON decodes an add pattern
+func (p *Add) UnmarshalJSON(data []byte) error {
+	return unmarshalPair(data, &p.A, &p.B, p)
+}
+
+type jsonPerturbed struct {
+	Pattern   jsonPattern    `json:"pattern"`
+	Noise     string         `json:"noise,omitempty"`
+	Scale     float64        `json:"scale"`
+	Octaves   int            `json:"octaves"`
+	Transform *matrix.Matrix `json:"transform"`
+}
+
+//MarshalJSON encodes a perturbed pattern
+func (p *Perturbed) MarshalJSON() ([]byte, error) {
+	name, err := funcName(p.Noise, noises)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonPerturbed{jsonPattern{p.Pattern}, name, p.Scale, p.Octaves, p.Transform})
+}
+
+//UnmarshalJSON decodes a perturbed pattern
+func (p *Perturbed) UnmarshalJSON(data []byte) error {
+	var j jsonPerturbed
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := required("pattern", j.Pattern.Pattern); err != nil {
+		return err
+	}
+	f, err := noiseFunc(j.Noise)
+	*p = Perturbed{Pattern: j.Pattern.Pattern, Scale: j.Scale, Octaves: j.Octaves, Noise: f}
+	p.SetTransform(orIdentity(j.Transform))
+	return err
+}
+
+type jsonTextureMap struct {
+	UVPattern jsonUVPattern  `json:"uv_pattern"`
+	Mapping   string         `json:"mapping"`
+	Transform *matrix.Matrix `json:"transform"`
+}
+
+//MarshalJSON encodes a texture map
+func (p *TextureMap) MarshalJSON() ([]byte, error) {
+	name, err := funcName(p.Mapping, mappings)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonTextureMap{jsonUVPattern{p.UVPattern}, name, p.Transform})
+}
+
+//UnmarshalJSON decodes a texture map
+func (p *TextureMap) UnmarshalJSON(data []byte) error {
+	var j jsonTextureMap
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := required("uv_pattern", j.UVPattern.UVPattern); err != nil {
+		return err
+	}
+	m, err := mapping(j.Mapping)
+	*p = TextureMap{UVPattern: j.UVPattern.UVPattern, Mapping: m}
+	p.SetTransform(orIdentity(j.Transform))
+	return err
+}
+
+type jsonCubeMap struct {
+	Faces     [6]jsonUVPattern `json:"faces"`
+	Transform *matrix.Matrix   `json:"transform"`
+}
+
+//MarshalJSON encodes a cube map, with faces in the order of the Cube
+//constants
+func (p *CubeMap) MarshalJSON() ([]byte, error) {
+	j := jsonCubeMap{Transform: p.Transform}
+	for i, f := range p.Faces {
+		j.Faces[i].UVPattern = f
+	}
+	return json.Marshal(j)
+}
+
+//UnmarshalJSON decodes a cube map
+func (p *CubeMap) UnmarshalJSON(data []byte) error {
+	var j jsonCubeMap
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	for i, f := range j.Faces {
+		if err := required(fmt.Sprintf("face %d", i), f.UVPattern); err != nil {
+			return err
+		}
+		p.Faces[i] = f.UVPattern
+	}
+	p.SetTransform(orIdentity(j.Transform))
+	return nil
+}
+
+type jsonUVCheckers struct {
+	Width  float64     `json:"width"`
+	Height float64     `json:"height"`
+	A      color.Color `json:"a"`
+	B      color.Color `json:"b"`
+}
+
+//MarshalJSON encodes a UV checkers pattern
+func (p *UVCheckers) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonUVCheckers{p.Width, p.Height, p.a, p.b})
+}
+
+//UnmarshalJSON decodes a UV checkers pattern
+func (p *UVCheckers) UnmarshalJSON(data []byte) error {
+	var j jsonUVCheckers
+	err := json.Unmarshal(data, &j)
+	*p = UVCheckers{j.Width, j.Height, j.A, j.B}
+	return err
+}
+
+type jsonUVAlignCheck struct {
+	Main        color.Color `json:"main"`
+	UpperLeft   color.Color `json:"ul"`
+	UpperRight  color.Color `json:"ur"`
+	BottomLeft  color.Color `json:"bl"`
+	BottomRight color.Color `json:"br"`
+}
+
+//MarshalJSON encodes a UV align check pattern
+func (p *UVAlignCheck) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonUVAlignCheck(*p))
+}
+
+//UnmarshalJSON decodes a UV align check pattern
+func (p *UVAlignCheck) UnmarshalJSON(data []byte) error {
+	var j jsonUVAlignCheck
+	err := json.Unmarshal(data, &j)
+	*p = UVAlignCheck(j)
+	return err
+}
+
+type jsonUVImage struct {
+	Canvas *canvas.Canvas `json:"canvas"`
+	Filter Filter         `json:"filter"`
+	Wrap   Wrap           `json:"wrap"`
+	Level  float64        `json:"level"`
+}
+
+//MarshalJSON encodes an image pattern with its pixels
+func (p *UVImage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonUVImage{p.Canvas, p.Filter, p.Wrap, p.Level})
+}
+
+//UnmarshalJSON decodes an image pattern into a new UVImage. Its mip-maps are
+//built again when first needed.
+func (p *UVImage) UnmarshalJSON(data []byte) error {
+	var j jsonUVImage
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Canvas == nil {
+		return errors.New("pattern: image is missing its canvas")
+	}
+	p.Canvas, p.Filter, p.Wrap, p.Level = j.Canvas, j.Filter, j.Wrap, j.Level
+	return nil
+}
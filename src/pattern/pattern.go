@@ -5,7 +5,6 @@ import (
 
 	"github.com/calbim/ray-tracer/src/color"
 	"github.com/calbim/ray-tracer/src/matrix"
-
 	"github.com/calbim/ray-tracer/src/tuple"
 )
 
@@ -16,61 +15,86 @@ type Pattern interface {
 	PatternAt(point tuple.Tuple) *color.Color
 }
 
+//Solid pattern of a single color
+type Solid struct {
+	Color     color.Color
+	Transform *matrix.Matrix
+	inverse
+}
+
 //Stripe pattern
 type Stripe struct {
-	a         color.Color
-	b         color.Color
+	a         Pattern
+	b         Pattern
 	Transform *matrix.Matrix
+	inverse
 }
 
 //Gradient pattern
 type Gradient struct {
-	a         color.Color
-	b         color.Color
+	a         Pattern
+	b         Pattern
 	Transform *matrix.Matrix
+	inverse
 }
 
 //Ring pattern
 type Ring struct {
-	a         color.Color
-	b         color.Color
+	a         Pattern
+	b         Pattern
 	Transform *matrix.Matrix
+	inverse
 }
 
 //Checkers pattern
 type Checkers struct {
-	a         color.Color
-	b         color.Color
+	a         Pattern
+	b         Pattern
 	Transform *matrix.Matrix
+	inverse
 }
 
 //RadialGradient pattern
 type RadialGradient struct {
-	a         color.Color
-	b         color.Color
+	a         Pattern
+	b         Pattern
 	Transform *matrix.Matrix
+	inverse
 }
 
+//Object is anything a pattern can be applied to, usually a shape.Shape
+type Object interface {
+	GetTransform() *matrix.Matrix
+}
 
-//Object struct
-type Object struct {
-	Transform *matrix.Matrix
+//NewSolid returns a pattern that is color c everywhere
+func NewSolid(c color.Color) *Solid {
+	return &Solid{Color: c, Transform: matrix.Identity}
 }
 
-//NewObject returns a new object
-func NewObject() Object {
-	return Object{
-		Transform: matrix.Identity,
-	}
+//GetTransform returns a solid pattern's transformation matrix
+func (p *Solid) GetTransform() *matrix.Matrix {
+	return p.Transform
 }
 
-//SetTransform sets an object's transform
-func (o *Object) SetTransform(transform *matrix.Matrix) {
-	o.Transform = transform
+//SetTransform sets a solid pattern's transformation matrix
+func (p *Solid) SetTransform(m *matrix.Matrix) {
+	p.Transform = m
+	p.setInverse(m)
+}
+
+//PatternAt returns the color of a solid pattern, which is the same at every point
+func (p *Solid) PatternAt(point tuple.Tuple) *color.Color {
+	return &p.Color
 }
 
 //NewStripe returns a stripe pattern
 func NewStripe(a color.Color, b color.Color) *Stripe {
+	return NewStripeOf(NewSolid(a), NewSolid(b))
+}
+
+//NewStripeOf returns a stripe pattern whose two regions are themselves patterns
+func NewStripeOf(a Pattern, b Pattern) *Stripe {
 	return &Stripe{a: a, b: b, Transform: matrix.Identity}
 }
 
@@ -82,18 +106,24 @@ func (p *Stripe) GetTransform() *matrix.Matrix {
 //SetTransform sets a stripe's transformation matrix
 func (p *Stripe) SetTransform(m *matrix.Matrix) {
 	p.Transform = m
+	p.setInverse(m)
 }
 
 //PatternAt returns the color of a stripe at a point
 func (p *Stripe) PatternAt(point tuple.Tuple) *color.Color {
 	if int(math.Floor(point.X))%2 == 0 {
-		return &p.a
+		return At(p.a, point)
 	}
-	return &p.b
+	return At(p.b, point)
 }
 
 //NewGradient returns a gradient pattern
 func NewGradient(a color.Color, b color.Color) *Gradient {
+	return NewGradientOf(NewSolid(a), NewSolid(b))
+}
+
+//NewGradientOf returns a gradient pattern whose two regions are themselves patterns
+func NewGradientOf(a Pattern, b Pattern) *Gradient {
 	return &Gradient{a: a, b: b, Transform: matrix.Identity}
 }
 
@@ -105,17 +135,21 @@ func (p *Gradient) GetTransform() *matrix.Matrix {
 //SetTransform sets a gradient's transformation matrix
 func (p *Gradient) SetTransform(m *matrix.Matrix) {
 	p.Transform = m
+	p.setInverse(m)
 }
 
 //PatternAt returns the color of a gradient at a point
 func (p *Gradient) PatternAt(point tuple.Tuple) *color.Color {
-	diff := p.b.Subtract(p.a)
-	c := p.a.Add(diff.Multiply(point.X - math.Floor(point.X)))
-	return &c
+	return mix(*At(p.a, point), *At(p.b, point), point.X-math.Floor(point.X))
 }
 
 //NewRing returns a gradient pattern
 func NewRing(a color.Color, b color.Color) *Ring {
+	return NewRingOf(NewSolid(a), NewSolid(b))
+}
+
+//NewRingOf returns a ring pattern whose two regions are themselves patterns
+func NewRingOf(a Pattern, b Pattern) *Ring {
 	return &Ring{a: a, b: b, Transform: matrix.Identity}
 }
 
@@ -127,19 +161,25 @@ func (p *Ring) GetTransform() *matrix.Matrix {
 //SetTransform sets a gradient's transformation matrix
 func (p *Ring) SetTransform(m *matrix.Matrix) {
 	p.Transform = m
+	p.setInverse(m)
 }
 
 //PatternAt returns the color of a gradient at a point
 func (p *Ring) PatternAt(point tuple.Tuple) *color.Color {
 	v := int(math.Floor(math.Sqrt(point.X*point.X + point.Z*point.Z)))
 	if v%2 == 0 {
-		return &p.a
+		return At(p.a, point)
 	}
-	return &p.b
+	return At(p.b, point)
 }
 
 //NewCheckers returns a checkers pattern
 func NewCheckers(a color.Color, b color.Color) *Checkers {
+	return NewCheckersOf(NewSolid(a), NewSolid(b))
+}
+
+//NewCheckersOf returns a checkers pattern whose two regions are themselves patterns
+func NewCheckersOf(a Pattern, b Pattern) *Checkers {
 	return &Checkers{a: a, b: b, Transform: matrix.Identity}
 }
 
@@ -151,19 +191,25 @@ func (p *Checkers) GetTransform() *matrix.Matrix {
 //SetTransform sets a checkers transformation matrix
 func (p *Checkers) SetTransform(m *matrix.Matrix) {
 	p.Transform = m
+	p.setInverse(m)
 }
 
 //PatternAt returns the color of a gradient at a point
 func (p *Checkers) PatternAt(point tuple.Tuple) *color.Color {
 	v := int(math.Floor(point.X) + math.Floor(point.Y) + math.Floor(point.Z))
 	if v%2 == 0 {
-		return &p.a
+		return At(p.a, point)
 	}
-	return &p.b
+	return At(p.b, point)
 }
 
 //NewRadialGradient returns a radial gradient pattern
 func NewRadialGradient(a color.Color, b color.Color) *RadialGradient {
+	return NewRadialGradientOf(NewSolid(a), NewSolid(b))
+}
+
+//NewRadialGradientOf returns a radial gradient pattern whose two regions are themselves patterns
+func NewRadialGradientOf(a Pattern, b Pattern) *RadialGradient {
 	return &RadialGradient{a: a, b: b, Transform: matrix.Identity}
 }
 
@@ -175,21 +221,65 @@ func (p *RadialGradient) GetTransform() *matrix.Matrix {
 //SetTransform sets a radial gradient's transformation matrix
 func (p *RadialGradient) SetTransform(m *matrix.Matrix) {
 	p.Transform = m
+	p.setInverse(m)
 }
 
 //PatternAt returns the color of a radial gradient at a point
 func (p *RadialGradient) PatternAt(point tuple.Tuple) *color.Color {
 	v := math.Sqrt(point.X*point.X + point.Z*point.Z)
-	diff := p.b.Subtract(p.a)
-	c := p.a.Add(diff.Multiply(v - math.Floor(v)))
-	return &c
+	return mix(*At(p.a, point), *At(p.b, point), v-math.Floor(v))
 }
 
-//AtObject returns the color of a stripe at a point on an object
+//AtObject returns the color of a pattern at a point on an object
 func AtObject(p Pattern, o Object, point tuple.Tuple) *color.Color {
-	oInv, _ := o.Transform.Inverse()
+	oInv, _ := o.GetTransform().Inverse()
 	point = oInv.MultiplyTuple(point)
-	pInv, _ := p.GetTransform().Inverse()
-	point = pInv.MultiplyTuple(point)
+	return At(p, point)
+}
+
+//At returns the color of a pattern at a point given in the space the
+//pattern's transform is relative to
+func At(p Pattern, point tuple.Tuple) *color.Color {
+	point = transformInverse(p).MultiplyTuple(point)
 	return p.PatternAt(point)
 }
+
+//inverse keeps the inverse of a pattern's transform, so that looking up
+//colors, which is done for every sample and through every level of nested
+//patterns, does not invert it each time. It is worked out by SetTransform.
+type inverse struct {
+	of  *matrix.Matrix
+	inv *matrix.Matrix
+}
+
+func (c *inverse) setInverse(m *matrix.Matrix) {
+	c.of = m
+	c.inv, _ = m.Inverse()
+}
+
+//inverseOf returns the kept inverse of m, or nil if it was not kept
+func (c *inverse) inverseOf(m *matrix.Matrix) *matrix.Matrix {
+	if m != c.of {
+		return nil
+	}
+	return c.inv
+}
+
+//transformInverse returns the inverse of a pattern's transform. Patterns
+//given a transform by assigning their Transform field, rather than with
+//SetTransform, and patterns from outside the package have it inverted here.
+func transformInverse(p Pattern) *matrix.Matrix {
+	m := p.GetTransform()
+	if m == matrix.Identity {
+		return m
+	}
+	if c, ok := p.(interface {
+		inverseOf(*matrix.Matrix) *matrix.Matrix
+	}); ok {
+		if inv := c.inverseOf(m); inv != nil {
+			return inv
+		}
+	}
+	inv, _ := m.Inverse()
+	return inv
+}
@@ -1,22 +1,31 @@
 package pattern
 
 import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/calbim/ray-tracer/src/matrix"
 
+	"github.com/calbim/ray-tracer/src/canvas"
 	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/noise"
 	"github.com/calbim/ray-tracer/src/transforms"
 	"github.com/calbim/ray-tracer/src/tuple"
 )
 
 func TestStripePattern(t *testing.T) {
 	pattern := NewStripe(color.White, color.Black)
-	if !pattern.a.Equals(color.White) {
-		t.Errorf("wanted stripe a=%v, got %v", color.White, pattern.a)
+	a := pattern.a.PatternAt(tuple.Point(0, 0, 0))
+	if !a.Equals(color.White) {
+		t.Errorf("wanted stripe a=%v, got %v", color.White, a)
 	}
-	if !pattern.b.Equals(color.Black) {
-		t.Errorf("wanted stripe a=%v, got %v", color.Black, pattern.b)
+	b := pattern.b.PatternAt(tuple.Point(0, 0, 0))
+	if !b.Equals(color.Black) {
+		t.Errorf("wanted stripe b=%v, got %v", color.Black, b)
 	}
 }
 
@@ -69,7 +78,7 @@ func TestStripeAlternatesInX(t *testing.T) {
 }
 
 func TestStripesWithObjectTransformation(t *testing.T) {
-	o := NewObject()
+	o := newTestObject()
 	o.Transform = transforms.Scaling(2, 2, 2)
 	p := NewStripe(color.White, color.Black)
 	c := AtObject(p, o, tuple.Point(1.5, 0, 0))
@@ -79,7 +88,7 @@ func TestStripesWithObjectTransformation(t *testing.T) {
 }
 
 func TestStripesWithPatternTransformation(t *testing.T) {
-	o := NewObject()
+	o := newTestObject()
 	p := NewStripe(color.White, color.Black)
 	p.Transform = transforms.Scaling(2, 2, 2)
 	c := AtObject(p, o, tuple.Point(1.5, 0, 0))
@@ -89,7 +98,7 @@ func TestStripesWithPatternTransformation(t *testing.T) {
 }
 
 func TestStripesWithObjectAndPatternTransformation(t *testing.T) {
-	o := NewObject()
+	o := newTestObject()
 	o.Transform = transforms.Scaling(2, 2, 2)
 	p := NewStripe(color.White, color.Black)
 	p.Transform = transforms.Translation(0.5, 0, 0)
@@ -117,7 +126,7 @@ func (tp *TestPattern) SetTransform(transform *matrix.Matrix) {
 }
 
 func (tp *TestPattern) PatternAtObject(o Object, point tuple.Tuple) color.Color {
-	oInv, _ := o.Transform.Inverse()
+	oInv, _ := o.GetTransform().Inverse()
 	point = oInv.MultiplyTuple(point)
 	pInv, _ := tp.Transform.Inverse()
 	point = pInv.MultiplyTuple(point)
@@ -138,8 +147,24 @@ func TestAssignPatternTransformation(t *testing.T) {
 	}
 }
 
+func TestPatternKeepsInverseTransformation(t *testing.T) {
+	p := NewStripe(color.White, color.Black)
+	p.SetTransform(transforms.Scaling(2, 2, 2))
+	if inv := transformInverse(p); inv != p.inverse.inv || !inv.Equals(transforms.Scaling(0.5, 0.5, 0.5)) {
+		t.Errorf("wanted the kept inverse %v, got %v", transforms.Scaling(0.5, 0.5, 0.5), inv)
+	}
+	p.Transform = transforms.Translation(1, 0, 0)
+	if c := At(p, tuple.Point(1.5, 0, 0)); !c.Equals(color.White) {
+		t.Errorf("wanted a transform assigned directly to be used, got %v", c)
+	}
+	p.SetTransform(matrix.Identity)
+	if c := At(p, tuple.Point(1.5, 0, 0)); !c.Equals(color.Black) {
+		t.Errorf("wanted the identity after it was set, got %v", c)
+	}
+}
+
 func TestPatternWithObjectTransformation(t *testing.T) {
-	o := NewObject()
+	o := newTestObject()
 	o.Transform = transforms.Scaling(2, 2, 2)
 	p := NewTestPattern()
 	c := p.PatternAtObject(o, tuple.Point(2, 3, 4))
@@ -149,7 +174,7 @@ func TestPatternWithObjectTransformation(t *testing.T) {
 }
 
 func TestPatternWithPatterntTransformation(t *testing.T) {
-	o := NewObject()
+	o := newTestObject()
 	p := NewTestPattern()
 	p.SetTransform(transforms.Scaling(2, 2, 2))
 	c := p.PatternAtObject(o, tuple.Point(2, 3, 4))
@@ -159,7 +184,7 @@ func TestPatternWithPatterntTransformation(t *testing.T) {
 }
 
 func TestPatternWithObjectAndPatterntTransformation(t *testing.T) {
-	o := NewObject()
+	o := newTestObject()
 	o.SetTransform(transforms.Scaling(2, 2, 2))
 	p := NewTestPattern()
 	p.SetTransform(transforms.Translation(0.5, 1, 1.5))
@@ -256,3 +281,671 @@ func TestCheckersShouldRepeatInZ(t *testing.T) {
 		t.Errorf("wanted color to be %v, got %v", color.Black, c)
 	}
 }
+
+func TestPerturbedWithoutScaleMatchesInnerPattern(t *testing.T) {
+	p := NewPerturbed(NewStripe(color.White, color.Black), 0)
+	for _, x := range []float64{0.1, 0.9, 1.1, -0.5} {
+		c := p.PatternAt(tuple.Point(x, 0.3, 0.7))
+		inner := NewStripe(color.White, color.Black).PatternAt(tuple.Point(x, 0.3, 0.7))
+		if !c.Equals(*inner) {
+			t.Errorf("wanted color at x=%v to be %v, got %v", x, inner, c)
+		}
+	}
+}
+
+func TestPerturbedJittersLookupPoint(t *testing.T) {
+	inner := NewGradient(color.Black, color.White)
+	p := NewPerturbed(inner, 0.2)
+	point := tuple.Point(0.35, 0.42, 0.17)
+	c := p.PatternAt(point)
+	if c.Equals(*inner.PatternAt(point)) {
+		t.Errorf("wanted perturbed color to differ from %v", inner.PatternAt(point))
+	}
+	if math.Abs(c.R-0.35) > 0.2 {
+		t.Errorf("wanted perturbed color within the perturbation scale, got %v", c)
+	}
+}
+
+func TestPerturbedUsesInnerTransform(t *testing.T) {
+	inner := NewStripe(color.White, color.Black)
+	inner.SetTransform(transforms.Scaling(2, 2, 2))
+	p := NewPerturbed(inner, 0)
+	c := p.PatternAt(tuple.Point(1.5, 0, 0))
+	if !c.Equals(color.White) {
+		t.Errorf("wanted color=%v, got %v", color.White, c)
+	}
+}
+
+func TestMarbleWithoutTurbulenceIsSinusoidal(t *testing.T) {
+	p := NewMarble(color.White, color.Black)
+	p.Turbulence = 0
+	if c := p.PatternAt(tuple.Point(0, 0.3, 0.2)); !c.Equals(color.New(0.5, 0.5, 0.5)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.5, 0.5, 0.5), c)
+	}
+	if c := p.PatternAt(tuple.Point(0.5, 0.3, 0.2)); !c.Equals(color.Black) {
+		t.Errorf("wanted color=%v, got %v", color.Black, c)
+	}
+	if c := p.PatternAt(tuple.Point(-0.5, 0.3, 0.2)); !c.Equals(color.White) {
+		t.Errorf("wanted color=%v, got %v", color.White, c)
+	}
+}
+
+func TestWoodWithoutTurbulenceFormsRings(t *testing.T) {
+	p := NewWood(color.White, color.Black)
+	p.Turbulence = 0
+	p.Scale = 1
+	if c := p.PatternAt(tuple.Point(0.25, 5, 0)); !c.Equals(color.New(0.75, 0.75, 0.75)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.75, 0.75, 0.75), c)
+	}
+	if c := p.PatternAt(tuple.Point(0, -2, 1.5)); !c.Equals(color.New(0.5, 0.5, 0.5)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.5, 0.5, 0.5), c)
+	}
+}
+
+func TestGraniteMixesBothColors(t *testing.T) {
+	p := NewGranite(color.White, color.Black)
+	white, black := false, false
+	for i := 0; i < 500; i++ {
+		c := p.PatternAt(tuple.Point(float64(i)*0.013, float64(i%17)*0.05, 0.3))
+		if c.R < 0 || c.R > 1 {
+			t.Errorf("wanted granite between its colors, got %v", c)
+		}
+		white = white || c.Equals(color.White)
+		black = black || c.Equals(color.Black)
+	}
+	if !white || !black {
+		t.Errorf("wanted granite to show both base and speckle colors")
+	}
+}
+
+func TestCellularIsFirstColorAtCellCenters(t *testing.T) {
+	p := NewCellular(color.White, color.Black)
+	darkest := 1.0
+	for i := 0; i < 1000; i++ {
+		c := p.PatternAt(tuple.Point(float64(i%10)*0.1, float64(i/10%10)*0.1, float64(i/100)*0.1))
+		darkest = math.Min(darkest, c.R)
+		if c.R < 0 || c.R > 1 {
+			t.Errorf("wanted cellular color between its colors, got %v", c)
+		}
+	}
+	if darkest > 0.5 {
+		t.Errorf("wanted cell edges to approach the second color, darkest was %v", darkest)
+	}
+}
+
+func TestCheckersOfStripes(t *testing.T) {
+	red, blue := color.New(1, 0, 0), color.New(0, 0, 1)
+	stripes := NewStripe(red, blue)
+	stripes.SetTransform(transforms.Scaling(0.5, 0.5, 0.5))
+	p := NewCheckersOf(stripes, NewSolid(color.White))
+	tests := []struct {
+		point tuple.Tuple
+		want  color.Color
+	}{
+		{tuple.Point(0.25, 0, 0), red},
+		{tuple.Point(0.75, 0, 0), blue},
+		{tuple.Point(1.25, 0, 0), color.White},
+	}
+	for _, test := range tests {
+		if c := p.PatternAt(test.point); !c.Equals(test.want) {
+			t.Errorf("wanted color at %v=%v, got %v", test.point, test.want, c)
+		}
+	}
+}
+
+func TestNestedPatternTransformsCompose(t *testing.T) {
+	inner := NewStripe(color.White, color.Black)
+	inner.SetTransform(transforms.Scaling(2, 2, 2))
+	outer := NewStripeOf(inner, NewSolid(color.Black))
+	outer.SetTransform(transforms.Translation(-4, 0, 0))
+	o := newTestObject()
+	o.SetTransform(transforms.Scaling(0.5, 0.5, 0.5))
+	// object space x=3, outer pattern space x=7, inner pattern space x=3.5
+	c := AtObject(outer, o, tuple.Point(1.5, 0, 0))
+	if !c.Equals(color.Black) {
+		t.Errorf("wanted color=%v, got %v", color.Black, c)
+	}
+	// object space x=-3.5, outer pattern space x=0.5, inner pattern space x=0.25
+	c = AtObject(outer, o, tuple.Point(-1.75, 0, 0))
+	if !c.Equals(color.White) {
+		t.Errorf("wanted color=%v, got %v", color.White, c)
+	}
+}
+
+func TestGradientOfPatterns(t *testing.T) {
+	p := NewGradientOf(NewStripe(color.White, color.Black), NewSolid(color.Black))
+	c := p.PatternAt(tuple.Point(0.25, 0, 0))
+	if !c.Equals(color.New(0.75, 0.75, 0.75)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.75, 0.75, 0.75), c)
+	}
+}
+
+func TestBlendIsWeightedAverage(t *testing.T) {
+	p := NewBlend(NewSolid(color.New(1, 0, 0)), NewSolid(color.New(0, 0, 1)))
+	if c := p.PatternAt(tuple.Point(0, 0, 0)); !c.Equals(color.New(0.5, 0, 0.5)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.5, 0, 0.5), c)
+	}
+	p.Weight = 0.25
+	if c := p.PatternAt(tuple.Point(0, 0, 0)); !c.Equals(color.New(0.75, 0, 0.25)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.75, 0, 0.25), c)
+	}
+}
+
+func TestBlendUsesOwnTransform(t *testing.T) {
+	p := NewBlend(NewStripe(color.White, color.Black), NewSolid(color.White))
+	p.SetTransform(transforms.Translation(1, 0, 0))
+	if c := p.PatternAt(tuple.Point(0.5, 0, 0)); !c.Equals(color.White) {
+		t.Errorf("wanted color=%v, got %v", color.White, c)
+	}
+	if c := At(p, tuple.Point(0.5, 0, 0)); !c.Equals(color.New(0.5, 0.5, 0.5)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.5, 0.5, 0.5), c)
+	}
+}
+
+func TestMaskSelectsByBrightness(t *testing.T) {
+	red, blue := color.New(1, 0, 0), color.New(0, 0, 1)
+	p := NewMask(NewSolid(red), NewSolid(blue), NewStripe(color.Black, color.White))
+	if c := p.PatternAt(tuple.Point(0.5, 0, 0)); !c.Equals(red) {
+		t.Errorf("wanted color=%v, got %v", red, c)
+	}
+	if c := p.PatternAt(tuple.Point(1.5, 0, 0)); !c.Equals(blue) {
+		t.Errorf("wanted color=%v, got %v", blue, c)
+	}
+}
+
+func TestMultiplyAndAddPatterns(t *testing.T) {
+	a := NewSolid(color.New(0.5, 0.2, 1))
+	b := NewSolid(color.New(0.4, 0.5, 0.25))
+	if c := NewMultiply(a, b).PatternAt(tuple.Point(0, 0, 0)); !c.Equals(color.New(0.2, 0.1, 0.25)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.2, 0.1, 0.25), c)
+	}
+	if c := NewAdd(a, b).PatternAt(tuple.Point(0, 0, 0)); !c.Equals(color.New(0.9, 0.7, 1.25)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.9, 0.7, 1.25), c)
+	}
+}
+
+func TestUVCheckers(t *testing.T) {
+	p := NewUVCheckers(2, 2, color.Black, color.White)
+	tests := []struct {
+		u, v float64
+		want color.Color
+	}{
+		{0, 0, color.Black},
+		{0.5, 0, color.White},
+		{0, 0.5, color.White},
+		{0.5, 0.5, color.Black},
+		{1, 1, color.Black},
+	}
+	for _, test := range tests {
+		if c := p.UVPatternAt(test.u, test.v); !c.Equals(test.want) {
+			t.Errorf("wanted color at (%v,%v)=%v, got %v", test.u, test.v, test.want, c)
+		}
+	}
+}
+
+func TestSphericalMap(t *testing.T) {
+	tests := []struct {
+		point tuple.Tuple
+		u, v  float64
+	}{
+		{tuple.Point(0, 0, -1), 0, 0.5},
+		{tuple.Point(1, 0, 0), 0.25, 0.5},
+		{tuple.Point(0, 0, 1), 0.5, 0.5},
+		{tuple.Point(-1, 0, 0), 0.75, 0.5},
+		{tuple.Point(0, 1, 0), 0.5, 1},
+		{tuple.Point(0, -1, 0), 0.5, 0},
+		{tuple.Point(math.Sqrt2/2, math.Sqrt2/2, 0), 0.25, 0.75},
+	}
+	for _, test := range tests {
+		u, v := SphericalMap(test.point)
+		if math.Abs(u-test.u) > 1e-5 || math.Abs(v-test.v) > 1e-5 {
+			t.Errorf("wanted uv of %v=(%v,%v), got (%v,%v)", test.point, test.u, test.v, u, v)
+		}
+	}
+}
+
+func TestPlanarMap(t *testing.T) {
+	tests := []struct {
+		point tuple.Tuple
+		u, v  float64
+	}{
+		{tuple.Point(0.25, 0, 0.5), 0.25, 0.5},
+		{tuple.Point(0.25, 0, -0.25), 0.25, 0.75},
+		{tuple.Point(0.25, 0.5, -0.25), 0.25, 0.75},
+		{tuple.Point(1.25, 0, 0.5), 0.25, 0.5},
+		{tuple.Point(0.25, 0, -1.75), 0.25, 0.25},
+		{tuple.Point(1, 0, -1), 0, 0},
+		{tuple.Point(0, 0, 0), 0, 0},
+	}
+	for _, test := range tests {
+		u, v := PlanarMap(test.point)
+		if math.Abs(u-test.u) > 1e-5 || math.Abs(v-test.v) > 1e-5 {
+			t.Errorf("wanted uv of %v=(%v,%v), got (%v,%v)", test.point, test.u, test.v, u, v)
+		}
+	}
+}
+
+func TestCylindricalMap(t *testing.T) {
+	tests := []struct {
+		point tuple.Tuple
+		u, v  float64
+	}{
+		{tuple.Point(0, 0, -1), 0, 0},
+		{tuple.Point(0, 0.5, -1), 0, 0.5},
+		{tuple.Point(0, 1, -1), 0, 0},
+		{tuple.Point(0.70711, 0.5, -0.70711), 0.125, 0.5},
+		{tuple.Point(1, 0.5, 0), 0.25, 0.5},
+		{tuple.Point(0.70711, 0.5, 0.70711), 0.375, 0.5},
+		{tuple.Point(0, -0.25, 1), 0.5, 0.75},
+		{tuple.Point(-0.70711, 0.5, 0.70711), 0.625, 0.5},
+		{tuple.Point(-1, 1.25, 0), 0.75, 0.25},
+		{tuple.Point(-0.70711, 0.5, -0.70711), 0.875, 0.5},
+	}
+	for _, test := range tests {
+		u, v := CylindricalMap(test.point)
+		if math.Abs(u-test.u) > 1e-5 || math.Abs(v-test.v) > 1e-5 {
+			t.Errorf("wanted uv of %v=(%v,%v), got (%v,%v)", test.point, test.u, test.v, u, v)
+		}
+	}
+}
+
+func TestTextureMapWithSphericalMap(t *testing.T) {
+	p := NewTextureMap(NewUVCheckers(16, 8, color.Black, color.White), SphericalMap)
+	tests := []struct {
+		point tuple.Tuple
+		want  color.Color
+	}{
+		{tuple.Point(0.4315, 0.4670, 0.7719), color.White},
+		{tuple.Point(-0.9654, 0.2552, -0.0534), color.Black},
+		{tuple.Point(0.1039, 0.7090, 0.6975), color.White},
+		{tuple.Point(-0.4986, -0.7856, -0.3663), color.Black},
+		{tuple.Point(-0.0317, -0.9395, 0.3411), color.Black},
+		{tuple.Point(0.4809, -0.7721, 0.4154), color.Black},
+		{tuple.Point(0.0285, -0.9612, -0.2745), color.Black},
+		{tuple.Point(-0.5734, -0.2162, -0.7903), color.White},
+		{tuple.Point(0.7688, -0.1470, 0.6223), color.Black},
+		{tuple.Point(-0.7652, 0.2175, 0.6060), color.Black},
+	}
+	for _, test := range tests {
+		if c := p.PatternAt(test.point); !c.Equals(test.want) {
+			t.Errorf("wanted color at %v=%v, got %v", test.point, test.want, c)
+		}
+	}
+}
+
+func TestUVAlignCheck(t *testing.T) {
+	main, ul, ur := color.White, color.New(1, 0, 0), color.New(1, 1, 0)
+	bl, br := color.New(0, 1, 0), color.New(0, 1, 1)
+	p := NewUVAlignCheck(main, ul, ur, bl, br)
+	tests := []struct {
+		u, v float64
+		want color.Color
+	}{
+		{0.5, 0.5, main},
+		{0.1, 0.9, ul},
+		{0.9, 0.9, ur},
+		{0.1, 0.1, bl},
+		{0.9, 0.1, br},
+	}
+	for _, test := range tests {
+		if c := p.UVPatternAt(test.u, test.v); !c.Equals(test.want) {
+			t.Errorf("wanted color at (%v,%v)=%v, got %v", test.u, test.v, test.want, c)
+		}
+	}
+}
+
+func TestCubeUV(t *testing.T) {
+	tests := []struct {
+		point tuple.Tuple
+		face  int
+		u, v  float64
+	}{
+		{tuple.Point(-1, 0.5, -0.25), CubeLeft, 0.375, 0.75},
+		{tuple.Point(1.1, -0.75, 0.8), CubeRight, 0.13636, 0.15909},
+		{tuple.Point(0.1, 0.6, 0.9), CubeFront, 0.55556, 0.83333},
+		{tuple.Point(-0.7, 0, -2), CubeBack, 0.675, 0.5},
+		{tuple.Point(0.5, 1, 0.9), CubeUp, 0.75, 0.05},
+		{tuple.Point(-0.2, -1.3, 1.1), CubeDown, 0.42308, 0.92308},
+	}
+	for _, test := range tests {
+		face, u, v := CubeUV(test.point)
+		if face != test.face || math.Abs(u-test.u) > 1e-5 || math.Abs(v-test.v) > 1e-5 {
+			t.Errorf("wanted %v on face %v at (%v,%v), got face %v at (%v,%v)", test.point, test.face, test.u, test.v, face, u, v)
+		}
+	}
+}
+
+func TestCubeMapFaces(t *testing.T) {
+	red, yellow, brown := color.New(1, 0, 0), color.New(1, 1, 0), color.New(1, 0.5, 0)
+	green, cyan, blue := color.New(0, 1, 0), color.New(0, 1, 1), color.New(0, 0, 1)
+	purple, white := color.New(1, 0, 1), color.White
+	var faces [6]UVPattern
+	faces[CubeLeft] = NewUVAlignCheck(yellow, cyan, red, blue, brown)
+	faces[CubeFront] = NewUVAlignCheck(cyan, red, yellow, brown, green)
+	faces[CubeRight] = NewUVAlignCheck(red, yellow, purple, green, white)
+	faces[CubeBack] = NewUVAlignCheck(green, purple, cyan, white, blue)
+	faces[CubeUp] = NewUVAlignCheck(brown, cyan, purple, red, yellow)
+	faces[CubeDown] = NewUVAlignCheck(purple, brown, green, blue, white)
+	p := NewCubeMap(faces)
+	tests := []struct {
+		point tuple.Tuple
+		want  color.Color
+	}{
+		{tuple.Point(-1, 0, 0), yellow},
+		{tuple.Point(-1, 0.9, -0.9), cyan},
+		{tuple.Point(-1, 0.9, 0.9), red},
+		{tuple.Point(-1, -0.9, -0.9), blue},
+		{tuple.Point(-1, -0.9, 0.9), brown},
+		{tuple.Point(0, 0, 1), cyan},
+		{tuple.Point(1, 0.9, -0.9), purple},
+		{tuple.Point(0, 0, -1), green},
+		{tuple.Point(-0.9, 1, -0.9), cyan},
+		{tuple.Point(0.9, -1, 0.9), green},
+	}
+	for _, test := range tests {
+		if c := p.PatternAt(test.point); !c.Equals(test.want) {
+			t.Errorf("wanted color at %v=%v, got %v", test.point, test.want, c)
+		}
+	}
+}
+
+func TestUVImage(t *testing.T) {
+	c := canvas.New(10, 10)
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			v := float64(x+y) / 100
+			c.WritePixel(x, y, color.New(v, v, v))
+		}
+	}
+	p := NewUVImage(&c)
+	tests := []struct {
+		u, v float64
+		want float64
+	}{
+		{0, 0, 0.09},
+		{0.3, 0, 0.12},
+		{0.6, 0.3, 0.13},
+		{1, 1, 0.09},
+	}
+	for _, test := range tests {
+		if got := p.UVPatternAt(test.u, test.v); !got.Equals(color.New(test.want, test.want, test.want)) {
+			t.Errorf("wanted color at (%v,%v)=%v, got %v", test.u, test.v, test.want, got)
+		}
+	}
+}
+
+func TestUVImageBilinearFilter(t *testing.T) {
+	c := canvas.New(2, 1)
+	c.WritePixel(0, 0, color.Black)
+	c.WritePixel(1, 0, color.White)
+	p := NewUVImage(&c)
+	p.Filter = Bilinear
+	tests := []struct {
+		u    float64
+		want float64
+	}{
+		{0.25, 0},
+		{0.5, 0.5},
+		{0.625, 0.75},
+		{0.75, 1},
+		{1, 1},
+	}
+	for _, test := range tests {
+		if got := p.UVPatternAt(test.u, 0.5); !got.Equals(color.New(test.want, test.want, test.want)) {
+			t.Errorf("wanted color at u=%v=%v, got %v", test.u, test.want, got)
+		}
+	}
+}
+
+func TestUVImageWrapModes(t *testing.T) {
+	c := canvas.New(4, 1)
+	for x := 0; x < 4; x++ {
+		v := float64(x) / 4
+		c.WritePixel(x, 0, color.New(v, v, v))
+	}
+	tests := []struct {
+		wrap Wrap
+		u    float64
+		want float64
+	}{
+		{Clamp, -0.2, 0},
+		{Clamp, 1.3, 0.75},
+		{Repeat, 1.3, 0.25},
+		{Repeat, -0.1, 0.75},
+		{Mirror, 1.1, 0.75},
+		{Mirror, 1.6, 0.25},
+		{Mirror, -0.1, 0},
+		{Mirror, 2.1, 0},
+	}
+	for _, test := range tests {
+		p := NewUVImage(&c)
+		p.Wrap = test.wrap
+		if got := p.UVPatternAt(test.u, 0.5); !got.Equals(color.New(test.want, test.want, test.want)) {
+			t.Errorf("wanted color at u=%v with wrap %v=%v, got %v", test.u, test.wrap, test.want, got)
+		}
+	}
+}
+
+func TestUVImageMipmaps(t *testing.T) {
+	c := canvas.New(4, 2)
+	c.WritePixel(0, 0, color.White)
+	c.WritePixel(3, 1, color.New(1, 0, 0))
+	levels := NewUVImage(&c).Mipmaps()
+	sizes := [][2]int{{4, 2}, {2, 1}, {1, 1}}
+	if len(levels) != len(sizes) {
+		t.Fatalf("wanted %v mip-map levels, got %v", len(sizes), len(levels))
+	}
+	for i, size := range sizes {
+		if levels[i].Width() != size[0] || levels[i].Height() != size[1] {
+			t.Errorf("wanted level %v to be %vx%v, got %vx%v", i, size[0], size[1], levels[i].Width(), levels[i].Height())
+		}
+	}
+	if !levels[1].Pixels[0][0].Equals(color.New(0.25, 0.25, 0.25)) {
+		t.Errorf("wanted averaged pixel=%v, got %v", color.New(0.25, 0.25, 0.25), levels[1].Pixels[0][0])
+	}
+	if !levels[2].Pixels[0][0].Equals(color.New(0.25, 0.125, 0.125)) {
+		t.Errorf("wanted averaged pixel=%v, got %v", color.New(0.25, 0.125, 0.125), levels[2].Pixels[0][0])
+	}
+}
+
+func TestUVImageTrilinearFilter(t *testing.T) {
+	c := canvas.New(2, 2)
+	c.WritePixel(0, 0, color.White)
+	p := NewUVImage(&c)
+	p.Filter = Trilinear
+	tests := []struct {
+		level float64
+		want  float64
+	}{
+		{0, 1},
+		{0.5, 0.625},
+		{1, 0.25},
+		{4, 0.25},
+	}
+	for _, test := range tests {
+		p.Level = test.level
+		if got := p.UVPatternAt(0.25, 0.75); !got.Equals(color.New(test.want, test.want, test.want)) {
+			t.Errorf("wanted color at level %v=%v, got %v", test.level, test.want, got)
+		}
+	}
+}
+
+func TestLoadUVImage(t *testing.T) {
+	c := canvas.New(2, 2)
+	c.WritePixel(1, 0, color.New(1, 0, 0))
+	path := filepath.Join(t.TempDir(), "texture.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ToPNG(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	p, err := LoadUVImage(path)
+	if err != nil {
+		t.Fatalf("wanted texture to load, got %v", err)
+	}
+	if got := p.UVPatternAt(0.75, 0.75); !got.Equals(color.New(1, 0, 0)) {
+		t.Errorf("wanted color=%v, got %v", color.New(1, 0, 0), got)
+	}
+	if _, err := LoadUVImage(filepath.Join(t.TempDir(), "missing.ppm")); err == nil {
+		t.Errorf("wanted an error loading a missing texture")
+	}
+}
+
+type testObject struct {
+	Transform *matrix.Matrix
+}
+
+func newTestObject() *testObject {
+	return &testObject{Transform: matrix.Identity}
+}
+
+func (o *testObject) GetTransform() *matrix.Matrix {
+	return o.Transform
+}
+
+func (o *testObject) SetTransform(m *matrix.Matrix) {
+	o.Transform = m
+}
+
+func TestNoisePatternIsMidpointOnLattice(t *testing.T) {
+	p := NewNoise(color.Black, color.White)
+	if c := p.PatternAt(tuple.Point(3, -2, 7)); !c.Equals(color.New(0.5, 0.5, 0.5)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.5, 0.5, 0.5), c)
+	}
+	if c := p.PatternAt(tuple.Point(0.3, 0.6, 0.2)); c.Equals(color.New(0.5, 0.5, 0.5)) {
+		t.Errorf("wanted noise to vary between lattice points, got %v", c)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	red, blue := color.New(1, 0, 0), color.New(0, 0, 1)
+	image := canvas.New(2, 2)
+	image.WritePixel(1, 0, red)
+	image.WriteAlpha(0, 1, 0.5)
+	uvImage := NewUVImage(&image)
+	uvImage.Filter, uvImage.Wrap, uvImage.Level = Trilinear, Mirror, 0.5
+	noisy := NewNoise(red, blue)
+	noisy.Func = noise.Simplex
+	perturbed := NewPerturbed(NewRing(red, blue), 0.2)
+	perturbed.Octaves = 3
+	blend := NewBlend(NewSolid(red), NewGradient(red, blue))
+	blend.Weight = 0.25
+	checkers := NewUVCheckers(2, 2, red, blue)
+	patterns := []Pattern{
+		NewSolid(red),
+		NewStripeOf(NewCheckers(red, blue), NewSolid(blue)),
+		NewGradient(red, blue),
+		NewRing(red, blue),
+		NewCheckers(red, blue),
+		NewRadialGradient(red, blue),
+		NewMarble(red, blue),
+		NewWood(red, blue),
+		NewGranite(red, blue),
+		NewCellular(red, blue),
+		noisy,
+		blend,
+		NewMask(NewSolid(red), NewSolid(blue), NewStripe(color.White, color.Black)),
+		NewMultiply(NewSolid(red), NewRing(red, blue)),
+		NewAdd(NewSolid(red), NewSolid(blue)),
+		perturbed,
+		NewTextureMap(checkers, CylindricalMap),
+		NewTextureMap(uvImage, SphericalMap),
+		NewCubeMap([6]UVPattern{checkers, NewUVAlignCheck(red, blue, red, blue, red), uvImage, checkers, checkers, checkers}),
+	}
+	points := []tuple.Tuple{tuple.Point(0.1, 0.2, 0.3), tuple.Point(0.3, -0.7, 1.2), tuple.Point(-2.5, 0.4, 0.9)}
+	for _, p := range patterns {
+		p.SetTransform(transforms.Chain(transforms.RotationY(0.3), transforms.Scaling(2, 1, 0.5)))
+		data, err := Marshal(p)
+		if err != nil {
+			t.Fatalf("%T: %v", p, err)
+		}
+		q, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("%T: %v", p, err)
+		}
+		again, err := Marshal(q)
+		if err != nil {
+			t.Fatalf("%T: %v", p, err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Errorf("wanted %T to encode the same after a round trip, got %s and %s", p, data, again)
+		}
+		for _, point := range points {
+			if a, b := At(p, point), At(q, point); !a.Equals(*b) {
+				t.Errorf("wanted %T color at %v=%v, got %v", p, point, a, b)
+			}
+		}
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	if _, err := Marshal(&unregistered{}); err == nil {
+		t.Errorf("wanted an error for an unregistered pattern type")
+	}
+	p := NewNoise(color.Black, color.White)
+	p.Func = func(x, y, z float64) float64 { return 0 }
+	if _, err := Marshal(p); err == nil {
+		t.Errorf("wanted an error for a noise function that is not built in")
+	}
+	if _, err := Unmarshal([]byte(`{"type":"spots"}`)); err == nil {
+		t.Errorf("wanted an error for an unknown pattern type")
+	}
+	if p, err := Unmarshal([]byte("null")); p != nil || err != nil {
+		t.Errorf("wanted null to decode to no pattern, got %v, %v", p, err)
+	}
+}
+
+func TestUnmarshalIncompletePatterns(t *testing.T) {
+	solid := `{"type":"solid","color":[1,1,1]}`
+	face := `{"type":"checkers","width":2,"height":2,"a":[0,0,0],"b":[1,1,1]}`
+	tests := []struct {
+		json string
+		want string
+	}{
+		{`{"type":"stripes","a":` + solid + `}`, "pattern: missing b"},
+		{`{"type":"checkers","a":null,"b":` + solid + `}`, "pattern: missing a"},
+		{`{"type":"multiply","a":` + solid + `,"b":null}`, "pattern: missing b"},
+		{`{"type":"blend","a":` + solid + `}`, "pattern: missing b"},
+		{`{"type":"mask","a":` + solid + `,"b":` + solid + `,"mask":null}`, "pattern: missing mask"},
+		{`{"type":"perturbed","scale":1}`, "pattern: missing pattern"},
+		{`{"type":"texture-map","mapping":"planar"}`, "pattern: missing uv_pattern"},
+		{`{"type":"texture-map","uv_pattern":` + face + `}`, "pattern: texture map is missing its mapping"},
+		{`{"type":"cube-map","faces":[` + strings.Repeat(face+",", 5) + `null]}`, "pattern: missing face 5"},
+	}
+	for _, test := range tests {
+		if _, err := Unmarshal([]byte(test.json)); err == nil || err.Error() != test.want {
+			t.Errorf("wanted error %q for %s, got %v", test.want, test.json, err)
+		}
+	}
+
+	for _, data := range []string{
+		`{"type":"perturbed","pattern":` + solid + `,"scale":0.5,"octaves":1}`,
+		`{"type":"noise","a":[0,0,0],"b":[1,1,1],"scale":1,"octaves":1}`,
+	} {
+		p, err := Unmarshal([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c := At(p, tuple.Point(0.1, 0.2, 0.3)); c == nil {
+			t.Errorf("wanted a color from %s", data)
+		}
+	}
+}
+
+func TestMarshalSolidPattern(t *testing.T) {
+	data, err := Marshal(NewSolid(color.New(1, 0.5, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"solid","color":[1,0.5,0],"transform":[[1,0,0,0],[0,1,0,0],[0,0,1,0],[0,0,0,1]]}`
+	if string(data) != want {
+		t.Errorf("wanted %s, got %s", want, data)
+	}
+}
+
+type unregistered struct {
+	Solid
+}
@@ -0,0 +1,47 @@
+package pattern
+
+import (
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/matrix"
+	"github.com/calbim/ray-tracer/src/noise"
+	"github.com/calbim/ray-tracer/src/tuple"
+)
+
+//Perturbed pattern jitters the point at which an inner pattern is looked up
+//using 3D noise, so that regular patterns look organic
+type Perturbed struct {
+	Pattern   Pattern
+	Scale     float64    //how far points are moved
+	Octaves   int        //number of fBm octaves of noise
+	Noise     noise.Func //noise.Perlin by default
+	Transform *matrix.Matrix
+	inverse
+}
+
+//NewPerturbed returns a perturbed pattern that moves points of p by up to
+//scale units
+func NewPerturbed(p Pattern, scale float64) *Perturbed {
+	return &Perturbed{
+		Pattern:   p,
+		Scale:     scale,
+		Octaves:   1,
+		Noise:     noise.Perlin,
+		Transform: matrix.Identity,
+	}
+}
+
+//GetTransform returns a perturbed pattern's transformation matrix
+func (p *Perturbed) GetTransform() *matrix.Matrix {
+	return p.Transform
+}
+
+//SetTransform sets a perturbed pattern's transformation matrix
+func (p *Perturbed) SetTransform(m *matrix.Matrix) {
+	p.Transform = m
+	p.setInverse(m)
+}
+
+//PatternAt returns the color of the inner pattern at a jittered point
+func (p *Perturbed) PatternAt(point tuple.Tuple) *color.Color {
+	return At(p.Pattern, jitter(p.Noise, point, p.Scale, p.Octaves))
+}
@@ -0,0 +1,210 @@
+package pattern
+
+import (
+	"math"
+
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/matrix"
+	"github.com/calbim/ray-tracer/src/noise"
+	"github.com/calbim/ray-tracer/src/tuple"
+)
+
+//Marble pattern of veins of color b running through color a along x
+type Marble struct {
+	a          color.Color
+	b          color.Color
+	Scale      float64 //number of veins per unit
+	Turbulence float64 //how far the veins wander
+	Octaves    int
+	Transform  *matrix.Matrix
+	inverse
+}
+
+//Wood pattern of concentric growth rings around the y axis
+type Wood struct {
+	a          color.Color
+	b          color.Color
+	Scale      float64 //number of rings per unit
+	Turbulence float64 //how irregular the rings are
+	Octaves    int
+	Transform  *matrix.Matrix
+	inverse
+}
+
+//Granite pattern of specks of color b scattered over color a
+type Granite struct {
+	a          color.Color
+	b          color.Color
+	Scale      float64 //frequency of the specks
+	Turbulence float64 //how far the lookup point is jittered
+	Octaves    int
+	Transform  *matrix.Matrix
+	inverse
+}
+
+//Cellular pattern based on Worley noise, blending from color a at the
+//center of each cell to color b at its edges
+type Cellular struct {
+	a          color.Color
+	b          color.Color
+	Scale      float64 //number of cells per unit
+	Turbulence float64 //how far the lookup point is jittered
+	Octaves    int
+	Transform  *matrix.Matrix
+	inverse
+}
+
+//Noise pattern blends from color a to color b with the fBm of a noise
+//function, which makes it useful as a bump map
+type Noise struct {
+	a         color.Color
+	b         color.Color
+	Func      noise.Func
+	Scale     float64 //frequency of the noise
+	Octaves   int
+	Transform *matrix.Matrix
+	inverse
+}
+
+//NewMarble returns a marble pattern
+func NewMarble(a color.Color, b color.Color) *Marble {
+	return &Marble{a: a, b: b, Scale: 1, Turbulence: 2, Octaves: 4, Transform: matrix.Identity}
+}
+
+//GetTransform returns a marble pattern's transformation matrix
+func (p *Marble) GetTransform() *matrix.Matrix {
+	return p.Transform
+}
+
+//SetTransform sets a marble pattern's transformation matrix
+func (p *Marble) SetTransform(m *matrix.Matrix) {
+	p.Transform = m
+	p.setInverse(m)
+}
+
+//PatternAt returns the color of a marble pattern at a point
+func (p *Marble) PatternAt(point tuple.Tuple) *color.Color {
+	point = point.Multiply(p.Scale)
+	t := noise.Turbulence(noise.Perlin, point.X, point.Y, point.Z, p.Octaves)
+	v := (1 + math.Sin((point.X+p.Turbulence*t)*math.Pi)) / 2
+	return mix(p.a, p.b, v)
+}
+
+//NewWood returns a wood pattern
+func NewWood(a color.Color, b color.Color) *Wood {
+	return &Wood{a: a, b: b, Scale: 4, Turbulence: 0.1, Octaves: 2, Transform: matrix.Identity}
+}
+
+//GetTransform returns a wood pattern's transformation matrix
+func (p *Wood) GetTransform() *matrix.Matrix {
+	return p.Transform
+}
+
+//SetTransform sets a wood pattern's transformation matrix
+func (p *Wood) SetTransform(m *matrix.Matrix) {
+	p.Transform = m
+	p.setInverse(m)
+}
+
+//PatternAt returns the color of a wood pattern at a point
+func (p *Wood) PatternAt(point tuple.Tuple) *color.Color {
+	t := noise.Turbulence(noise.Perlin, point.X, point.Y, point.Z, p.Octaves)
+	r := math.Sqrt(point.X*point.X+point.Z*point.Z)*p.Scale + p.Turbulence*t*p.Scale
+	return mix(p.a, p.b, r-math.Floor(r))
+}
+
+//NewGranite returns a granite pattern
+func NewGranite(a color.Color, b color.Color) *Granite {
+	return &Granite{a: a, b: b, Scale: 8, Turbulence: 0, Octaves: 4, Transform: matrix.Identity}
+}
+
+//GetTransform returns a granite pattern's transformation matrix
+func (p *Granite) GetTransform() *matrix.Matrix {
+	return p.Transform
+}
+
+//SetTransform sets a granite pattern's transformation matrix
+func (p *Granite) SetTransform(m *matrix.Matrix) {
+	p.Transform = m
+	p.setInverse(m)
+}
+
+//PatternAt returns the color of a granite pattern at a point
+func (p *Granite) PatternAt(point tuple.Tuple) *color.Color {
+	point = jitter(noise.Perlin, point.Multiply(p.Scale), p.Turbulence, p.Octaves)
+	t := noise.Turbulence(noise.Simplex, point.X, point.Y, point.Z, p.Octaves)
+	return mix(p.a, p.b, smoothstep(0.2, 0.4, t))
+}
+
+//NewCellular returns a cellular pattern
+func NewCellular(a color.Color, b color.Color) *Cellular {
+	return &Cellular{a: a, b: b, Scale: 1, Turbulence: 0, Octaves: 2, Transform: matrix.Identity}
+}
+
+//GetTransform returns a cellular pattern's transformation matrix
+func (p *Cellular) GetTransform() *matrix.Matrix {
+	return p.Transform
+}
+
+//SetTransform sets a cellular pattern's transformation matrix
+func (p *Cellular) SetTransform(m *matrix.Matrix) {
+	p.Transform = m
+	p.setInverse(m)
+}
+
+//PatternAt returns the color of a cellular pattern at a point
+func (p *Cellular) PatternAt(point tuple.Tuple) *color.Color {
+	point = jitter(noise.Perlin, point.Multiply(p.Scale), p.Turbulence, p.Octaves)
+	f1, _ := noise.Worley(point.X, point.Y, point.Z)
+	return mix(p.a, p.b, f1)
+}
+
+//NewNoise returns a pattern of Perlin noise
+func NewNoise(a color.Color, b color.Color) *Noise {
+	return &Noise{a: a, b: b, Func: noise.Perlin, Scale: 1, Octaves: 1, Transform: matrix.Identity}
+}
+
+//GetTransform returns a noise pattern's transformation matrix
+func (p *Noise) GetTransform() *matrix.Matrix {
+	return p.Transform
+}
+
+//SetTransform sets a noise pattern's transformation matrix
+func (p *Noise) SetTransform(m *matrix.Matrix) {
+	p.Transform = m
+	p.setInverse(m)
+}
+
+//PatternAt returns the color of a noise pattern at a point
+func (p *Noise) PatternAt(point tuple.Tuple) *color.Color {
+	point = point.Multiply(p.Scale)
+	n := noise.FBM(p.Func, point.X, point.Y, point.Z, p.Octaves)
+	return mix(p.a, p.b, (n+1)/2)
+}
+
+//jitter moves a point by up to amount units using fBm of noise n. Each axis
+//looks the noise up at an offset so the three displacements are unrelated.
+func jitter(n noise.Func, point tuple.Tuple, amount float64, octaves int) tuple.Tuple {
+	if amount == 0 {
+		return point
+	}
+	d := tuple.Vector(
+		noise.FBM(n, point.X, point.Y, point.Z, octaves),
+		noise.FBM(n, point.X+31.7, point.Y+17.3, point.Z+5.1, octaves),
+		noise.FBM(n, point.X+11.9, point.Y+43.1, point.Z+23.3, octaves))
+	return point.Add(d.Multiply(amount))
+}
+
+//mix returns the blend of a and b, with t clamped to [0,1]
+func mix(a color.Color, b color.Color, t float64) *color.Color {
+	t = math.Max(0, math.Min(1, t))
+	diff := b.Subtract(a)
+	c := a.Add(diff.Multiply(t))
+	return &c
+}
+
+//smoothstep eases from 0 at edge0 to 1 at edge1
+func smoothstep(edge0, edge1, x float64) float64 {
+	t := math.Max(0, math.Min(1, (x-edge0)/(edge1-edge0)))
+	return t * t * (3 - 2*t)
+}
@@ -0,0 +1,187 @@
+package pattern
+
+import (
+	"math"
+
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/matrix"
+	"github.com/calbim/ray-tracer/src/tuple"
+)
+
+//Cube faces, in the order expected by NewCubeMap
+const (
+	CubeRight = iota //+x
+	CubeLeft         //-x
+	CubeUp           //+y
+	CubeDown         //-y
+	CubeFront        //+z
+	CubeBack         //-z
+)
+
+//UVPattern is a 2D pattern evaluated at texture coordinates u and v, which
+//run from 0 to 1 across the texture with v pointing up
+type UVPattern interface {
+	UVPatternAt(u, v float64) *color.Color
+}
+
+//Mapping converts a point in pattern space to texture coordinates
+type Mapping func(point tuple.Tuple) (float64, float64)
+
+//SphericalMap maps a point on a unit sphere to texture coordinates, with u
+//running around the y axis and v from the south pole to the north pole
+func SphericalMap(point tuple.Tuple) (float64, float64) {
+	theta := math.Atan2(point.X, point.Z)
+	radius := math.Sqrt(point.X*point.X + point.Y*point.Y + point.Z*point.Z)
+	phi := math.Acos(math.Max(-1, math.Min(1, point.Y/radius)))
+	rawU := theta / (2 * math.Pi)
+	return 1 - (rawU + 0.5), 1 - phi/math.Pi
+}
+
+//PlanarMap maps a point to texture coordinates by tiling the xz plane with
+//unit squares
+func PlanarMap(point tuple.Tuple) (float64, float64) {
+	return fract(point.X), fract(point.Z)
+}
+
+//CylindricalMap maps a point on a unit cylinder around the y axis to texture
+//coordinates, repeating every unit in y
+func CylindricalMap(point tuple.Tuple) (float64, float64) {
+	theta := math.Atan2(point.X, point.Z)
+	rawU := theta / (2 * math.Pi)
+	return 1 - (rawU + 0.5), fract(point.Y)
+}
+
+//CubeUV returns the face of a cube centered on the origin that a point (or
+//direction) falls on, and the texture coordinates of the point on that face
+func CubeUV(point tuple.Tuple) (int, float64, float64) {
+	ax, ay, az := math.Abs(point.X), math.Abs(point.Y), math.Abs(point.Z)
+	switch {
+	case ax >= ay && ax >= az && point.X > 0:
+		return CubeRight, (1 - point.Z/ax) / 2, (point.Y/ax + 1) / 2
+	case ax >= ay && ax >= az:
+		return CubeLeft, (point.Z/ax + 1) / 2, (point.Y/ax + 1) / 2
+	case ay >= az && point.Y > 0:
+		return CubeUp, (point.X/ay + 1) / 2, (1 - point.Z/ay) / 2
+	case ay >= az:
+		return CubeDown, (point.X/ay + 1) / 2, (point.Z/ay + 1) / 2
+	case point.Z > 0:
+		return CubeFront, (point.X/az + 1) / 2, (point.Y/az + 1) / 2
+	default:
+		return CubeBack, (1 - point.X/az) / 2, (point.Y/az + 1) / 2
+	}
+}
+
+//TextureMap pattern wraps a 2D pattern onto a surface using a mapping
+type TextureMap struct {
+	UVPattern UVPattern
+	Mapping   Mapping
+	Transform *matrix.Matrix
+	inverse
+}
+
+//CubeMap pattern wraps a separate 2D pattern onto each face of a cube
+type CubeMap struct {
+	Faces     [6]UVPattern
+	Transform *matrix.Matrix
+	inverse
+}
+
+//UVCheckers pattern of width by height squares alternating between a and b
+type UVCheckers struct {
+	Width  float64
+	Height float64
+	a      color.Color
+	b      color.Color
+}
+
+//UVAlignCheck pattern is a main color with a different color in each
+//corner, to check the orientation of a mapping
+type UVAlignCheck struct {
+	Main        color.Color
+	UpperLeft   color.Color
+	UpperRight  color.Color
+	BottomLeft  color.Color
+	BottomRight color.Color
+}
+
+//NewTextureMap returns a pattern applying uv to a surface with mapping m
+func NewTextureMap(uv UVPattern, m Mapping) *TextureMap {
+	return &TextureMap{UVPattern: uv, Mapping: m, Transform: matrix.Identity}
+}
+
+//GetTransform returns a texture map's transformation matrix
+func (p *TextureMap) GetTransform() *matrix.Matrix {
+	return p.Transform
+}
+
+//SetTransform sets a texture map's transformation matrix
+func (p *TextureMap) SetTransform(m *matrix.Matrix) {
+	p.Transform = m
+	p.setInverse(m)
+}
+
+//PatternAt returns the color of a texture map at a point
+func (p *TextureMap) PatternAt(point tuple.Tuple) *color.Color {
+	u, v := p.Mapping(point)
+	return p.UVPattern.UVPatternAt(u, v)
+}
+
+//NewCubeMap returns a pattern applying a 2D pattern to each face of a cube,
+//indexed by CubeRight, CubeLeft, CubeUp, CubeDown, CubeFront and CubeBack
+func NewCubeMap(faces [6]UVPattern) *CubeMap {
+	return &CubeMap{Faces: faces, Transform: matrix.Identity}
+}
+
+//GetTransform returns a cube map's transformation matrix
+func (p *CubeMap) GetTransform() *matrix.Matrix {
+	return p.Transform
+}
+
+//SetTransform sets a cube map's transformation matrix
+func (p *CubeMap) SetTransform(m *matrix.Matrix) {
+	p.Transform = m
+	p.setInverse(m)
+}
+
+//PatternAt returns the color of a cube map at a point
+func (p *CubeMap) PatternAt(point tuple.Tuple) *color.Color {
+	face, u, v := CubeUV(point)
+	return p.Faces[face].UVPatternAt(u, v)
+}
+
+//NewUVCheckers returns a checkers pattern in texture space
+func NewUVCheckers(width float64, height float64, a color.Color, b color.Color) *UVCheckers {
+	return &UVCheckers{Width: width, Height: height, a: a, b: b}
+}
+
+//UVPatternAt returns the color of UV checkers at (u,v)
+func (p *UVCheckers) UVPatternAt(u, v float64) *color.Color {
+	if int(math.Floor(u*p.Width)+math.Floor(v*p.Height))%2 == 0 {
+		return &p.a
+	}
+	return &p.b
+}
+
+//NewUVAlignCheck returns an align check pattern
+func NewUVAlignCheck(main, ul, ur, bl, br color.Color) *UVAlignCheck {
+	return &UVAlignCheck{Main: main, UpperLeft: ul, UpperRight: ur, BottomLeft: bl, BottomRight: br}
+}
+
+//UVPatternAt returns the color of an align check pattern at (u,v)
+func (p *UVAlignCheck) UVPatternAt(u, v float64) *color.Color {
+	switch {
+	case v > 0.8 && u < 0.2:
+		return &p.UpperLeft
+	case v > 0.8 && u > 0.8:
+		return &p.UpperRight
+	case v < 0.2 && u < 0.2:
+		return &p.BottomLeft
+	case v < 0.2 && u > 0.8:
+		return &p.BottomRight
+	}
+	return &p.Main
+}
+
+func fract(x float64) float64 {
+	return x - math.Floor(x)
+}
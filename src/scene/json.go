@@ -0,0 +1,60 @@
+package scene
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"github.com/calbim/ray-tracer/src/camera"
+)
+
+//Marshal encodes a scene as indented JSON that Unmarshal reads back
+//unchanged. Images used by patterns and backgrounds are included pixel by
+//pixel.
+func Marshal(s *Scene) ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+//Unmarshal decodes a scene written by Marshal. The camera's pixel size and
+//half width and height are worked out again from its size and field of view.
+//Like a parsed scene, it must have a light.
+func Unmarshal(data []byte) (*Scene, error) {
+	s := &Scene{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.World.Light == nil {
+		return nil, &Error{Msg: "scene has no light"}
+	}
+	c := s.Camera
+	if c.HSize <= 0 || c.VSize <= 0 || c.FieldOfView <= 0 {
+		return nil, errors.New("scene: camera hsize, vsize and field_of_view must be positive")
+	}
+	s.Camera = camera.New(c.HSize, c.VSize, c.FieldOfView)
+	if c.Transform != nil {
+		s.Camera.Transform = c.Transform
+	}
+	return s, nil
+}
+
+//Save writes a scene to a JSON file
+func Save(s *Scene, path string) error {
+	data, err := Marshal(s)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func loadJSON(path string) (*Scene, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Unmarshal(data)
+	if err != nil {
+		return nil, &Error{File: path, Msg: err.Error()}
+	}
+	s.Files = []string{path}
+	return s, nil
+}
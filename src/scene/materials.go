@@ -0,0 +1,525 @@
+package scene
+
+import (
+	"github.com/calbim/ray-tracer/src/canvas"
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/material"
+	"github.com/calbim/ray-tracer/src/noise"
+	"github.com/calbim/ray-tracer/src/pattern"
+	"gopkg.in/yaml.v3"
+)
+
+func (p *parser) material(n *yaml.Node) (material.Material, error) {
+	m := material.New()
+	if _, err := p.fields(n, "color", "ambient", "diffuse", "specular", "shininess", "reflective",
+		"pattern", "bump", "bump-scale", "normal-map", "emission", "light-samples",
+		"model", "metallic", "roughness"); err != nil {
+		return m, err
+	}
+	scalars := map[string]*float64{
+		"ambient":    &m.Ambient,
+		"diffuse":    &m.Diffuse,
+		"specular":   &m.Specular,
+		"shininess":  &m.Shininess,
+		"reflective": &m.Reflective,
+		"bump-scale": &m.BumpScale,
+		"metallic":   &m.Metallic,
+		"roughness":  &m.Roughness,
+	}
+	colors := map[string]*color.Color{
+		"color":    &m.Color,
+		"emission": &m.Emission,
+	}
+	patterns := map[string]*pattern.Pattern{
+		"bump":       &m.Bump,
+		"normal-map": &m.NormalMap,
+	}
+	n = p.resolve(n)
+	for i := 0; i < len(n.Content); i += 2 {
+		key, v := n.Content[i].Value, n.Content[i+1]
+		var err error
+		switch {
+		case scalars[key] != nil:
+			*scalars[key], err = p.float(v, key)
+		case colors[key] != nil:
+			*colors[key], err = p.color(v, key)
+		case patterns[key] != nil:
+			*patterns[key], err = p.pattern(v)
+		case key == "pattern":
+			var pat pattern.Pattern
+			if pat, err = p.pattern(v); err == nil {
+				m.SetPattern(pat)
+			}
+		case key == "light-samples":
+			m.LightSamples, err = p.int(v, key)
+		case key == "model":
+			m.Model, err = p.model(v)
+		}
+		if err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+func (p *parser) model(n *yaml.Node) (material.Model, error) {
+	name, err := p.str(n, "model")
+	if err != nil {
+		return 0, err
+	}
+	switch name {
+	case "phong":
+		return material.Phong, nil
+	case "metallic-roughness":
+		return material.MetallicRoughness, nil
+	}
+	return 0, p.errorf(n, "model", "unknown model %q, expected phong or metallic-roughness", name)
+}
+
+//pattern returns the pattern described by a mapping with a type key
+func (p *parser) pattern(n *yaml.Node) (pattern.Pattern, error) {
+	if err := p.expired(n); err != nil {
+		return nil, err
+	}
+	n, err := p.enter(n, "pattern")
+	if err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	typ := lookup(n, "type")
+	if typ == nil {
+		return nil, p.errorf(n, "type", "missing key")
+	}
+	name, err := p.str(typ, "type")
+	if err != nil {
+		return nil, err
+	}
+	var pat pattern.Pattern
+	switch name {
+	case "solid":
+		pat, err = p.solid(n)
+	case "stripes", "gradient", "rings", "checkers", "radial-gradient":
+		pat, err = p.pair(n, name)
+	case "marble", "wood", "granite", "cellular", "noise":
+		pat, err = p.procedural(n, name)
+	case "blend", "mask", "multiply", "add":
+		pat, err = p.combination(n, name)
+	case "perturbed":
+		pat, err = p.perturbed(n)
+	case "map":
+		pat, err = p.textureMap(n)
+	default:
+		return nil, p.errorf(typ, "type", "unknown pattern %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t := lookup(n, "transform"); t != nil {
+		m, err := p.transform(t)
+		if err != nil {
+			return nil, err
+		}
+		pat.SetTransform(m)
+	}
+	return pat, nil
+}
+
+func (p *parser) solid(n *yaml.Node) (pattern.Pattern, error) {
+	fields, err := p.fields(n, "type", "transform", "color")
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.require(n, fields, "color")
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.color(v, "color")
+	if err != nil {
+		return nil, err
+	}
+	return pattern.NewSolid(c), nil
+}
+
+//pair returns one of the patterns alternating between two colors or
+//patterns
+func (p *parser) pair(n *yaml.Node, name string) (pattern.Pattern, error) {
+	fields, err := p.fields(n, "type", "transform", "colors")
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.require(n, fields, "colors")
+	if err != nil {
+		return nil, err
+	}
+	v = p.resolve(v)
+	if v.Kind != yaml.SequenceNode || len(v.Content) != 2 {
+		return nil, p.errorf(v, "colors", "expected a list of two colors or patterns")
+	}
+	var sub [2]pattern.Pattern
+	for i, c := range v.Content {
+		c = p.resolve(c)
+		if c.Kind == yaml.MappingNode {
+			sub[i], err = p.pattern(c)
+		} else {
+			var col color.Color
+			col, err = p.color(c, "colors")
+			sub[i] = pattern.NewSolid(col)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch name {
+	case "stripes":
+		return pattern.NewStripeOf(sub[0], sub[1]), nil
+	case "gradient":
+		return pattern.NewGradientOf(sub[0], sub[1]), nil
+	case "rings":
+		return pattern.NewRingOf(sub[0], sub[1]), nil
+	case "checkers":
+		return pattern.NewCheckersOf(sub[0], sub[1]), nil
+	}
+	return pattern.NewRadialGradientOf(sub[0], sub[1]), nil
+}
+
+func (p *parser) procedural(n *yaml.Node, name string) (pattern.Pattern, error) {
+	fields, err := p.fields(n, "type", "transform", "colors", "scale", "turbulence", "octaves")
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.require(n, fields, "colors")
+	if err != nil {
+		return nil, err
+	}
+	colors, err := p.colors(v, "colors", 2)
+	if err != nil {
+		return nil, err
+	}
+	a, b := colors[0], colors[1]
+	var scale, turbulence *float64
+	var octaves *int
+	var pat pattern.Pattern
+	switch name {
+	case "marble":
+		m := pattern.NewMarble(a, b)
+		scale, turbulence, octaves, pat = &m.Scale, &m.Turbulence, &m.Octaves, m
+	case "wood":
+		w := pattern.NewWood(a, b)
+		scale, turbulence, octaves, pat = &w.Scale, &w.Turbulence, &w.Octaves, w
+	case "granite":
+		g := pattern.NewGranite(a, b)
+		scale, turbulence, octaves, pat = &g.Scale, &g.Turbulence, &g.Octaves, g
+	case "cellular":
+		c := pattern.NewCellular(a, b)
+		scale, turbulence, octaves, pat = &c.Scale, &c.Turbulence, &c.Octaves, c
+	default:
+		if fields["turbulence"] != nil {
+			return nil, p.errorf(fields["turbulence"], "turbulence", "not used by noise patterns")
+		}
+		s := pattern.NewNoise(a, b)
+		scale, turbulence, octaves, pat = &s.Scale, new(float64), &s.Octaves, s
+	}
+	if v := fields["scale"]; v != nil {
+		if *scale, err = p.float(v, "scale"); err != nil {
+			return nil, err
+		}
+	}
+	if v := fields["turbulence"]; v != nil {
+		if *turbulence, err = p.float(v, "turbulence"); err != nil {
+			return nil, err
+		}
+	}
+	if v := fields["octaves"]; v != nil {
+		if *octaves, err = p.int(v, "octaves"); err != nil {
+			return nil, err
+		}
+	}
+	return pat, nil
+}
+
+func (p *parser) combination(n *yaml.Node, name string) (pattern.Pattern, error) {
+	allowed := []string{"type", "transform", "patterns"}
+	switch name {
+	case "blend":
+		allowed = append(allowed, "weight")
+	case "mask":
+		allowed = append(allowed, "mask")
+	}
+	fields, err := p.fields(n, allowed...)
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.require(n, fields, "patterns")
+	if err != nil {
+		return nil, err
+	}
+	v = p.resolve(v)
+	if v.Kind != yaml.SequenceNode || len(v.Content) != 2 {
+		return nil, p.errorf(v, "patterns", "expected a list of two patterns")
+	}
+	a, err := p.pattern(v.Content[0])
+	if err != nil {
+		return nil, err
+	}
+	b, err := p.pattern(v.Content[1])
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "blend":
+		blend := pattern.NewBlend(a, b)
+		if w := fields["weight"]; w != nil {
+			if blend.Weight, err = p.float(w, "weight"); err != nil {
+				return nil, err
+			}
+		}
+		return blend, nil
+	case "mask":
+		m, err := p.require(n, fields, "mask")
+		if err != nil {
+			return nil, err
+		}
+		mask, err := p.pattern(m)
+		if err != nil {
+			return nil, err
+		}
+		return pattern.NewMask(a, b, mask), nil
+	case "multiply":
+		return pattern.NewMultiply(a, b), nil
+	}
+	return pattern.NewAdd(a, b), nil
+}
+
+func (p *parser) perturbed(n *yaml.Node) (pattern.Pattern, error) {
+	fields, err := p.fields(n, "type", "transform", "pattern", "scale", "octaves", "noise")
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.require(n, fields, "pattern")
+	if err != nil {
+		return nil, err
+	}
+	inner, err := p.pattern(v)
+	if err != nil {
+		return nil, err
+	}
+	pat := pattern.NewPerturbed(inner, 0.2)
+	if v := fields["scale"]; v != nil {
+		if pat.Scale, err = p.float(v, "scale"); err != nil {
+			return nil, err
+		}
+	}
+	if v := fields["octaves"]; v != nil {
+		if pat.Octaves, err = p.int(v, "octaves"); err != nil {
+			return nil, err
+		}
+	}
+	if v := fields["noise"]; v != nil {
+		name, err := p.str(v, "noise")
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "perlin":
+			pat.Noise = noise.Perlin
+		case "simplex":
+			pat.Noise = noise.Simplex
+		default:
+			return nil, p.errorf(v, "noise", "unknown noise %q, expected perlin or simplex", name)
+		}
+	}
+	return pat, nil
+}
+
+func (p *parser) textureMap(n *yaml.Node) (pattern.Pattern, error) {
+	fields, err := p.fields(n, "type", "transform", "mapping", "uv_pattern",
+		"left", "right", "front", "back", "up", "down")
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.require(n, fields, "mapping")
+	if err != nil {
+		return nil, err
+	}
+	name, err := p.str(v, "mapping")
+	if err != nil {
+		return nil, err
+	}
+	if name == "cube" {
+		var faces [6]pattern.UVPattern
+		for i, key := range []string{"right", "left", "up", "down", "front", "back"} {
+			f, err := p.require(n, fields, key)
+			if err != nil {
+				return nil, err
+			}
+			if faces[i], err = p.uvPattern(f); err != nil {
+				return nil, err
+			}
+		}
+		return pattern.NewCubeMap(faces), nil
+	}
+	mappings := map[string]pattern.Mapping{
+		"spherical":   pattern.SphericalMap,
+		"planar":      pattern.PlanarMap,
+		"cylindrical": pattern.CylindricalMap,
+	}
+	mapping, ok := mappings[name]
+	if !ok {
+		return nil, p.errorf(v, "mapping", "unknown mapping %q", name)
+	}
+	u, err := p.require(n, fields, "uv_pattern")
+	if err != nil {
+		return nil, err
+	}
+	uv, err := p.uvPattern(u)
+	if err != nil {
+		return nil, err
+	}
+	return pattern.NewTextureMap(uv, mapping), nil
+}
+
+func (p *parser) uvPattern(n *yaml.Node) (pattern.UVPattern, error) {
+	n = p.resolve(n)
+	typ := lookup(n, "type")
+	if typ == nil {
+		return nil, p.errorf(n, "type", "missing key")
+	}
+	name, err := p.str(typ, "type")
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "checkers":
+		return p.uvCheckers(n)
+	case "align_check":
+		return p.uvAlignCheck(n)
+	case "image":
+		return p.uvImage(n)
+	}
+	return nil, p.errorf(typ, "type", "unknown uv pattern %q", name)
+}
+
+func (p *parser) uvCheckers(n *yaml.Node) (pattern.UVPattern, error) {
+	fields, err := p.fields(n, "type", "width", "height", "colors")
+	if err != nil {
+		return nil, err
+	}
+	var size [2]float64
+	for i, key := range []string{"width", "height"} {
+		v, err := p.require(n, fields, key)
+		if err != nil {
+			return nil, err
+		}
+		if size[i], err = p.float(v, key); err != nil {
+			return nil, err
+		}
+	}
+	v, err := p.require(n, fields, "colors")
+	if err != nil {
+		return nil, err
+	}
+	colors, err := p.colors(v, "colors", 2)
+	if err != nil {
+		return nil, err
+	}
+	return pattern.NewUVCheckers(size[0], size[1], colors[0], colors[1]), nil
+}
+
+func (p *parser) uvAlignCheck(n *yaml.Node) (pattern.UVPattern, error) {
+	fields, err := p.fields(n, "type", "colors")
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.require(n, fields, "colors")
+	if err != nil {
+		return nil, err
+	}
+	corners, err := p.fields(v, "main", "ul", "ur", "bl", "br")
+	if err != nil {
+		return nil, err
+	}
+	var c [5]color.Color
+	for i, key := range []string{"main", "ul", "ur", "bl", "br"} {
+		cv, err := p.require(v, corners, key)
+		if err != nil {
+			return nil, err
+		}
+		if c[i], err = p.color(cv, key); err != nil {
+			return nil, err
+		}
+	}
+	return pattern.NewUVAlignCheck(c[0], c[1], c[2], c[3], c[4]), nil
+}
+
+func (p *parser) uvImage(n *yaml.Node) (pattern.UVPattern, error) {
+	fields, err := p.fields(n, "type", "file", "filter", "wrap", "level")
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.require(n, fields, "file")
+	if err != nil {
+		return nil, err
+	}
+	file, err := p.str(v, "file")
+	if err != nil {
+		return nil, err
+	}
+	p.scene.Files = append(p.scene.Files, p.path(file))
+	if err := p.allowed(p.path(file)); err != nil {
+		return nil, p.errorf(v, "file", "%v", err)
+	}
+	c, err := canvas.Load(p.path(file))
+	if err != nil {
+		return nil, p.errorf(v, "file", "%v", err)
+	}
+	image := pattern.NewUVImage(c)
+	if v := fields["filter"]; v != nil {
+		filters := map[string]pattern.Filter{
+			"nearest": pattern.Nearest, "bilinear": pattern.Bilinear, "trilinear": pattern.Trilinear,
+		}
+		name, err := p.str(v, "filter")
+		if err != nil {
+			return nil, err
+		}
+		f, ok := filters[name]
+		if !ok {
+			return nil, p.errorf(v, "filter", "unknown filter %q, expected nearest, bilinear or trilinear", name)
+		}
+		image.Filter = f
+	}
+	if v := fields["wrap"]; v != nil {
+		wraps := map[string]pattern.Wrap{
+			"clamp": pattern.Clamp, "repeat": pattern.Repeat, "mirror": pattern.Mirror,
+		}
+		name, err := p.str(v, "wrap")
+		if err != nil {
+			return nil, err
+		}
+		w, ok := wraps[name]
+		if !ok {
+			return nil, p.errorf(v, "wrap", "unknown wrap %q, expected clamp, repeat or mirror", name)
+		}
+		image.Wrap = w
+	}
+	if v := fields["level"]; v != nil {
+		if image.Level, err = p.float(v, "level"); err != nil {
+			return nil, err
+		}
+	}
+	return image, nil
+}
+
+func (p *parser) colors(n *yaml.Node, key string, count int) ([]color.Color, error) {
+	n = p.resolve(n)
+	if n.Kind != yaml.SequenceNode || len(n.Content) != count {
+		return nil, p.errorf(n, key, "expected a list of %d colors", count)
+	}
+	colors := make([]color.Color, count)
+	for i, c := range n.Content {
+		var err error
+		if colors[i], err = p.color(c, key); err != nil {
+			return nil, err
+		}
+	}
+	return colors, nil
+}
@@ -0,0 +1,540 @@
+package scene
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/calbim/ray-tracer/src/camera"
+	"github.com/calbim/ray-tracer/src/light"
+	"github.com/calbim/ray-tracer/src/material"
+	"github.com/calbim/ray-tracer/src/matrix"
+	"github.com/calbim/ray-tracer/src/shape"
+	"github.com/calbim/ray-tracer/src/transforms"
+	"github.com/calbim/ray-tracer/src/world"
+	"gopkg.in/yaml.v3"
+)
+
+//Scene is a world together with the camera to render it with
+type Scene struct {
+	World  world.World   `json:"world"`
+	Camera camera.Camera `json:"camera"`
+	Files  []string      `json:"-"` //the scene file and every file it includes or reads images from
+}
+
+//Error is a problem in a scene file, located by line and key
+type Error struct {
+	File string
+	Line int //0 when the problem is with the scene as a whole
+	Key  string
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	s := e.Msg
+	if e.Key != "" {
+		s = e.Key + ": " + s
+	}
+	if e.Line > 0 {
+		s = fmt.Sprintf("line %d: %s", e.Line, s)
+	}
+	if e.File != "" {
+		s = e.File + ": " + s
+	}
+	return s
+}
+
+//Load reads a scene from a YAML file. Files it includes and images it uses
+//are found relative to it. Files ending in .json are read with Unmarshal
+//instead.
+func Load(path string) (*Scene, error) {
+	s, _, err := LoadFiles(path)
+	return s, err
+}
+
+//LoadFiles is Load that also returns the files read or tried, the Files of
+//a loaded scene. When loading fails they are the files read up to the
+//problem, including one that could not be opened, so that a fix can be
+//watched for.
+func LoadFiles(path string) (*Scene, []string, error) {
+	if filepath.Ext(path) == ".json" {
+		s, err := loadJSON(path)
+		return s, []string{path}, err
+	}
+	p := newParser()
+	if err := p.include(path); err != nil {
+		return nil, p.scene.Files, err
+	}
+	s, err := p.finish(path)
+	return s, p.scene.Files, err
+}
+
+//Parse reads a scene from YAML. Files it includes and images it uses are
+//found relative to the working directory.
+func Parse(data []byte) (*Scene, error) {
+	p := newParser()
+	if err := p.parse(data); err != nil {
+		return nil, err
+	}
+	return p.finish("")
+}
+
+//Options limit what ParseWith does for a scene from a source that is not
+//trusted
+type Options struct {
+	//Root is the directory files are included and images read from, and
+	//what relative paths are found from. Files outside it are refused, and
+	//no files are read when it is empty.
+	Root string
+	//Deadline is when parsing gives up. A scene small enough to send can
+	//still take long to build, as defines can nest patterns many times
+	//over. There is no deadline when it is zero.
+	Deadline time.Time
+}
+
+//ParseWith reads a scene from YAML like Parse, within the limits of o
+func ParseWith(data []byte, o Options) (*Scene, error) {
+	p := newParser()
+	p.restricted, p.root, p.deadline = true, o.Root, o.Deadline
+	p.dir = o.Root
+	if err := p.parse(data); err != nil {
+		return nil, err
+	}
+	return p.finish("")
+}
+
+type parser struct {
+	file      string
+	dir       string
+	defines   map[string]*yaml.Node
+	including map[string]bool
+	scene     *Scene
+	hasCamera bool
+	//restricted parsers only read files within root, and give up after
+	//deadline when it is set
+	restricted bool
+	root       string
+	deadline   time.Time
+	//building holds the values being built into patterns and transforms,
+	//innermost last
+	building []*yaml.Node
+}
+
+func newParser() *parser {
+	return &parser{
+		defines:   map[string]*yaml.Node{},
+		including: map[string]bool{},
+		scene:     &Scene{},
+	}
+}
+
+func (p *parser) finish(file string) (*Scene, error) {
+	if !p.hasCamera {
+		return nil, &Error{File: file, Msg: "scene has no camera"}
+	}
+	if p.scene.World.Light == nil {
+		return nil, &Error{File: file, Msg: "scene has no light"}
+	}
+	return p.scene, nil
+}
+
+//include parses another scene file into the scene being built
+func (p *parser) include(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if p.including[abs] {
+		return fmt.Errorf("%s: included from itself", path)
+	}
+	if err := p.allowed(path); err != nil {
+		return err
+	}
+	p.scene.Files = append(p.scene.Files, path)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	file, dir := p.file, p.dir
+	p.file, p.dir = path, filepath.Dir(path)
+	p.including[abs] = true
+	err = p.parse(data)
+	p.including[abs] = false
+	p.file, p.dir = file, dir
+	return err
+}
+
+func (p *parser) parse(data []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return &Error{File: p.file, Msg: err.Error()}
+	}
+	if doc.Kind == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.SequenceNode {
+		return p.errorf(root, "", "expected a list of add, define and include items")
+	}
+	for _, item := range root.Content {
+		if err := p.item(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//item handles one entry of the top level list
+func (p *parser) item(n *yaml.Node) error {
+	if err := p.expired(n); err != nil {
+		return err
+	}
+	n = p.resolve(n)
+	if n.Kind != yaml.MappingNode {
+		return p.errorf(n, "", "expected an add, define or include item")
+	}
+	switch {
+	case lookup(n, "add") != nil:
+		return p.add(n)
+	case lookup(n, "define") != nil:
+		return p.define(n)
+	case lookup(n, "include") != nil:
+		fields, err := p.fields(n, "include")
+		if err != nil {
+			return err
+		}
+		path, err := p.str(fields["include"], "include")
+		if err != nil {
+			return err
+		}
+		err = p.include(p.path(path))
+		if _, ok := err.(*Error); err != nil && !ok {
+			return p.errorf(fields["include"], "include", "%v", err)
+		}
+		return err
+	}
+	return p.errorf(n, "", "expected an add, define or include item")
+}
+
+//define records a named value, optionally extending an earlier mapping
+func (p *parser) define(n *yaml.Node) error {
+	fields, err := p.fields(n, "define", "extend", "value")
+	if err != nil {
+		return err
+	}
+	name, err := p.str(fields["define"], "define")
+	if err != nil {
+		return err
+	}
+	value, err := p.require(n, fields, "value")
+	if err != nil {
+		return err
+	}
+	value = p.resolve(value)
+	if value.Kind == yaml.ScalarNode && value.Tag == "!!str" && value.Value == name {
+		return p.errorf(fields["value"], "value", "define %s refers to itself", name)
+	}
+	if e := fields["extend"]; e != nil {
+		base, err := p.str(e, "extend")
+		if err != nil {
+			return err
+		}
+		b, ok := p.defines[base]
+		if !ok {
+			return p.errorf(e, "extend", "%q is not defined", base)
+		}
+		if b.Kind != yaml.MappingNode || value.Kind != yaml.MappingNode {
+			return p.errorf(e, "extend", "only mappings can be extended")
+		}
+		value = merge(b, value)
+	}
+	p.defines[name] = value
+	return nil
+}
+
+//add adds a camera, light or shape to the scene
+func (p *parser) add(n *yaml.Node) error {
+	kind, err := p.str(lookup(n, "add"), "add")
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case "camera":
+		return p.camera(n)
+	case "light":
+		return p.light(n)
+	case "sphere", "plane":
+		return p.shape(n, kind)
+	}
+	return p.errorf(lookup(n, "add"), "add", "unknown item %q", kind)
+}
+
+func (p *parser) camera(n *yaml.Node) error {
+	fields, err := p.fields(n, "add", "width", "height", "field-of-view", "from", "to", "up")
+	if err != nil {
+		return err
+	}
+	if p.hasCamera {
+		return p.errorf(n, "add", "scene already has a camera")
+	}
+	var values [3]float64
+	for i, key := range []string{"width", "height", "field-of-view"} {
+		v, err := p.require(n, fields, key)
+		if err != nil {
+			return err
+		}
+		if values[i], err = p.float(v, key); err != nil {
+			return err
+		}
+		if values[i] <= 0 {
+			return p.errorf(v, key, "must be positive")
+		}
+	}
+	var view [3][]float64
+	for i, key := range []string{"from", "to", "up"} {
+		v, err := p.require(n, fields, key)
+		if err != nil {
+			return err
+		}
+		if view[i], err = p.floats(v, key, 3); err != nil {
+			return err
+		}
+	}
+	c := camera.New(values[0], values[1], values[2])
+	c.Transform = transforms.ViewTransform(point(view[0]), point(view[1]), vector(view[2]))
+	p.scene.Camera = c
+	p.hasCamera = true
+	return nil
+}
+
+func (p *parser) light(n *yaml.Node) error {
+	fields, err := p.fields(n, "add", "at", "intensity")
+	if err != nil {
+		return err
+	}
+	if p.scene.World.Light != nil {
+		return p.errorf(n, "add", "only one light is supported")
+	}
+	at, err := p.require(n, fields, "at")
+	if err != nil {
+		return err
+	}
+	position, err := p.floats(at, "at", 3)
+	if err != nil {
+		return err
+	}
+	i, err := p.require(n, fields, "intensity")
+	if err != nil {
+		return err
+	}
+	intensity, err := p.color(i, "intensity")
+	if err != nil {
+		return err
+	}
+	l := light.PointLight(point(position), intensity)
+	p.scene.World.Light = &l
+	return nil
+}
+
+func (p *parser) shape(n *yaml.Node, kind string) error {
+	fields, err := p.fields(n, "add", "material", "transform")
+	if err != nil {
+		return err
+	}
+	var s shape.Shape
+	switch kind {
+	case "sphere":
+		s = shape.NewSphere()
+	case "plane":
+		s = shape.NewPlane()
+	}
+	m := material.New()
+	if v := fields["material"]; v != nil {
+		if m, err = p.material(v); err != nil {
+			return err
+		}
+	}
+	s.SetMaterial(&m)
+	if v := fields["transform"]; v != nil {
+		t, err := p.transform(v)
+		if err != nil {
+			return err
+		}
+		s.SetTransform(t)
+	}
+	p.scene.World.Objects = append(p.scene.World.Objects, s)
+	return nil
+}
+
+//transform returns the product of a list of transformations, each applied
+//after the ones before it. Names of defined lists are expanded in place.
+func (p *parser) transform(n *yaml.Node) (*matrix.Matrix, error) {
+	var list []*matrix.Matrix
+	if err := p.transforms(n, &list); err != nil {
+		return nil, err
+	}
+	return transforms.Chain(list...), nil
+}
+
+func (p *parser) transforms(n *yaml.Node, list *[]*matrix.Matrix) error {
+	n, err := p.enter(n, "transform")
+	if err != nil {
+		return err
+	}
+	defer p.leave()
+	if n.Kind != yaml.SequenceNode {
+		return p.errorf(n, "transform", "expected a list of transformations")
+	}
+	for _, item := range n.Content {
+		item = p.resolve(item)
+		if item.Kind == yaml.SequenceNode && len(item.Content) > 0 && p.resolve(item.Content[0]).Kind == yaml.SequenceNode {
+			if err := p.transforms(item, list); err != nil {
+				return err
+			}
+			continue
+		}
+		if item.Kind != yaml.SequenceNode || len(item.Content) == 0 {
+			return p.errorf(item, "transform", "expected a transformation such as [translate, 1, 2, 3]")
+		}
+		op, err := p.str(item.Content[0], "transform")
+		if err != nil {
+			return err
+		}
+		args := make([]float64, len(item.Content)-1)
+		for i, a := range item.Content[1:] {
+			if args[i], err = p.float(a, op); err != nil {
+				return err
+			}
+		}
+		m, err := p.operation(item, op, args)
+		if err != nil {
+			return err
+		}
+		*list = append(*list, m)
+	}
+	return nil
+}
+
+func (p *parser) operation(n *yaml.Node, op string, args []float64) (*matrix.Matrix, error) {
+	want := map[string]int{
+		"translate": 3, "scale": 3, "rotate-x": 1, "rotate-y": 1, "rotate-z": 1, "shear": 6,
+	}
+	count, ok := want[op]
+	if !ok {
+		return nil, p.errorf(n, "transform", "unknown transformation %q", op)
+	}
+	if len(args) != count {
+		return nil, p.errorf(n, op, "wrong number of arguments, expected %d but got %d", count, len(args))
+	}
+	switch op {
+	case "translate":
+		return transforms.Translation(args[0], args[1], args[2]), nil
+	case "scale":
+		return transforms.Scaling(args[0], args[1], args[2]), nil
+	case "rotate-x":
+		return transforms.RotationX(args[0]), nil
+	case "rotate-y":
+		return transforms.RotationY(args[0]), nil
+	case "rotate-z":
+		return transforms.RotationZ(args[0]), nil
+	}
+	return transforms.Shearing(args[0], args[1], args[2], args[3], args[4], args[5]), nil
+}
+
+//path returns a path in the scene relative to the file being parsed
+func (p *parser) path(path string) string {
+	if filepath.IsAbs(path) || p.dir == "" {
+		return path
+	}
+	return filepath.Join(p.dir, path)
+}
+
+//allowed returns an error if a restricted parser may not read a file
+func (p *parser) allowed(path string) error {
+	if !p.restricted {
+		return nil
+	}
+	if p.root == "" {
+		return fmt.Errorf("%s: files cannot be read", path)
+	}
+	root, err := realPath(p.root)
+	if err != nil {
+		return err
+	}
+	abs, err := realPath(path)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s: outside %s", path, p.root)
+	}
+	return nil
+}
+
+//realPath returns the absolute path of a file with symbolic links followed,
+//as far as they can be for a file that does not exist
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real, nil
+	}
+	return abs, nil
+}
+
+//enter resolves a value about to be built into a pattern or transform and
+//notes it as being built until leave is called. Meeting a value again while
+//it is being built means a define is used within itself, which would be
+//built forever, so it is an error.
+func (p *parser) enter(n *yaml.Node, key string) (*yaml.Node, error) {
+	r := p.resolve(n)
+	for _, b := range p.building {
+		if b != r {
+			continue
+		}
+		var names []string
+		for name, d := range p.defines {
+			if d == r {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return nil, p.errorf(n, key, "value refers to itself")
+		}
+		sort.Strings(names)
+		return nil, p.errorf(n, key, "define %s refers to itself", names[0])
+	}
+	p.building = append(p.building, r)
+	return r, nil
+}
+
+//leave ends the building of the value last entered
+func (p *parser) leave() {
+	p.building = p.building[:len(p.building)-1]
+}
+
+//expired returns an error if a restricted parser's deadline has passed
+func (p *parser) expired(n *yaml.Node) error {
+	if p.deadline.IsZero() || time.Now().Before(p.deadline) {
+		return nil
+	}
+	return p.errorf(n, "", "scene took too long to read")
+}
+
+//merge returns the mapping base with the keys of over added or replaced
+func merge(base, over *yaml.Node) *yaml.Node {
+	m := &yaml.Node{Kind: yaml.MappingNode, Tag: base.Tag, Line: over.Line, Column: over.Column}
+	for i := 0; i < len(base.Content); i += 2 {
+		if lookup(over, base.Content[i].Value) == nil {
+			m.Content = append(m.Content, base.Content[i], base.Content[i+1])
+		}
+	}
+	m.Content = append(m.Content, over.Content...)
+	return m
+}
@@ -0,0 +1,447 @@
+package scene
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/calbim/ray-tracer/src/camera"
+	"github.com/calbim/ray-tracer/src/canvas"
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/material"
+	"github.com/calbim/ray-tracer/src/matrix"
+	"github.com/calbim/ray-tracer/src/pattern"
+	"github.com/calbim/ray-tracer/src/shape"
+	"github.com/calbim/ray-tracer/src/transforms"
+	"github.com/calbim/ray-tracer/src/tuple"
+	"github.com/calbim/ray-tracer/src/world"
+)
+
+const basic = `
+- add: camera
+  width: 100
+  height: 50
+  field-of-view: 0.785
+  from: [0, 1.5, -5]
+  to: [0, 1, 0]
+  up: [0, 1, 0]
+- add: light
+  at: [-10, 10, -10]
+  intensity: [1, 1, 1]
+`
+
+func TestParseCameraAndLight(t *testing.T) {
+	s, err := Parse([]byte(basic))
+	if err != nil {
+		t.Fatalf("wanted scene to parse, got %v", err)
+	}
+	if s.Camera.HSize != 100 || s.Camera.VSize != 50 || s.Camera.FieldOfView != 0.785 {
+		t.Errorf("wanted camera 100x50 with field of view 0.785, got %vx%v with %v", s.Camera.HSize, s.Camera.VSize, s.Camera.FieldOfView)
+	}
+	view := transforms.ViewTransform(tuple.Point(0, 1.5, -5), tuple.Point(0, 1, 0), tuple.Vector(0, 1, 0))
+	if !s.Camera.Transform.Equals(view) {
+		t.Errorf("wanted camera transform=%v, got %v", view, s.Camera.Transform)
+	}
+	if !s.World.Light.Position.Equals(tuple.Point(-10, 10, -10)) || !s.World.Light.Intensity.Equals(color.White) {
+		t.Errorf("wanted light at %v, got %v", tuple.Point(-10, 10, -10), s.World.Light)
+	}
+}
+
+func TestParseShapesWithMaterialsAndTransforms(t *testing.T) {
+	s, err := Parse([]byte(basic + `
+- add: plane
+- add: sphere
+  material:
+    color: [1, 0.5, 0]
+    diffuse: 0.7
+    reflective: 0.3
+    light-samples: 4
+    model: metallic-roughness
+  transform:
+    - [scale, 0.5, 0.5, 0.5]
+    - [translate, 1, 2, 3]
+`))
+	if err != nil {
+		t.Fatalf("wanted scene to parse, got %v", err)
+	}
+	if len(s.World.Objects) != 2 {
+		t.Fatalf("wanted 2 objects, got %v", len(s.World.Objects))
+	}
+	if _, ok := s.World.Objects[0].(*shape.Plane); !ok {
+		t.Errorf("wanted first object to be a plane, got %T", s.World.Objects[0])
+	}
+	sphere := s.World.Objects[1]
+	m := sphere.GetMaterial()
+	if !m.Color.Equals(color.New(1, 0.5, 0)) || m.Diffuse != 0.7 || m.Reflective != 0.3 || m.Specular != 0.9 {
+		t.Errorf("wanted material with overridden color, diffuse and reflective, got %+v", m)
+	}
+	if m.LightSamples != 4 || m.Model != material.MetallicRoughness {
+		t.Errorf("wanted 4 light samples and the metallic roughness model, got %v and %v", m.LightSamples, m.Model)
+	}
+	want := transforms.Chain(transforms.Scaling(0.5, 0.5, 0.5), transforms.Translation(1, 2, 3))
+	if !sphere.GetTransform().Equals(want) {
+		t.Errorf("wanted transform=%v, got %v", want, sphere.GetTransform())
+	}
+}
+
+func TestParseDefineAndExtend(t *testing.T) {
+	s, err := Parse([]byte(basic + `
+- define: white-material
+  value:
+    color: [1, 1, 1]
+    diffuse: 0.7
+    ambient: 0.2
+- define: blue-material
+  extend: white-material
+  value:
+    color: [0.5, 0.8, 0.9]
+- define: standard-transform
+  value:
+    - [translate, 1, -1, 1]
+    - [scale, 0.5, 0.5, 0.5]
+- define: large-object
+  value:
+    - standard-transform
+    - [scale, 4, 4, 4]
+- add: sphere
+  material: blue-material
+  transform:
+    - large-object
+    - [translate, 0, 1, 0]
+`))
+	if err != nil {
+		t.Fatalf("wanted scene to parse, got %v", err)
+	}
+	o := s.World.Objects[0]
+	m := o.GetMaterial()
+	if !m.Color.Equals(color.New(0.5, 0.8, 0.9)) || m.Diffuse != 0.7 || m.Ambient != 0.2 {
+		t.Errorf("wanted extended material, got %+v", m)
+	}
+	want := transforms.Chain(transforms.Translation(1, -1, 1), transforms.Scaling(0.5, 0.5, 0.5),
+		transforms.Scaling(4, 4, 4), transforms.Translation(0, 1, 0))
+	if !o.GetTransform().Equals(want) {
+		t.Errorf("wanted transform=%v, got %v", want, o.GetTransform())
+	}
+}
+
+func TestParsePatterns(t *testing.T) {
+	s, err := Parse([]byte(basic + `
+- add: sphere
+  material:
+    pattern:
+      type: checkers
+      colors:
+        - type: stripes
+          colors: [[1, 0, 0], [0, 0, 1]]
+          transform:
+            - [scale, 0.5, 0.5, 0.5]
+        - [1, 1, 1]
+      transform:
+        - [scale, 2, 2, 2]
+- add: sphere
+  material:
+    pattern:
+      type: map
+      mapping: planar
+      uv_pattern:
+        type: checkers
+        width: 2
+        height: 2
+        colors: [[0, 0, 0], [1, 1, 1]]
+    bump:
+      type: perturbed
+      noise: simplex
+      scale: 0.1
+      pattern:
+        type: marble
+        colors: [[0, 0, 0], [1, 1, 1]]
+        turbulence: 3
+`))
+	if err != nil {
+		t.Fatalf("wanted scene to parse, got %v", err)
+	}
+	first := s.World.Objects[0].GetMaterial()
+	tests := []struct {
+		point tuple.Tuple
+		want  color.Color
+	}{
+		{tuple.Point(0.5, 0, 0), color.New(1, 0, 0)},
+		{tuple.Point(1.5, 0, 0), color.New(0, 0, 1)},
+		{tuple.Point(2.5, 0, 0), color.White},
+	}
+	for _, test := range tests {
+		if c := first.ColorAt(s.World.Objects[0], test.point); !c.Equals(test.want) {
+			t.Errorf("wanted color at %v=%v, got %v", test.point, test.want, c)
+		}
+	}
+	second := s.World.Objects[1].GetMaterial()
+	if c := second.ColorAt(s.World.Objects[1], tuple.Point(0.75, 0, 0.25)); !c.Equals(color.White) {
+		t.Errorf("wanted texture mapped color=%v, got %v", color.White, c)
+	}
+	bump, ok := second.Bump.(*pattern.Perturbed)
+	if !ok || bump.Scale != 0.1 {
+		t.Fatalf("wanted a perturbed bump pattern with scale 0.1, got %#v", second.Bump)
+	}
+	if marble, ok := bump.Pattern.(*pattern.Marble); !ok || marble.Turbulence != 3 {
+		t.Errorf("wanted a marble pattern with turbulence 3, got %#v", bump.Pattern)
+	}
+}
+
+func TestParseErrorsGiveLineAndKey(t *testing.T) {
+	tests := []struct {
+		yaml string
+		want string
+	}{
+		{basic + "- add: sphere\n  material:\n    diffuse: high\n", `line 14: diffuse: expected a number, got "high"`},
+		{basic + "- add: sphere\n  colour: [1, 0, 0]\n", "line 13: colour: unknown key"},
+		{basic + "- add: cube\n", `line 12: add: unknown item "cube"`},
+		{basic + "- add: sphere\n  transform:\n    - [rotate-x, 1, 2]\n", "line 14: rotate-x: wrong number of arguments, expected 1 but got 2"},
+		{basic + "- add: sphere\n  material:\n    pattern:\n      type: plaid\n", `line 15: type: unknown pattern "plaid"`},
+		{basic + "- define: a\n  extend: b\n  value: {}\n", `line 13: extend: "b" is not defined`},
+		{basic + "- define: x\n  value: x\n", "line 13: value: define x refers to itself"},
+		{basic + "- define: x\n  value: y\n- define: y\n  value: x\n", "line 15: value: define y refers to itself"},
+		{basic + "- define: x\n  value: y\n- define: z\n  value: x\n- define: y\n  value: z\n", "line 17: value: define y refers to itself"},
+		{basic + "- define: t\n  value: [t]\n- add: sphere\n  transform: [t]\n", "line 13: transform: define t refers to itself"},
+		{basic + "- define: t\n  value: [u]\n- define: u\n  value: [[t]]\n- add: sphere\n  transform: [u]\n", "line 15: transform: define u refers to itself"},
+		{basic + "- define: p\n  value:\n    type: stripes\n    colors: [{type: checkers, colors: [p, [0, 0, 0]]}, [1, 1, 1]]\n- add: sphere\n  material:\n    pattern: p\n", "line 14: pattern: define p refers to itself"},
+		{basic + "- add: light\n  at: [0, 0, 0]\n  intensity: [1, 1, 1]\n", "line 12: add: only one light is supported"},
+		{"- add: light\n  at: [0, 0]\n", "line 2: at: expected a list of 3 numbers"},
+		{"- add: camera\n  width: 10\n", "line 1: height: missing key"},
+		{"- add: light\n  at: [0, 0, 0]\n  intensity: [1, 1, 1]\n", "scene has no camera"},
+	}
+	for _, test := range tests {
+		_, err := Parse([]byte(test.yaml))
+		if err == nil || err.Error() != test.want {
+			t.Errorf("wanted error %q, got %v", test.want, err)
+		}
+	}
+}
+
+func TestLoadWithIncludes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	materials := "- define: red\n  value:\n    color: [1, 0, 0]\n" +
+		"- define: textured\n  value:\n    pattern:\n      type: map\n      mapping: planar\n" +
+		"      uv_pattern:\n        type: image\n        file: textures.ppm\n"
+	files := map[string]string{
+		"main.yml":             "- include: common/setup.yml\n- add: sphere\n  material: red\n- add: sphere\n  material: textured\n",
+		"textures.ppm":         "P3\n1 1\n255\n255 0 0\n",
+		"common/setup.yml":     basic + "- include: materials.yml\n",
+		"common/materials.yml": materials,
+		"loop.yml":             "- include: loop.yml\n",
+		"bad.yml":              "- include: common/setup.yml\n- add: sphere\n  material:\n    shininess: [1]\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := Load(filepath.Join(dir, "main.yml"))
+	if err != nil {
+		t.Fatalf("wanted scene to load, got %v", err)
+	}
+	if len(s.Files) != 4 || s.Files[3] != filepath.Join(dir, "textures.ppm") {
+		t.Errorf("wanted the scene, 2 included files and a texture, got %v", s.Files)
+	}
+	if c := s.World.Objects[0].GetMaterial().Color; !c.Equals(color.New(1, 0, 0)) {
+		t.Errorf("wanted included material color=%v, got %v", color.New(1, 0, 0), c)
+	}
+	if _, err := Load(filepath.Join(dir, "loop.yml")); err == nil || !strings.Contains(err.Error(), "included from itself") {
+		t.Errorf("wanted an error for a file including itself, got %v", err)
+	}
+	_, err = Load(filepath.Join(dir, "bad.yml"))
+	want := filepath.Join(dir, "bad.yml") + ": line 4: shininess: expected a number, got a list"
+	if err == nil || err.Error() != want {
+		t.Errorf("wanted error %q, got %v", want, err)
+	}
+}
+
+func TestLoadFilesReportsFilesOnError(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.yml")
+	setup := filepath.Join(dir, "setup.yml")
+	if err := ioutil.WriteFile(main, []byte("- include: setup.yml\n- include: missing.yml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(setup, []byte(basic+"- add: sphere\n  material:\n    pattern:\n      type: map\n      mapping: planar\n      uv_pattern:\n        type: image\n        file: missing.ppm\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, files, err := LoadFiles(main)
+	want := []string{main, setup, filepath.Join(dir, "missing.ppm")}
+	if err == nil || len(files) != len(want) || files[0] != want[0] || files[1] != want[1] || files[2] != want[2] {
+		t.Errorf("wanted an error and files=%v, got %v, %v", want, files, err)
+	}
+	if err := ioutil.WriteFile(setup, []byte(basic), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, files, err = LoadFiles(main)
+	want = []string{main, setup, filepath.Join(dir, "missing.yml")}
+	if err == nil || len(files) != len(want) || files[0] != want[0] || files[1] != want[1] || files[2] != want[2] {
+		t.Errorf("wanted an error and files=%v, got %v, %v", want, files, err)
+	}
+}
+
+func TestParseWith(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	os.MkdirAll(filepath.Join(root, "common"), 0755)
+	files := map[string]string{
+		"root/common/setup.yml": basic,
+		"root/sky.ppm":          "P3\n1 1\n255\n255 0 0\n",
+		"secret.yml":            basic,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	image := "- add: sphere\n  material:\n    pattern:\n      type: map\n      mapping: planar\n      uv_pattern:\n        type: image\n        file: sky.ppm\n"
+	s, err := ParseWith([]byte("- include: common/setup.yml\n"+image), Options{Root: root})
+	if err != nil {
+		t.Fatalf("wanted files within the root read, got %v", err)
+	}
+	if len(s.World.Objects) != 1 {
+		t.Errorf("wanted 1 object, got %v", len(s.World.Objects))
+	}
+	tests := []struct {
+		scene string
+		o     Options
+		want  string
+	}{
+		{"- include: ../secret.yml\n", Options{Root: root}, "outside"},
+		{"- include: " + filepath.Join(dir, "secret.yml") + "\n", Options{Root: root}, "outside"},
+		{"- include: common/setup.yml\n", Options{}, "files cannot be read"},
+		{basic + image, Options{}, "line 19: file: sky.ppm: files cannot be read"},
+		{basic, Options{Deadline: time.Now().Add(-time.Second)}, "line 2: scene took too long to read"},
+	}
+	for _, test := range tests {
+		if _, err := ParseWith([]byte(test.scene), test.o); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("wanted an error containing %q for\n%s\ngot %v", test.want, test.scene, err)
+		}
+	}
+}
+
+func TestParsedSceneRenders(t *testing.T) {
+	s, err := Parse([]byte(basic + `
+- add: sphere
+  transform:
+    - [translate, 0, 1, 0]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := camera.New(11, 11, 0.785)
+	c.Transform = s.Camera.Transform
+	image := c.Render(s.World)
+	middle := image.Pixels[5][5]
+	if middle.Equals(color.Black) || math.IsNaN(middle.R) {
+		t.Errorf("wanted the sphere in the middle of the image, got %v", middle)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	s, err := Parse([]byte(basic + `
+- add: sphere
+  material:
+    pattern:
+      type: checkers
+      colors: [[1, 0, 0], [0, 0, 1]]
+      transform:
+        - [scale, 0.25, 0.25, 0.25]
+    bump:
+      type: noise
+      colors: [[0, 0, 0], [1, 1, 1]]
+    model: metallic-roughness
+    metallic: 0.8
+    roughness: 0.3
+    emission: [0.1, 0.2, 0.3]
+- add: plane
+  material:
+    reflective: 0.5
+  transform:
+    - [translate, 0, -1, 0]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sky := canvas.New(4, 2)
+	sky.WritePixel(1, 0, color.New(0.2, 0.4, 1))
+	s.World.Background = world.NewEquirectangular(&sky)
+	s.World.MaxDepth = 3
+	data, err := Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "scene.json")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Marshal(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Errorf("wanted the scene to encode the same after a round trip, got\n%s\nand\n%s", data, again)
+	}
+	if len(loaded.Files) != 1 || loaded.Files[0] != path {
+		t.Errorf("wanted files=%v, got %v", []string{path}, loaded.Files)
+	}
+	c := camera.New(11, 11, 0.785)
+	c.Transform = s.Camera.Transform
+	want := c.Render(s.World)
+	c.Transform = loaded.Camera.Transform
+	got := c.Render(loaded.World)
+	for y := range want.Pixels {
+		for x := range want.Pixels[y] {
+			if !got.Pixels[y][x].Equals(want.Pixels[y][x]) {
+				t.Fatalf("wanted pixel (%d,%d)=%v, got %v", x, y, want.Pixels[y][x], got.Pixels[y][x])
+			}
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	if _, err := Unmarshal([]byte(`{"world":{"objects":[{"type":"torus"}]}}`)); err == nil || !strings.Contains(err.Error(), `unknown type "torus"`) {
+		t.Errorf("wanted an error for an unknown shape, got %v", err)
+	}
+	if _, err := Unmarshal([]byte(`{"world":{"objects":[{"type":"sphere","material":{"model":"lambert"}}]}}`)); err == nil {
+		t.Errorf("wanted an error for an unknown material model")
+	}
+	if _, err := Unmarshal([]byte(`{"camera":{"hsize":0,"vsize":10,"field_of_view":1},"world":{"light":` + testLight + `}}`)); err == nil {
+		t.Errorf("wanted an error for a camera with no width")
+	}
+	if _, err := Unmarshal([]byte(`{"camera":{"hsize":20,"vsize":10,"field_of_view":1},"world":{"objects":[{"type":"sphere"}]}}`)); err == nil || err.Error() != "scene has no light" {
+		t.Errorf("wanted an error for a scene with no light, got %v", err)
+	}
+}
+
+//testLight is the JSON of a light for scenes decoded in tests
+const testLight = `{"intensity":[1,1,1],"position":[-10,10,-10,1]}`
+
+func TestUnmarshalRebuildsCamera(t *testing.T) {
+	s, err := Unmarshal([]byte(`{"camera":{"hsize":200,"vsize":125,"field_of_view":1.5707963267948966,"pixel_size":100,"half_width":-1},"world":{"light":` + testLight + `}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := camera.New(200, 125, math.Pi/2)
+	if s.Camera.PixelSize != want.PixelSize || s.Camera.HalfWidth != want.HalfWidth || s.Camera.HalfHeight != want.HalfHeight {
+		t.Errorf("wanted camera=%+v, got %+v", want, s.Camera)
+	}
+	if !s.Camera.Transform.Equals(matrix.Identity) {
+		t.Errorf("wanted the identity transform, got %v", s.Camera.Transform)
+	}
+}
@@ -0,0 +1,159 @@
+package scene
+
+import (
+	"fmt"
+
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/tuple"
+	"gopkg.in/yaml.v3"
+)
+
+func (p *parser) errorf(n *yaml.Node, key string, format string, args ...interface{}) error {
+	return &Error{File: p.file, Line: n.Line, Key: key, Msg: fmt.Sprintf(format, args...)}
+}
+
+//resolve follows YAML aliases and the names of defined values. A name met
+//twice is left as it is rather than followed around a cycle.
+func (p *parser) resolve(n *yaml.Node) *yaml.Node {
+	var seen []string
+	for {
+		switch {
+		case n.Kind == yaml.AliasNode:
+			n = n.Alias
+		case n.Kind == yaml.ScalarNode && n.Tag == "!!str" && p.defines[n.Value] != nil:
+			for _, name := range seen {
+				if name == n.Value {
+					return n
+				}
+			}
+			seen = append(seen, n.Value)
+			n = p.defines[n.Value]
+		default:
+			return n
+		}
+	}
+}
+
+//fields returns the values of a mapping by key, failing on keys that are
+//not allowed or appear twice
+func (p *parser) fields(n *yaml.Node, allowed ...string) (map[string]*yaml.Node, error) {
+	n = p.resolve(n)
+	if n.Kind != yaml.MappingNode {
+		return nil, p.errorf(n, "", "expected a mapping")
+	}
+	fields := map[string]*yaml.Node{}
+	for i := 0; i < len(n.Content); i += 2 {
+		key := n.Content[i]
+		ok := false
+		for _, a := range allowed {
+			ok = ok || key.Value == a
+		}
+		if !ok {
+			return nil, p.errorf(key, key.Value, "unknown key")
+		}
+		if fields[key.Value] != nil {
+			return nil, p.errorf(key, key.Value, "repeated key")
+		}
+		fields[key.Value] = n.Content[i+1]
+	}
+	return fields, nil
+}
+
+//require returns a field that must be present in mapping n
+func (p *parser) require(n *yaml.Node, fields map[string]*yaml.Node, key string) (*yaml.Node, error) {
+	v, ok := fields[key]
+	if !ok {
+		return nil, p.errorf(n, key, "missing key")
+	}
+	return v, nil
+}
+
+//lookup returns the value of a key in a mapping, or nil
+func lookup(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func (p *parser) str(n *yaml.Node, key string) (string, error) {
+	n = alias(n)
+	if n.Kind != yaml.ScalarNode {
+		return "", p.errorf(n, key, "expected a name")
+	}
+	return n.Value, nil
+}
+
+func (p *parser) float(n *yaml.Node, key string) (float64, error) {
+	n = p.resolve(n)
+	var f float64
+	if n.Kind != yaml.ScalarNode || (n.Tag != "!!int" && n.Tag != "!!float") || n.Decode(&f) != nil {
+		return 0, p.errorf(n, key, "expected a number, got %s", describe(n))
+	}
+	return f, nil
+}
+
+func (p *parser) int(n *yaml.Node, key string) (int, error) {
+	n = p.resolve(n)
+	var i int
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!int" || n.Decode(&i) != nil {
+		return 0, p.errorf(n, key, "expected a whole number, got %s", describe(n))
+	}
+	return i, nil
+}
+
+func (p *parser) floats(n *yaml.Node, key string, count int) ([]float64, error) {
+	n = p.resolve(n)
+	if n.Kind != yaml.SequenceNode || len(n.Content) != count {
+		return nil, p.errorf(n, key, "expected a list of %d numbers", count)
+	}
+	values := make([]float64, count)
+	for i, v := range n.Content {
+		f, err := p.float(v, key)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = f
+	}
+	return values, nil
+}
+
+func (p *parser) color(n *yaml.Node, key string) (color.Color, error) {
+	v, err := p.floats(n, key, 3)
+	if err != nil {
+		return color.Black, err
+	}
+	return color.New(v[0], v[1], v[2]), nil
+}
+
+//alias follows YAML aliases only, so that names are not replaced by
+//the values defined for them
+func alias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
+
+func describe(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	return fmt.Sprintf("%q", n.Value)
+}
+
+func point(v []float64) tuple.Tuple {
+	return tuple.Point(v[0], v[1], v[2])
+}
+
+func vector(v []float64) tuple.Tuple {
+	return tuple.Vector(v[0], v[1], v[2])
+}
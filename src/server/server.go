@@ -0,0 +1,395 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/calbim/ray-tracer/src/camera"
+	"github.com/calbim/ray-tracer/src/scene"
+)
+
+//Status is the state of a render job
+type Status string
+
+//Job states, in the order a job passes through them
+const (
+	Queued    Status = "queued"
+	Rendering Status = "rendering"
+	Done      Status = "done"
+	Failed    Status = "failed"
+)
+
+//maxScene is the largest scene document accepted, in bytes
+const maxScene = 32 << 20
+
+//Defaults for the limits of a Server left at zero
+const (
+	DefaultMaxWidth     = 4096
+	DefaultMaxHeight    = 4096
+	DefaultMaxSamples   = 256
+	DefaultParseTimeout = 10 * time.Second
+	DefaultJobTTL       = time.Hour
+)
+
+//Server is an http.Handler that renders scenes in the background. It
+//serves:
+//
+//	POST /jobs             queue a YAML or JSON scene, answering with the job
+//	GET  /jobs/{id}        the job's status and progress
+//	GET  /jobs/{id}/image  the finished image as PNG
+//
+//POST /jobs takes the optional query parameters width, height and samples.
+//Scenes are read with scene.ParseWith or scene.Unmarshal. YAML scenes may
+//only include files and read images from within Root.
+//
+//The fields of a Server should be set before jobs are posted.
+type Server struct {
+	//Threads is the number of rows each job renders at once,
+	//runtime.NumCPU() when zero
+	Threads int
+	//MaxWidth, MaxHeight and MaxSamples are the largest image and number of
+	//samples per pixel a job may have, DefaultMaxWidth, DefaultMaxHeight
+	//and DefaultMaxSamples when zero. Jobs over them are refused.
+	MaxWidth   int
+	MaxHeight  int
+	MaxSamples int
+	//ParseTimeout is how long a scene may take to read before it is
+	//refused, DefaultParseTimeout when zero
+	ParseTimeout time.Duration
+	//JobTTL is how long a finished or failed job is kept for its status and
+	//image to be fetched, DefaultJobTTL when zero
+	JobTTL time.Duration
+	//Root is the directory YAML scenes may include files and read images
+	//from. When it is empty, scenes may not read files.
+	Root string
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	nextID int
+	queue  chan *job
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+//job is a scene waiting for, going through or finished with rendering
+type job struct {
+	id       string
+	scene    *scene.Scene
+	width    int
+	height   int
+	samples  int
+	status   Status
+	done     int //rows rendered
+	err      error
+	png      []byte
+	created  time.Time
+	started  time.Time
+	finished time.Time
+}
+
+//JobStatus is the JSON form of a job
+type JobStatus struct {
+	ID       string     `json:"id"`
+	Status   Status     `json:"status"`
+	Progress float64    `json:"progress"` //fraction of rows rendered, from 0 to 1
+	Width    int        `json:"width"`
+	Height   int        `json:"height"`
+	Samples  int        `json:"samples"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+//New returns a server that renders up to workers jobs at once and holds up
+//to queue more waiting. Its workers run until Close is called.
+func New(workers, queue int) *Server {
+	if workers < 1 {
+		workers = 1
+	}
+	s := &Server{
+		jobs:  map[string]*job{},
+		queue: make(chan *job, queue),
+		stop:  make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+	return s
+}
+
+//Close stops the workers, canceling renders in progress, and waits for
+//them to finish. Jobs still queued are left as they are.
+func (s *Server) Close() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+func (s *Server) work() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case j := <-s.queue:
+			s.render(j)
+		}
+	}
+}
+
+func (s *Server) render(j *job) {
+	s.mu.Lock()
+	j.status = Rendering
+	j.started = time.Now()
+	s.mu.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			j.finished = time.Now()
+			j.scene = nil
+			j.status, j.err = Failed, fmt.Errorf("render failed: %v", r)
+		}
+	}()
+
+	c := j.scene.Camera
+	image := c.RenderWith(j.scene.World, camera.Options{
+		Samples: j.samples,
+		Threads: s.Threads,
+		Cancel:  s.stop,
+		Progress: func(done, total int) {
+			s.mu.Lock()
+			j.done = done
+			s.mu.Unlock()
+		},
+	})
+	var buf bytes.Buffer
+	err := image.ToPNG(&buf)
+	select {
+	case <-s.stop:
+		err = errors.New("server closed")
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.finished = time.Now()
+	j.scene = nil
+	if err != nil {
+		j.status, j.err = Failed, err
+		return
+	}
+	j.status, j.png = Done, buf.Bytes()
+}
+
+//ServeHTTP routes requests to the job endpoints
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "jobs":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		s.create(w, r)
+	case len(parts) == 2 && parts[0] == "jobs":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		s.status(w, parts[1])
+	case len(parts) == 3 && parts[0] == "jobs" && parts[2] == "image":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		s.image(w, parts[1])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxScene))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	var size [3]int
+	for i, key := range []string{"width", "height", "samples"} {
+		v := r.URL.Query().Get(key)
+		if v == "" {
+			continue
+		}
+		if size[i], err = strconv.Atoi(v); err != nil || size[i] < 1 {
+			http.Error(w, fmt.Sprintf("%s must be a positive whole number, got %q", key, v), http.StatusBadRequest)
+			return
+		}
+	}
+	sc, err := s.parse(r.Header.Get("Content-Type"), data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sc.Camera = sc.Camera.Resize(float64(size[0]), float64(size[1]))
+	j := &job{
+		scene:   sc,
+		samples: size[2],
+		width:   int(sc.Camera.HSize),
+		height:  int(sc.Camera.VSize),
+		status:  Queued,
+		created: time.Now(),
+	}
+	if j.samples == 0 {
+		j.samples = 1
+	}
+	maxWidth, maxHeight, maxSamples := orDefault(s.MaxWidth, DefaultMaxWidth), orDefault(s.MaxHeight, DefaultMaxHeight), orDefault(s.MaxSamples, DefaultMaxSamples)
+	if j.width > maxWidth || j.height > maxHeight {
+		http.Error(w, fmt.Sprintf("image is %dx%d, larger than the %dx%d allowed", j.width, j.height, maxWidth, maxHeight), http.StatusBadRequest)
+		return
+	}
+	if j.samples > maxSamples {
+		http.Error(w, fmt.Sprintf("%d samples is more than the %d allowed", j.samples, maxSamples), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.expire(j.created)
+	s.nextID++
+	j.id = strconv.Itoa(s.nextID)
+	select {
+	case s.queue <- j:
+		s.jobs[j.id] = j
+	default:
+		s.mu.Unlock()
+		http.Error(w, "too many jobs queued, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	status := j.report()
+	s.mu.Unlock()
+
+	w.Header().Set("Location", "/jobs/"+j.id)
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+//parse reads a scene as JSON if it is sent as JSON or looks like it, and as
+//YAML otherwise. JSON scenes hold everything they use and read no files.
+func (s *Server) parse(contentType string, data []byte) (*scene.Scene, error) {
+	trimmed := bytes.TrimSpace(data)
+	if strings.HasPrefix(contentType, "application/json") || bytes.HasPrefix(trimmed, []byte("{")) {
+		return scene.Unmarshal(data)
+	}
+	timeout := s.ParseTimeout
+	if timeout == 0 {
+		timeout = DefaultParseTimeout
+	}
+	return scene.ParseWith(data, scene.Options{Root: s.Root, Deadline: time.Now().Add(timeout)})
+}
+
+//expire forgets jobs that finished longer than JobTTL before now. The
+//server's lock must be held.
+func (s *Server) expire(now time.Time) {
+	ttl := s.JobTTL
+	if ttl == 0 {
+		ttl = DefaultJobTTL
+	}
+	for id, j := range s.jobs {
+		if (j.status == Done || j.status == Failed) && now.Sub(j.finished) > ttl {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+func orDefault(v, def int) int {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+func (s *Server) status(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	s.expire(time.Now())
+	j, ok := s.jobs[id]
+	var status JobStatus
+	if ok {
+		status = j.report()
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "no such job", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) image(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	s.expire(time.Now())
+	j, ok := s.jobs[id]
+	var status Status
+	var image []byte
+	var err error
+	if ok {
+		status, image, err = j.status, j.png, j.err
+	}
+	s.mu.Unlock()
+	switch {
+	case !ok:
+		http.Error(w, "no such job", http.StatusNotFound)
+	case status == Failed:
+		http.Error(w, "job failed: "+err.Error(), http.StatusConflict)
+	case status != Done:
+		http.Error(w, "job is "+string(status), http.StatusConflict)
+	default:
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Length", strconv.Itoa(len(image)))
+		w.Write(image)
+	}
+}
+
+//report returns the JSON form of a job. The server's lock must be held.
+func (j *job) report() JobStatus {
+	st := JobStatus{
+		ID:      j.id,
+		Status:  j.status,
+		Width:   j.width,
+		Height:  j.height,
+		Samples: j.samples,
+		Created: j.created,
+	}
+	if j.height > 0 {
+		st.Progress = float64(j.done) / float64(j.height)
+	}
+	if j.err != nil {
+		st.Error = j.err.Error()
+	}
+	if !j.started.IsZero() {
+		st.Started = &j.started
+	}
+	if !j.finished.IsZero() {
+		st.Finished = &j.finished
+	}
+	return st
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
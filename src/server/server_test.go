@@ -0,0 +1,324 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/calbim/ray-tracer/src/pattern"
+	"github.com/calbim/ray-tracer/src/scene"
+)
+
+const testScene = `
+- add: camera
+  width: 40
+  height: 20
+  field-of-view: 0.785
+  from: [0, 1.5, -5]
+  to: [0, 1, 0]
+  up: [0, 1, 0]
+- add: light
+  at: [-10, 10, -10]
+  intensity: [1, 1, 1]
+- add: sphere
+  transform:
+    - [translate, 0, 1, 0]
+`
+
+func post(t *testing.T, url, contentType, body string) (*http.Response, JobStatus) {
+	resp, err := http.Post(url, contentType, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var status JobStatus
+	if resp.StatusCode == http.StatusAccepted {
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp, status
+}
+
+func get(t *testing.T, url string) (*http.Response, []byte) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	return resp, buf.Bytes()
+}
+
+//wait polls a job until it is done or failed
+func wait(t *testing.T, url string) JobStatus {
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		_, body := get(t, url)
+		var status JobStatus
+		if err := json.Unmarshal(body, &status); err != nil {
+			t.Fatal(err)
+		}
+		if status.Status == Done || status.Status == Failed {
+			return status
+		}
+	}
+	t.Fatalf("timed out waiting for %s", url)
+	return JobStatus{}
+}
+
+func TestRenderJob(t *testing.T) {
+	s := New(2, 10)
+	defer s.Close()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	resp, status := post(t, ts.URL+"/jobs?width=20&samples=2", "application/x-yaml", testScene)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("wanted status %v, got %v", http.StatusAccepted, resp.Status)
+	}
+	location := resp.Header.Get("Location")
+	if location != "/jobs/"+status.ID {
+		t.Errorf("wanted location=%v, got %v", "/jobs/"+status.ID, location)
+	}
+	if status.Width != 20 || status.Height != 10 || status.Samples != 2 {
+		t.Errorf("wanted a 20x10 job with 2 samples, got %+v", status)
+	}
+	status = wait(t, ts.URL+location)
+	if status.Status != Done || status.Progress != 1 || status.Started == nil || status.Finished == nil {
+		t.Errorf("wanted a finished job, got %+v", status)
+	}
+	resp, body := get(t, ts.URL+location+"/image")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" {
+		t.Fatalf("wanted a PNG, got %v %v", resp.Status, resp.Header.Get("Content-Type"))
+	}
+	img, err := png.Decode(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 20 || b.Dy() != 10 {
+		t.Errorf("wanted a 20x10 image, got %dx%d", b.Dx(), b.Dy())
+	}
+}
+
+func TestRenderJSONJob(t *testing.T) {
+	s := New(1, 10)
+	defer s.Close()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	sc, err := scene.Parse([]byte(testScene))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := scene.Marshal(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, status := post(t, ts.URL+"/jobs", "application/json", string(data))
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("wanted status %v, got %v", http.StatusAccepted, resp.Status)
+	}
+	if status = wait(t, ts.URL+"/jobs/"+status.ID); status.Status != Done {
+		t.Errorf("wanted the job done, got %+v", status)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	s := New(1, 1)
+	s.Close()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	resp, status := post(t, ts.URL+"/jobs", "", testScene)
+	if resp.StatusCode != http.StatusAccepted || status.Status != Queued {
+		t.Fatalf("wanted a queued job, got %v %+v", resp.Status, status)
+	}
+	resp, body := get(t, ts.URL+"/jobs/"+status.ID+"/image")
+	if resp.StatusCode != http.StatusConflict || !strings.Contains(string(body), "job is queued") {
+		t.Errorf("wanted the image of a queued job to conflict, got %v %q", resp.Status, body)
+	}
+	if resp, _ := post(t, ts.URL+"/jobs", "", testScene); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("wanted a full queue to refuse jobs, got %v", resp.Status)
+	}
+}
+
+//postError posts a scene that should be refused, returning the response
+//and its body
+func postError(t *testing.T, url, contentType, body string) (*http.Response, string) {
+	resp, err := http.Post(url, contentType, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	return resp, buf.String()
+}
+
+func TestMalformedScene(t *testing.T) {
+	s := New(1, 10)
+	defer s.Close()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	malformed := `{"camera":{"hsize":20,"vsize":10,"field_of_view":1},"world":{"objects":[{"type":"sphere","material":{"pattern":{"type":"perturbed"}}}]}}`
+	resp, body := postError(t, ts.URL+"/jobs", "application/json", malformed)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, "pattern: missing pattern") {
+		t.Errorf("wanted a bad request for a perturbed pattern with nothing to perturb, got %v %q", resp.Status, body)
+	}
+	resp, body = postError(t, ts.URL+"/jobs", "application/json", `{"camera":{"hsize":20,"vsize":10,"field_of_view":1},"world":{"objects":[{"type":"sphere"}]}}`)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, "scene has no light") {
+		t.Errorf("wanted a bad request for a scene with no light, got %v %q", resp.Status, body)
+	}
+	resp, body = postError(t, ts.URL+"/jobs", "", "- define: a\n  value: a\n")
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, "define a refers to itself") {
+		t.Errorf("wanted a bad request for a define that refers to itself, got %v %q", resp.Status, body)
+	}
+	resp, body = postError(t, ts.URL+"/jobs", "", "- define: t\n  value: [t]\n"+testScene+"- add: sphere\n  transform: [t]\n")
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, "define t refers to itself") {
+		t.Errorf("wanted a bad request for a transform that refers to itself, got %v %q", resp.Status, body)
+	}
+	if resp, status := post(t, ts.URL+"/jobs", "", testScene); resp.StatusCode != http.StatusAccepted || wait(t, ts.URL+"/jobs/"+status.ID).Status != Done {
+		t.Errorf("wanted the server to render after refusing bad scenes, got %v", resp.Status)
+	}
+}
+
+func TestRenderPanicFailsJob(t *testing.T) {
+	s := New(1, 10)
+	defer s.Close()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	sc, err := scene.Parse([]byte(testScene))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.World.Objects[0].GetMaterial().SetPattern(&pattern.Perturbed{})
+	j := &job{id: "broken", scene: sc, width: 40, height: 20, samples: 1, status: Queued, created: time.Now()}
+	s.mu.Lock()
+	s.jobs[j.id] = j
+	s.mu.Unlock()
+	s.queue <- j
+	status := wait(t, ts.URL+"/jobs/broken")
+	if status.Status != Failed || !strings.Contains(status.Error, "render failed") {
+		t.Errorf("wanted the job failed by the panic, got %+v", status)
+	}
+	if resp, _ := post(t, ts.URL+"/jobs", "", testScene); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("wanted the server to take jobs after a panic, got %v", resp.Status)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	s := New(1, 10)
+	s.MaxWidth, s.MaxHeight, s.MaxSamples = 30, 30, 4
+	defer s.Close()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	for _, query := range []string{"", "?width=200000&height=200000", "?height=31", "?width=20&samples=5"} {
+		if resp, body := postError(t, ts.URL+"/jobs"+query, "", testScene); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("wanted a bad request for %q over the limits, got %v %q", query, resp.Status, body)
+		}
+	}
+	if resp, status := post(t, ts.URL+"/jobs?width=30&samples=4", "", testScene); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("wanted a job at the limits accepted, got %v %+v", resp.Status, status)
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	root := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(root, "scene.yml"), []byte(testScene), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := New(1, 10)
+	defer s.Close()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	resp, body := postError(t, ts.URL+"/jobs", "", "- include: "+filepath.Join(root, "scene.yml")+"\n")
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, "files cannot be read") {
+		t.Errorf("wanted includes refused without a root, got %v %q", resp.Status, body)
+	}
+
+	rooted := New(1, 10)
+	rooted.Root = root
+	defer rooted.Close()
+	rts := httptest.NewServer(rooted)
+	defer rts.Close()
+	if resp, _ := post(t, rts.URL+"/jobs", "", "- include: scene.yml\n"); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("wanted an include within the root accepted, got %v", resp.Status)
+	}
+	if resp, body := postError(t, rts.URL+"/jobs", "", "- include: ../scene.yml\n"); resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, "outside") {
+		t.Errorf("wanted an include outside the root refused, got %v %q", resp.Status, body)
+	}
+}
+
+func TestFinishedJobsExpire(t *testing.T) {
+	s := New(1, 10)
+	s.JobTTL = 10 * time.Millisecond
+	defer s.Close()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	_, status := post(t, ts.URL+"/jobs", "", testScene)
+	wait(t, ts.URL+"/jobs/"+status.ID)
+	time.Sleep(20 * time.Millisecond)
+	if resp, _ := get(t, ts.URL+"/jobs/"+status.ID); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("wanted the finished job forgotten, got %v", resp.Status)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.jobs) != 0 {
+		t.Errorf("wanted no jobs kept, got %v", len(s.jobs))
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := New(1, 10)
+	defer s.Close()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/jobs", "", strings.NewReader(testScene+"  material:\n    shininess: [1]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(buf.String(), "line 16: shininess: expected a number") {
+		t.Errorf("wanted a bad request for a bad scene, got %v %q", resp.Status, buf.String())
+	}
+	if resp, _ := post(t, ts.URL+"/jobs?width=-3", "", testScene); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("wanted a bad request for a negative width, got %v", resp.Status)
+	}
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/jobs/99", http.StatusNotFound},
+		{http.MethodGet, "/jobs/99/image", http.StatusNotFound},
+		{http.MethodGet, "/other", http.StatusNotFound},
+		{http.MethodGet, "/jobs", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/jobs/1", http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, ts.URL+test.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.want {
+			t.Errorf("wanted %s %s to give %v, got %v", test.method, test.path, test.want, resp.StatusCode)
+		}
+	}
+}
@@ -62,6 +62,9 @@ type Computation struct {
 	Normal    tuple.Tuple
 	Reflectv  tuple.Tuple
 	Inside    bool
+	//GeometricNormal is the true normal of the surface, where Normal may be
+	//perturbed by a bump pattern or normal map
+	GeometricNormal tuple.Tuple
 }
 
 // PrepareComputations calculates the Computation object for an intersection
@@ -77,15 +80,17 @@ func (i *Intersection) PrepareComputations(r ray.Ray) Computation {
 		tmp := normal.Negate()
 		normal = &tmp
 	}
+	shading := object.GetMaterial().ShadingNormal(object, point, *normal)
 
 	return Computation{
-		Value:     tValue,
-		Object:    object,
-		Point:     point,
-		Overpoint: (point.Add(normal.Multiply(util.Eps))),
-		Eyev:      eyev,
-		Normal:    *normal,
-		Inside:    inside,
-		Reflectv:  r.Direction.Reflect(*normal),
+		Value:           tValue,
+		Object:          object,
+		Point:           point,
+		Overpoint:       (point.Add(normal.Multiply(util.Eps))),
+		Eyev:            eyev,
+		Normal:          shading,
+		Inside:          inside,
+		Reflectv:        r.Direction.Reflect(shading),
+		GeometricNormal: *normal,
 	}
 }
@@ -0,0 +1,93 @@
+package shape
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/calbim/ray-tracer/src/material"
+	"github.com/calbim/ray-tracer/src/matrix"
+)
+
+//Shapes are encoded as JSON objects whose "type" key names the registered
+//type and whose other keys are the shape's own fields
+var (
+	shapes = map[string]func() Shape{}
+	names  = map[reflect.Type]string{}
+)
+
+func init() {
+	Register("sphere", func() Shape { return &Sphere{} })
+	Register("plane", func() Shape { return &Plane{} })
+}
+
+//Register makes a shape type known to Marshal and Unmarshal by name. new
+//returns an empty shape of the type for decoding into. A shape decoded
+//without a transform or material is given the defaults.
+func Register(name string, new func() Shape) {
+	if _, ok := shapes[name]; ok {
+		panic("shape: Register called twice for " + name)
+	}
+	shapes[name] = new
+	names[reflect.TypeOf(new())] = name
+}
+
+//TypeName returns the name a shape's type is registered under, or its Go
+//type for a type that is not registered
+func TypeName(s Shape) string {
+	if name, ok := names[reflect.TypeOf(s)]; ok {
+		return name
+	}
+	return fmt.Sprintf("%T", s)
+}
+
+//Marshal encodes a shape of a registered type as JSON
+func Marshal(s Shape) ([]byte, error) {
+	name, ok := names[reflect.TypeOf(s)]
+	if !ok {
+		return nil, fmt.Errorf("shape: type %T is not registered", s)
+	}
+	fields, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) < 2 || fields[0] != '{' {
+		return nil, fmt.Errorf("shape: %T is not encoded as an object", s)
+	}
+	data, _ := json.Marshal(name)
+	data = append([]byte(`{"type":`), data...)
+	if len(fields) > 2 {
+		data = append(data, ',')
+	}
+	return append(data, fields[1:]...), nil
+}
+
+//Unmarshal decodes a shape written by Marshal
+func Unmarshal(data []byte) (Shape, error) {
+	var v struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	if v.Type == "" {
+		return nil, errors.New("shape: missing type")
+	}
+	new, ok := shapes[v.Type]
+	if !ok {
+		return nil, fmt.Errorf("shape: unknown type %q", v.Type)
+	}
+	s := new()
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.GetTransform() == nil {
+		s.SetTransform(matrix.Identity)
+	}
+	if s.GetMaterial() == nil {
+		m := material.New()
+		s.SetMaterial(&m)
+	}
+	return s, nil
+}
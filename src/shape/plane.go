@@ -12,8 +12,8 @@ import (
 
 //Plane is a flat surface that extends infinitely in xz
 type Plane struct {
-	Transform *matrix.Matrix
-	Material  *material.Material
+	Transform *matrix.Matrix     `json:"transform"`
+	Material  *material.Material `json:"material"`
 }
 
 //NewPlane returns a sphere with a unique ID
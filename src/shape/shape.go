@@ -17,6 +17,24 @@ type Shape interface {
 	GetTransform() *matrix.Matrix
 }
 
+//Sampler is implemented by shapes with a finite surface that points can be
+//picked from, such as spheres. Only these can act as area lights.
+type Sampler interface {
+	//LocalSurfacePoint maps u and v in [0,1) evenly over the surface, in
+	//object space
+	LocalSurfacePoint(u, v float64) tuple.Tuple
+}
+
+//SurfacePoint returns the point on a shape's surface for u and v in [0,1),
+//in world space. ok is false if the shape cannot be sampled.
+func SurfacePoint(s Shape, u, v float64) (point tuple.Tuple, ok bool) {
+	sampler, ok := s.(Sampler)
+	if !ok {
+		return tuple.Tuple{}, false
+	}
+	return s.GetTransform().MultiplyTuple(sampler.LocalSurfacePoint(u, v)), true
+}
+
 //NormalAt returns the normal of a shape at a point
 func NormalAt(s Shape, p tuple.Tuple) *tuple.Tuple {
 	transform := s.GetTransform()
@@ -4,7 +4,9 @@ import (
 	"math"
 	"testing"
 
+	"github.com/calbim/ray-tracer/src/color"
 	"github.com/calbim/ray-tracer/src/material"
+	"github.com/calbim/ray-tracer/src/pattern"
 	"github.com/calbim/ray-tracer/src/transforms"
 
 	"github.com/calbim/ray-tracer/src/matrix"
@@ -478,3 +480,90 @@ func TestPrecomputeReflectionVector(t *testing.T) {
 	}
 }
 
+func TestPrepareComputationsWithBumpMap(t *testing.T) {
+	s := NewPlane()
+	s.Material.Bump = pattern.NewGradient(color.Black, color.White)
+	s.Material.Bump.SetTransform(transforms.RotationY(math.Pi / 2))
+	r := ray.New(tuple.Point(0, 1, 0.5), tuple.Vector(0, -1, 0))
+	i := NewIntersection(1, s)
+	comps := i.PrepareComputations(r)
+	if !comps.GeometricNormal.Equals(tuple.Vector(0, 1, 0)) {
+		t.Errorf("wanted geometric normal=%v, got %v", tuple.Vector(0, 1, 0), comps.GeometricNormal)
+	}
+	if comps.Normal.Equals(comps.GeometricNormal) {
+		t.Errorf("wanted bump map to perturb normal %v", comps.Normal)
+	}
+	if !comps.Point.Equals(tuple.Point(0, 0, 0.5)) {
+		t.Errorf("wanted hit point=%v, got %v", tuple.Point(0, 0, 0.5), comps.Point)
+	}
+	if comps.Overpoint.Y <= 0 || comps.Overpoint.X != 0 || comps.Overpoint.Z != 0.5 {
+		t.Errorf("wanted overpoint to move along the geometric normal, got %v", comps.Overpoint)
+	}
+}
+
+func TestSphereSurfacePoints(t *testing.T) {
+	s := NewSphere()
+	s.SetTransform(transforms.Translation(0, 2, 0))
+	for _, uv := range [][2]float64{{0, 0}, {0.3, 0.7}, {0.9, 0.5}, {0.5, 0.99}} {
+		p, ok := SurfacePoint(s, uv[0], uv[1])
+		if !ok {
+			t.Fatalf("wanted a sphere to be sampled")
+		}
+		d := p.Subtract(tuple.Point(0, 2, 0))
+		if math.Abs(d.Magnitude()-1) > 1e-9 {
+			t.Errorf("wanted point %v on the sphere surface, got distance %v", p, d.Magnitude())
+		}
+	}
+	p, _ := SurfacePoint(s, 0.25, 0)
+	if !p.Equals(tuple.Point(0, 3, 0)) {
+		t.Errorf("wanted v=0 at the north pole %v, got %v", tuple.Point(0, 3, 0), p)
+	}
+	if _, ok := SurfacePoint(NewPlane(), 0.5, 0.5); ok {
+		t.Errorf("wanted an infinite plane not to be sampled")
+	}
+}
+
+func TestMarshalShape(t *testing.T) {
+	s := NewSphere()
+	s.SetTransform(transforms.Translation(1, 2, 3))
+	data, err := Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.(*Sphere); !ok {
+		t.Errorf("wanted a sphere, got %T", got)
+	}
+	if !got.GetTransform().Equals(s.Transform) {
+		t.Errorf("wanted transform=%v, got %v", s.Transform, got.GetTransform())
+	}
+	p, err := Unmarshal([]byte(`{"type":"plane"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.GetTransform().Equals(matrix.Identity) || *p.GetMaterial() != material.New() {
+		t.Errorf("wanted defaults for fields left out of a plane, got %v and %v", p.GetTransform(), p.GetMaterial())
+	}
+	if _, err := Unmarshal([]byte(`{"type":"cone"}`)); err == nil {
+		t.Errorf("wanted an error for an unknown shape type")
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	tests := []struct {
+		s    Shape
+		want string
+	}{
+		{NewSphere(), "sphere"},
+		{NewPlane(), "plane"},
+		{NewTestShape(), "*shape.TestShape"},
+	}
+	for _, test := range tests {
+		if got := TypeName(test.s); got != test.want {
+			t.Errorf("wanted name=%v, got %v", test.want, got)
+		}
+	}
+}
@@ -14,9 +14,9 @@ import (
 
 //Sphere struct
 type Sphere struct {
-	ID        int64
-	Transform *matrix.Matrix
-	Material  *material.Material
+	ID        int64              `json:"id"`
+	Transform *matrix.Matrix     `json:"transform"`
+	Material  *material.Material `json:"material"`
 }
 
 //NewSphere returns a sphere with a unique ID
@@ -55,6 +55,15 @@ func (s *Sphere) LocalNormalAt(p tuple.Tuple) *tuple.Tuple {
 	return &n
 }
 
+//LocalSurfacePoint returns the point on a sphere for u and v in [0,1), with
+//equal areas of the uv square covering equal areas of the sphere
+func (s *Sphere) LocalSurfacePoint(u, v float64) tuple.Tuple {
+	y := 1 - 2*v
+	r := math.Sqrt(1 - y*y)
+	phi := 2 * math.Pi * u
+	return tuple.Point(r*math.Cos(phi), y, r*math.Sin(phi))
+}
+
 //GetMaterial returns the material of the sphere
 func (s *Sphere) GetMaterial() *material.Material {
 	return s.Material
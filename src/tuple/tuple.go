@@ -1,6 +1,8 @@
 package tuple
 
 import (
+	"encoding/json"
+	"fmt"
 	"math"
 
 	"github.com/calbim/ray-tracer/src/util"
@@ -88,3 +90,21 @@ func (t *Tuple) Equals(t2 Tuple) bool {
 func (t *Tuple) Reflect(normal Tuple) Tuple {
 	return t.Subtract(normal.Multiply(2*(t.DotProduct(normal))))
 }
+
+// MarshalJSON encodes a tuple as an array of x, y, z and w
+func (t Tuple) MarshalJSON() ([]byte, error) {
+	return json.Marshal([4]float64{t.X, t.Y, t.Z, t.W})
+}
+
+// UnmarshalJSON decodes a tuple from an array of four numbers
+func (t *Tuple) UnmarshalJSON(data []byte) error {
+	var v []float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if len(v) != 4 {
+		return fmt.Errorf("tuple: expected 4 values, got %d", len(v))
+	}
+	t.X, t.Y, t.Z, t.W = v[0], v[1], v[2], v[3]
+	return nil
+}
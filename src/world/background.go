@@ -0,0 +1,49 @@
+package world
+
+import (
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/pattern"
+	"github.com/calbim/ray-tracer/src/ray"
+	"github.com/calbim/ray-tracer/src/tuple"
+)
+
+//Background supplies the color seen by rays that miss every object
+type Background interface {
+	ColorFor(r ray.Ray) color.Color
+}
+
+//Solid is a background of a single color
+type Solid struct {
+	Color color.Color
+}
+
+//ColorFor returns the color of a solid background
+func (b Solid) ColorFor(r ray.Ray) color.Color {
+	return b.Color
+}
+
+//Gradient is a background that blends from Bottom to Top with the
+//elevation of the ray
+type Gradient struct {
+	Bottom color.Color
+	Top    color.Color
+}
+
+//ColorFor returns the color of a gradient background in the ray's direction
+func (b Gradient) ColorFor(r ray.Ray) color.Color {
+	d := r.Direction.Normalize()
+	diff := b.Top.Subtract(b.Bottom)
+	return b.Bottom.Add(diff.Multiply((d.Y + 1) / 2))
+}
+
+//Environment is a background that looks a pattern up on a unit sphere
+//surrounding the scene
+type Environment struct {
+	Pattern pattern.Pattern
+}
+
+//ColorFor returns the color of the environment in the ray's direction
+func (b Environment) ColorFor(r ray.Ray) color.Color {
+	d := r.Direction.Normalize()
+	return *pattern.At(b.Pattern, tuple.Point(d.X, d.Y, d.Z))
+}
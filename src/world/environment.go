@@ -0,0 +1,100 @@
+package world
+
+import (
+	"github.com/calbim/ray-tracer/src/canvas"
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/matrix"
+	"github.com/calbim/ray-tracer/src/pattern"
+	"github.com/calbim/ray-tracer/src/ray"
+)
+
+//Cube map faces, in the order expected by NewCubeMap
+const (
+	CubeRight = pattern.CubeRight
+	CubeLeft  = pattern.CubeLeft
+	CubeUp    = pattern.CubeUp
+	CubeDown  = pattern.CubeDown
+	CubeFront = pattern.CubeFront
+	CubeBack  = pattern.CubeBack
+)
+
+//EnvironmentMap is a background image surrounding the scene at an infinite
+//distance. It is either a single equirectangular image or a cube map of six
+//faces, and can be oriented with a transform.
+type EnvironmentMap struct {
+	Faces     []*canvas.Canvas
+	Transform *matrix.Matrix
+	//inverse is the inverse of inverseOf, worked out by SetTransform so
+	//that it is not inverted again for every ray that misses
+	inverse   *matrix.Matrix
+	inverseOf *matrix.Matrix
+}
+
+//NewEquirectangular returns an environment map from a single image
+//spanning 360 degrees horizontally and 180 degrees vertically
+func NewEquirectangular(image *canvas.Canvas) *EnvironmentMap {
+	e := &EnvironmentMap{Faces: []*canvas.Canvas{image}}
+	e.SetTransform(matrix.Identity)
+	return e
+}
+
+//NewCubeMap returns an environment map from six square faces indexed by
+//CubeRight, CubeLeft, CubeUp, CubeDown, CubeFront and CubeBack
+func NewCubeMap(faces [6]*canvas.Canvas) *EnvironmentMap {
+	e := &EnvironmentMap{Faces: faces[:]}
+	e.SetTransform(matrix.Identity)
+	return e
+}
+
+//LoadEquirectangular reads an equirectangular environment map from disk
+func LoadEquirectangular(path string) (*EnvironmentMap, error) {
+	image, err := canvas.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewEquirectangular(image), nil
+}
+
+//LoadCubeMap reads the six faces of a cube map from disk, in the same
+//order as NewCubeMap
+func LoadCubeMap(paths [6]string) (*EnvironmentMap, error) {
+	var faces [6]*canvas.Canvas
+	for i, path := range paths {
+		image, err := canvas.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		faces[i] = image
+	}
+	return NewCubeMap(faces), nil
+}
+
+//SetTransform sets the orientation of an environment map
+func (e *EnvironmentMap) SetTransform(m *matrix.Matrix) {
+	e.Transform = m
+	e.inverse, _ = m.Inverse()
+	e.inverseOf = m
+}
+
+//ColorFor returns the color of the environment in the ray's direction
+func (e *EnvironmentMap) ColorFor(r ray.Ray) color.Color {
+	inv := e.inverse
+	if e.inverseOf != e.Transform || inv == nil {
+		//the transform was assigned to the field rather than set
+		inv, _ = e.Transform.Inverse()
+	}
+	d := inv.MultiplyTuple(r.Direction)
+	d = d.Normalize()
+	if len(e.Faces) == 1 {
+		// the sphere is seen from the inside, so u runs the other way
+		u, v := pattern.SphericalMap(d)
+		return sample(e.Faces[0], 1-u, v)
+	}
+	face, u, v := pattern.CubeUV(d)
+	return sample(e.Faces[face], u, v)
+}
+
+//sample returns the pixel of an image nearest to (u,v), with v pointing up
+func sample(image *canvas.Canvas, u, v float64) color.Color {
+	return *pattern.NewUVImage(image).UVPatternAt(u, v)
+}
@@ -0,0 +1,132 @@
+package world
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/calbim/ray-tracer/src/canvas"
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/light"
+	"github.com/calbim/ray-tracer/src/matrix"
+	"github.com/calbim/ray-tracer/src/pattern"
+	"github.com/calbim/ray-tracer/src/shape"
+)
+
+//jsonWorld is the encoded form of a world. Objects are encoded with
+//shape.Marshal.
+type jsonWorld struct {
+	Objects    []json.RawMessage `json:"objects"`
+	Light      *light.Light      `json:"light"`
+	Background *jsonBackground   `json:"background,omitempty"`
+	MaxDepth   int               `json:"max_depth"`
+}
+
+//jsonBackground holds any of the backgrounds, told apart by Type
+type jsonBackground struct {
+	Type      string           `json:"type"`
+	Color     *color.Color     `json:"color,omitempty"`
+	Bottom    *color.Color     `json:"bottom,omitempty"`
+	Top       *color.Color     `json:"top,omitempty"`
+	Pattern   json.RawMessage  `json:"pattern,omitempty"`
+	Faces     []*canvas.Canvas `json:"faces,omitempty"`
+	Transform *matrix.Matrix   `json:"transform,omitempty"`
+}
+
+//MarshalJSON encodes a world with its objects, light and background
+func (w World) MarshalJSON() ([]byte, error) {
+	j := jsonWorld{
+		Objects:  make([]json.RawMessage, len(w.Objects)),
+		Light:    w.Light,
+		MaxDepth: w.MaxDepth,
+	}
+	for i, o := range w.Objects {
+		data, err := shape.Marshal(o)
+		if err != nil {
+			return nil, err
+		}
+		j.Objects[i] = data
+	}
+	if w.Background != nil {
+		b, err := marshalBackground(w.Background)
+		if err != nil {
+			return nil, err
+		}
+		j.Background = b
+	}
+	return json.Marshal(j)
+}
+
+//UnmarshalJSON decodes a world written by MarshalJSON
+func (w *World) UnmarshalJSON(data []byte) error {
+	var j jsonWorld
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*w = World{Light: j.Light, MaxDepth: j.MaxDepth}
+	for _, o := range j.Objects {
+		s, err := shape.Unmarshal(o)
+		if err != nil {
+			return err
+		}
+		w.Objects = append(w.Objects, s)
+	}
+	if j.Background != nil {
+		b, err := unmarshalBackground(j.Background)
+		if err != nil {
+			return err
+		}
+		w.Background = b
+	}
+	return nil
+}
+
+func marshalBackground(b Background) (*jsonBackground, error) {
+	switch b := b.(type) {
+	case Solid:
+		return &jsonBackground{Type: "solid", Color: &b.Color}, nil
+	case Gradient:
+		return &jsonBackground{Type: "gradient", Bottom: &b.Bottom, Top: &b.Top}, nil
+	case Environment:
+		data, err := pattern.Marshal(b.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonBackground{Type: "environment", Pattern: data}, nil
+	case *EnvironmentMap:
+		return &jsonBackground{Type: "environment-map", Faces: b.Faces, Transform: b.Transform}, nil
+	}
+	return nil, fmt.Errorf("world: cannot encode background of type %T", b)
+}
+
+func unmarshalBackground(j *jsonBackground) (Background, error) {
+	switch j.Type {
+	case "solid":
+		if j.Color == nil {
+			return nil, errors.New("world: solid background is missing its color")
+		}
+		return Solid{Color: *j.Color}, nil
+	case "gradient":
+		if j.Bottom == nil || j.Top == nil {
+			return nil, errors.New("world: gradient background is missing a color")
+		}
+		return Gradient{Bottom: *j.Bottom, Top: *j.Top}, nil
+	case "environment":
+		p, err := pattern.Unmarshal(j.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return Environment{Pattern: p}, nil
+	case "environment-map":
+		if len(j.Faces) != 1 && len(j.Faces) != 6 {
+			return nil, fmt.Errorf("world: environment map needs 1 or 6 faces, got %d", len(j.Faces))
+		}
+		e := &EnvironmentMap{Faces: j.Faces}
+		if j.Transform == nil {
+			j.Transform = matrix.Identity
+		}
+		e.SetTransform(j.Transform)
+		return e, nil
+	}
+	return nil, fmt.Errorf("world: unknown background %q", j.Type)
+}
@@ -0,0 +1,173 @@
+package world
+
+import (
+	"math"
+	"sort"
+
+	"github.com/calbim/ray-tracer/src/color"
+	"github.com/calbim/ray-tracer/src/pattern"
+	"github.com/calbim/ray-tracer/src/ray"
+	"github.com/calbim/ray-tracer/src/shape"
+)
+
+//Shader colors what a ray from the camera sees in place of the world's
+//lighting, given the computations for the nearest hit or nil for a miss.
+//The shaders here show what the renderer sees when a scene looks wrong.
+type Shader interface {
+	Shade(w *World, r ray.Ray, c *shape.Computation) color.Color
+}
+
+//Normals shows the surface normal facing the camera, with x, y and z from
+//-1 to 1 as r, g and b from 0 to 1. Misses are black.
+type Normals struct{}
+
+//Shade colors a hit by its normal
+func (Normals) Shade(w *World, r ray.Ray, c *shape.Computation) color.Color {
+	if c == nil {
+		return color.Black
+	}
+	return color.New((c.Normal.X+1)/2, (c.Normal.Y+1)/2, (c.Normal.Z+1)/2)
+}
+
+//Depth shows the distance to each hit as a heatmap running from blue at
+//Near to red at Far. Misses are black.
+type Depth struct {
+	Near float64
+	Far  float64 //10 when zero
+}
+
+//Shade colors a hit by its distance
+func (d Depth) Shade(w *World, r ray.Ray, c *shape.Computation) color.Color {
+	if c == nil {
+		return color.Black
+	}
+	far := d.Far
+	if far == 0 {
+		far = 10
+	}
+	return heat((c.Value - d.Near) / (far - d.Near))
+}
+
+//UVChecker lays a black and white checker over the unlit surface color,
+//showing how textures are mapped. Planes use planar mapping, with Squares
+//squares to a unit, and other shapes spherical mapping, with Squares
+//squares from pole to pole. Misses are black.
+type UVChecker struct {
+	Squares int //16 when zero
+}
+
+//Shade colors a hit by its UV coordinates
+func (u UVChecker) Shade(w *World, r ray.Ray, c *shape.Computation) color.Color {
+	if c == nil {
+		return color.Black
+	}
+	n := float64(u.Squares)
+	if n == 0 {
+		n = 16
+	}
+	inv, _ := c.Object.GetTransform().Inverse()
+	p := inv.MultiplyTuple(c.Point)
+	mapping, checkers := pattern.SphericalMap, pattern.NewUVCheckers(2*n, n, color.Black, color.White)
+	if _, ok := c.Object.(*shape.Plane); ok {
+		mapping, checkers = pattern.PlanarMap, pattern.NewUVCheckers(n, n, color.Black, color.White)
+	}
+	albedo := w.Albedo(*c)
+	albedo = albedo.Multiply(0.5)
+	return albedo.Add(checkers.UVPatternAt(mapping(p)).Multiply(0.5))
+}
+
+//Cost shows the number of intersection tests made to shade each pixel as a
+//heatmap running from blue for none to red for Max. Worlds are a flat list
+//of objects with no bounding volume hierarchy, so the tests stand in for
+//the cost of traversing one, showing where reflections and area lights
+//spend time.
+type Cost struct {
+	Max int //8 tests for each object in the world when zero
+}
+
+//Shade shades a ray as usual and colors it by the tests that took
+func (cost Cost) Shade(w *World, r ray.Ray, c *shape.Computation) color.Color {
+	max := cost.Max
+	if max == 0 {
+		max = 8 * len(w.Objects)
+	}
+	count := *w
+	count.Shader = nil
+	count.Stats = &Stats{}
+	count.Trace(r)
+	return heat(float64(count.Stats.TotalTests()) / float64(max))
+}
+
+//Shadow shows hits white where the light reaches them and black where it
+//is blocked. Misses are gray.
+type Shadow struct{}
+
+//Shade colors a hit by whether it is in shadow
+func (Shadow) Shade(w *World, r ray.Ray, c *shape.Computation) color.Color {
+	switch {
+	case c == nil:
+		return color.New(0.5, 0.5, 0.5)
+	case w.IsShadowed(c.Overpoint):
+		return color.Black
+	}
+	return color.White
+}
+
+//ObjectIDs shows each object in a false color picked by its place in
+//World.Objects, the colors of Buffers.ObjectID. Misses are black.
+type ObjectIDs struct{}
+
+//Shade colors a hit by the object hit
+func (ObjectIDs) Shade(w *World, r ray.Ray, c *shape.Computation) color.Color {
+	if c == nil {
+		return color.Black
+	}
+	for i, o := range w.Objects {
+		if o == c.Object {
+			return color.FromID(i + 1)
+		}
+	}
+	return color.Black
+}
+
+//heatStops are the colors of a heatmap from cold to hot
+var heatStops = []color.Color{
+	color.New(0, 0, 1), color.New(0, 1, 1), color.New(0, 1, 0), color.New(1, 1, 0), color.New(1, 0, 0),
+}
+
+//heat returns the heatmap color of t from 0 to 1, clamping values outside
+func heat(t float64) color.Color {
+	t = math.Max(0, math.Min(1, t)) * float64(len(heatStops)-1)
+	i := int(t)
+	if i == len(heatStops)-1 {
+		return heatStops[i]
+	}
+	a, b := heatStops[i].Multiply(1-(t-float64(i))), heatStops[i+1].Multiply(t-float64(i))
+	return a.Add(b)
+}
+
+var shaders = map[string]Shader{
+	"normals": Normals{},
+	"depth":   Depth{},
+	"uv":      UVChecker{},
+	"cost":    Cost{},
+	"shadow":  Shadow{},
+	"ids":     ObjectIDs{},
+}
+
+//ShaderNamed returns a debug shader with its default settings by name, one
+//of ShaderNames
+func ShaderNamed(name string) (Shader, bool) {
+	s, ok := shaders[name]
+	return s, ok
+}
+
+//ShaderNames returns the names of the debug shaders in order
+func ShaderNames() []string {
+	var names []string
+	for name := range shaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
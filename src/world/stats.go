@@ -0,0 +1,90 @@
+package world
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/calbim/ray-tracer/src/shape"
+)
+
+//Stats count the work done while tracing. A world with Stats set adds to
+//them as it traces, and one without counts nothing, which costs no more
+//than a nil check per ray and object. Stats are not safe to share between
+//goroutines tracing at once, so the camera gives each thread its own and
+//adds them up.
+type Stats struct {
+	PrimaryRays    int            //rays traced by Trace and ColorAt, one per camera sample
+	ReflectionRays int            //rays followed off reflective surfaces
+	ShadowRays     int            //rays cast toward the light and area light samples
+	Tests          map[string]int //ray-object intersection tests by shape.TypeName
+	//BVHNodes is the number of bounding volume hierarchy nodes visited.
+	//Worlds are a flat list of objects with no hierarchy, so it stays zero.
+	BVHNodes     int
+	Tracing      time.Duration //time spent in Trace, summed over threads
+	Intersecting time.Duration //time spent finding intersections, summed over threads
+	Rendering    time.Duration //wall time of camera renders
+}
+
+//count records an intersection test against an object
+func (s *Stats) count(o shape.Shape) {
+	if s.Tests == nil {
+		s.Tests = map[string]int{}
+	}
+	s.Tests[shape.TypeName(o)]++
+}
+
+//Add adds the counts and times of o to s
+func (s *Stats) Add(o *Stats) {
+	s.PrimaryRays += o.PrimaryRays
+	s.ReflectionRays += o.ReflectionRays
+	s.ShadowRays += o.ShadowRays
+	for name, n := range o.Tests {
+		if s.Tests == nil {
+			s.Tests = map[string]int{}
+		}
+		s.Tests[name] += n
+	}
+	s.BVHNodes += o.BVHNodes
+	s.Tracing += o.Tracing
+	s.Intersecting += o.Intersecting
+	s.Rendering += o.Rendering
+}
+
+//Rays returns the number of rays of every kind
+func (s *Stats) Rays() int {
+	return s.PrimaryRays + s.ReflectionRays + s.ShadowRays
+}
+
+//TotalTests returns the number of intersection tests against every type
+func (s *Stats) TotalTests() int {
+	total := 0
+	for _, n := range s.Tests {
+		total += n
+	}
+	return total
+}
+
+//String summarizes the stats over a few lines
+func (s *Stats) String() string {
+	var names []string
+	for name := range s.Tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tests := make([]string, len(names))
+	for i, name := range names {
+		tests[i] = fmt.Sprintf("%d %s", s.Tests[name], name)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "rays: %d primary, %d shadow, %d reflection\n", s.PrimaryRays, s.ShadowRays, s.ReflectionRays)
+	fmt.Fprintf(&b, "intersection tests: %d", s.TotalTests())
+	if len(tests) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(tests, ", "))
+	}
+	fmt.Fprintf(&b, "\nBVH nodes visited: %d (no BVH)\n", s.BVHNodes)
+	fmt.Fprintf(&b, "time: %v rendering, %v tracing summed over threads, %v of it finding intersections\n",
+		s.Rendering.Round(time.Millisecond), s.Tracing.Round(time.Millisecond), s.Intersecting.Round(time.Millisecond))
+	return b.String()
+}
@@ -1,24 +1,33 @@
 package world
 
 import (
+	"math"
 	"sort"
+	"time"
 
 	"github.com/calbim/ray-tracer/src/color"
 	"github.com/calbim/ray-tracer/src/light"
 	"github.com/calbim/ray-tracer/src/material"
-	"github.com/calbim/ray-tracer/src/pattern"
 	"github.com/calbim/ray-tracer/src/ray"
 	"github.com/calbim/ray-tracer/src/shape"
 	"github.com/calbim/ray-tracer/src/transforms"
 	"github.com/calbim/ray-tracer/src/tuple"
+	"github.com/calbim/ray-tracer/src/util"
 )
 
 // World is a collection of objects and a light source
 type World struct {
-	Objects []shape.Shape
-	Light   *light.Light
+	Objects    []shape.Shape
+	Light      *light.Light
+	Background Background //black when nil
+	MaxDepth   int        //maximum number of reflection bounces, DefaultMaxDepth when zero
+	Stats      *Stats     //counts the work done tracing when set
+	Shader     Shader     //colors what the camera sees in place of lighting when set
 }
 
+//DefaultMaxDepth is the number of reflection bounces followed by default
+const DefaultMaxDepth = 5
+
 // Default returns a default World object
 func Default() World {
 	light := light.PointLight(tuple.Point(-10, 10, -10), color.New(1, 1, 1))
@@ -38,55 +47,180 @@ func Default() World {
 
 // Intersect returns the intersections of a collection of objects with a ray
 func (w *World) Intersect(r ray.Ray) []shape.Intersection {
+	var start time.Time
+	if w.Stats != nil {
+		start = time.Now()
+	}
 	list := []shape.Intersection{}
 	for _, o := range w.Objects {
+		if w.Stats != nil {
+			w.Stats.count(o)
+		}
 		intersections := shape.Intersect(o, r)
 		list = append(list, intersections...)
 	}
 	sort.Sort(byValue(list))
+	if w.Stats != nil {
+		w.Stats.Intersecting += time.Since(start)
+	}
 	return list
 }
 
 //ShadeHit returns the shade of a hit
 func (w *World) ShadeHit(c shape.Computation) color.Color {
+	return w.shadeHit(c, w.maxDepth())
+}
+
+func (w *World) shadeHit(c shape.Computation, remaining int) color.Color {
 	shadowed := w.IsShadowed(c.Overpoint)
 	m := c.Object.GetMaterial()
 	l := w.Light
-	return m.Lighting(getPatternObject(c.Object), *l, c.Overpoint, c.Eyev, c.Normal, shadowed)
+	surface := m.Lighting(c.Object, *l, c.Overpoint, c.Eyev, c.Normal, shadowed)
+	surface = surface.Add(w.emittedLight(c))
+	return surface.Add(w.reflectedColor(&c, remaining))
+}
+
+//goldenRatioConjugate is 1/φ, used to spread area light samples
+const goldenRatioConjugate = 0.6180339887498949
+
+//emittedLight returns the light reaching a hit from emissive objects that
+//act as area lights. Each is treated as LightSamples point lights spread
+//over its surface, and the lighting from those that are visible is averaged.
+func (w *World) emittedLight(c shape.Computation) color.Color {
+	sum := color.Black
+	for _, o := range w.Objects {
+		e := o.GetMaterial()
+		if e.LightSamples <= 0 || o == c.Object {
+			continue
+		}
+		n := float64(e.LightSamples)
+		lit := color.Black
+		for i := 0.0; i < n; i++ {
+			// a Fibonacci lattice spreads the samples evenly without noise
+			p, ok := shape.SurfacePoint(o, math.Mod(i*goldenRatioConjugate, 1), (i+0.5)/n)
+			if !ok {
+				break
+			}
+			if w.isShadowed(c.Overpoint, p, o) {
+				continue
+			}
+			l := light.PointLight(p, e.Emission)
+			lit = lit.Add(c.Object.GetMaterial().DirectLighting(c.Object, l, c.Overpoint, c.Eyev, c.Normal))
+		}
+		sum = sum.Add(lit.Multiply(1 / n))
+	}
+	return sum
 }
 
 //ColorAt returns the color of an intersection
 func (w *World) ColorAt(r ray.Ray) color.Color {
-	intersections := w.Intersect(r)
-	hit := shape.Hit(intersections)
+	c, _ := w.Trace(r)
+	return c
+}
+
+//Trace returns the color seen along a ray together with the computations
+//for the hit, which are nil when the ray misses every object. The color is
+//the Shader's if the world has one.
+func (w *World) Trace(r ray.Ray) (color.Color, *shape.Computation) {
+	if w.Stats == nil {
+		return w.primary(r)
+	}
+	start := time.Now()
+	w.Stats.PrimaryRays++
+	c, comps := w.primary(r)
+	w.Stats.Tracing += time.Since(start)
+	return c, comps
+}
+
+//primary traces a ray from the camera, through the Shader if there is one
+func (w *World) primary(r ray.Ray) (color.Color, *shape.Computation) {
+	if w.Shader == nil {
+		return w.trace(r, w.maxDepth())
+	}
+	comps := w.FirstHit(r)
+	return w.Shader.Shade(w, r, comps), comps
+}
+
+func (w *World) trace(r ray.Ray, remaining int) (color.Color, *shape.Computation) {
+	comps := w.FirstHit(r)
+	if comps == nil {
+		return w.background(r), nil
+	}
+	return w.shadeHit(*comps, remaining), comps
+}
+
+//FirstHit returns the computations for the nearest object a ray hits, or
+//nil if it misses every object. Nothing is shaded.
+func (w *World) FirstHit(r ray.Ray) *shape.Computation {
+	hit := shape.Hit(w.Intersect(r))
 	if hit == nil {
-		return color.Black
+		return nil
 	}
 	comps := hit.PrepareComputations(r)
-	return w.ShadeHit(comps)
+	return &comps
+}
+
+func (w *World) maxDepth() int {
+	if w.MaxDepth == 0 {
+		return DefaultMaxDepth
+	}
+	return w.MaxDepth
+}
+
+func (w *World) background(r ray.Ray) color.Color {
+	if w.Background == nil {
+		return color.Black
+	}
+	return w.Background.ColorFor(r)
+}
+
+//Albedo returns the unlit surface color for a hit
+func (w *World) Albedo(c shape.Computation) color.Color {
+	return c.Object.GetMaterial().ColorAt(c.Object, c.Point)
 }
 
 //IsShadowed determines if a point is shadowed in a world
 func (w *World) IsShadowed(p tuple.Tuple) bool {
-	dV := w.Light.Position.Subtract(p)
+	return w.isShadowed(p, w.Light.Position, nil)
+}
+
+//isShadowed determines if any object lies between a point and a light
+//position. The light may be a point on the surface of ignore, an emitting
+//object, whose own hit at that point does not count; the rest of ignore's
+//body still casts shadows.
+func (w *World) isShadowed(p tuple.Tuple, lightPosition tuple.Tuple, ignore shape.Shape) bool {
+	if w.Stats != nil {
+		w.Stats.ShadowRays++
+	}
+	dV := lightPosition.Subtract(p)
 	distance := dV.Magnitude()
 	r := ray.New(p, dV.Normalize())
-	intersections := w.Intersect(r)
-	hit := shape.Hit(intersections)
-	if hit != nil && hit.Value < distance {
-		return true
+	for _, i := range w.Intersect(r) {
+		if i.Object == ignore && math.Abs(i.Value-distance) < util.Eps {
+			continue
+		}
+		if i.Value >= 0 && i.Value < distance {
+			return true
+		}
 	}
 	return false
 }
 
 //ReflectedColor determines the reflected color for a precomputation
 func (w *World) ReflectedColor(c *shape.Computation) color.Color {
-	reflectivity := c.Object.GetMaterial().Reflective
-	if reflectivity == 0 {
+	return w.reflectedColor(c, w.maxDepth())
+}
+
+func (w *World) reflectedColor(c *shape.Computation, remaining int) color.Color {
+	reflectivity := c.Object.GetMaterial().ReflectiveAt(c.Object, c.Point)
+	if reflectivity == 0 || remaining <= 0 {
 		return color.Black
 	}
+	if w.Stats != nil {
+		w.Stats.ReflectionRays++
+	}
 	reflectRay := ray.New(c.Overpoint, c.Reflectv)
-	color := w.ColorAt(reflectRay)
+	color, _ := w.trace(reflectRay, remaining-1)
 	return color.Multiply(reflectivity)
 }
 
@@ -101,9 +235,3 @@ func (s byValue) Swap(i, j int) {
 func (s byValue) Less(i, j int) bool {
 	return s[i].Value < s[j].Value
 }
-
-func getPatternObject(s shape.Shape) pattern.Object {
-	o := pattern.NewObject()
-	o.Transform = s.GetTransform()
-	return o
-}
@@ -2,15 +2,19 @@ package world
 
 import (
 	"math"
+	"strings"
 	"testing"
 
 	"github.com/calbim/ray-tracer/src/util"
 
 	"github.com/calbim/ray-tracer/src/ray"
 
+	"github.com/calbim/ray-tracer/src/canvas"
 	"github.com/calbim/ray-tracer/src/color"
 	"github.com/calbim/ray-tracer/src/light"
 	"github.com/calbim/ray-tracer/src/material"
+	"github.com/calbim/ray-tracer/src/matrix"
+	"github.com/calbim/ray-tracer/src/pattern"
 	"github.com/calbim/ray-tracer/src/shape"
 	"github.com/calbim/ray-tracer/src/transforms"
 	"github.com/calbim/ray-tracer/src/tuple"
@@ -223,3 +227,338 @@ func contains(list []shape.Shape, s shape.Shape) bool {
 	}
 	return false
 }
+
+func TestColorWhenRayMissesUsesBackground(t *testing.T) {
+	w := Default()
+	w.Background = Solid{Color: color.New(0.2, 0.3, 0.4)}
+	r := ray.New(tuple.Point(0, 0, -5), tuple.Vector(0, 1, 0))
+	c := w.ColorAt(r)
+	if !c.Equals(color.New(0.2, 0.3, 0.4)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.2, 0.3, 0.4), c)
+	}
+}
+
+func TestGradientBackground(t *testing.T) {
+	b := Gradient{Bottom: color.Black, Top: color.White}
+	up := b.ColorFor(ray.New(tuple.Point(0, 0, 0), tuple.Vector(0, 2, 0)))
+	level := b.ColorFor(ray.New(tuple.Point(0, 0, 0), tuple.Vector(1, 0, 0)))
+	down := b.ColorFor(ray.New(tuple.Point(0, 0, 0), tuple.Vector(0, -1, 0)))
+	if !up.Equals(color.White) || !level.Equals(color.New(0.5, 0.5, 0.5)) || !down.Equals(color.Black) {
+		t.Errorf("wanted gradient white, grey and black, got %v, %v and %v", up, level, down)
+	}
+}
+
+func TestEnvironmentBackground(t *testing.T) {
+	b := Environment{Pattern: pattern.NewStripe(color.White, color.Black)}
+	c := b.ColorFor(ray.New(tuple.Point(0, 0, 0), tuple.Vector(-1, 0, 0)))
+	if !c.Equals(color.Black) {
+		t.Errorf("wanted color=%v, got %v", color.Black, c)
+	}
+	c = b.ColorFor(ray.New(tuple.Point(0, 0, 0), tuple.Vector(0, 0, 1)))
+	if !c.Equals(color.White) {
+		t.Errorf("wanted color=%v, got %v", color.White, c)
+	}
+}
+
+func TestTraceReturnsComputationsOfHit(t *testing.T) {
+	w := Default()
+	c, comps := w.Trace(ray.New(tuple.Point(0, 0, -5), tuple.Vector(0, 0, 1)))
+	if comps == nil || comps.Object != w.Objects[0] || comps.Value != 4 {
+		t.Errorf("wanted hit on outer sphere at t=4, got %v", comps)
+	}
+	if !c.Equals(color.New(0.38066, 0.47583, 0.2855)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.38066, 0.47583, 0.2855), c)
+	}
+	_, comps = w.Trace(ray.New(tuple.Point(0, 0, -5), tuple.Vector(0, 1, 0)))
+	if comps != nil {
+		t.Errorf("wanted no computations for a miss, got %v", comps)
+	}
+}
+
+func TestFirstHit(t *testing.T) {
+	w := Default()
+	w.Stats = &Stats{}
+	comps := w.FirstHit(ray.New(tuple.Point(0, 0, -5), tuple.Vector(0, 0, 1)))
+	if comps == nil || comps.Object != w.Objects[0] || comps.Value != 4 {
+		t.Errorf("wanted hit on outer sphere at t=4, got %v", comps)
+	}
+	if w.Stats.ShadowRays != 0 {
+		t.Errorf("wanted nothing shaded, got %v shadow rays", w.Stats.ShadowRays)
+	}
+	if comps := w.FirstHit(ray.New(tuple.Point(0, 0, -5), tuple.Vector(0, 1, 0))); comps != nil {
+		t.Errorf("wanted no computations for a miss, got %v", comps)
+	}
+}
+
+func TestShadeHitWithReflectiveMaterial(t *testing.T) {
+	w := Default()
+	plane := shape.NewPlane()
+	plane.GetMaterial().Reflective = 0.5
+	plane.SetTransform(transforms.Translation(0, -1, 0))
+	w.Objects = append(w.Objects, plane)
+	r := ray.New(tuple.Point(0, 0, -3), tuple.Vector(0, -math.Sqrt2/2, math.Sqrt2/2))
+	i := shape.NewIntersection(math.Sqrt2, plane)
+	comps := i.PrepareComputations(r)
+	col := w.ShadeHit(comps)
+	if !col.Equals(color.New(0.87677, 0.92436, 0.82918)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.87677, 0.92436, 0.82918), col)
+	}
+}
+
+func TestColorAtWithMutuallyReflectiveSurfaces(t *testing.T) {
+	w := World{}
+	l := light.PointLight(tuple.Point(0, 0, 0), color.White)
+	w.Light = &l
+	lower := shape.NewPlane()
+	lower.GetMaterial().Reflective = 1
+	lower.SetTransform(transforms.Translation(0, -1, 0))
+	upper := shape.NewPlane()
+	upper.GetMaterial().Reflective = 1
+	upper.SetTransform(transforms.Translation(0, 1, 0))
+	w.Objects = []shape.Shape{lower, upper}
+	c := w.ColorAt(ray.New(tuple.Point(0, 0, 0), tuple.Vector(0, 1, 0)))
+	if c.R <= 0 {
+		t.Errorf("wanted the recursion to terminate with a lit color, got %v", c)
+	}
+}
+
+func TestReflectedColorAtMaximumDepth(t *testing.T) {
+	w := Default()
+	w.MaxDepth = -1
+	plane := shape.NewPlane()
+	plane.GetMaterial().Reflective = 0.5
+	plane.SetTransform(transforms.Translation(0, -1, 0))
+	w.Objects = append(w.Objects, plane)
+	r := ray.New(tuple.Point(0, 0, -3), tuple.Vector(0, -math.Sqrt2/2, math.Sqrt2/2))
+	i := shape.NewIntersection(math.Sqrt2, plane)
+	comps := i.PrepareComputations(r)
+	col := w.ReflectedColor(&comps)
+	if !col.Equals(color.Black) {
+		t.Errorf("wanted color=%v, got %v", color.Black, col)
+	}
+}
+
+func TestReflectionOfBackground(t *testing.T) {
+	w := World{Background: Solid{Color: color.New(1, 0, 0)}}
+	l := light.PointLight(tuple.Point(0, 10, 0), color.White)
+	w.Light = &l
+	mirror := shape.NewPlane()
+	mirror.GetMaterial().Reflective = 1
+	w.Objects = []shape.Shape{mirror}
+	r := ray.New(tuple.Point(0, 1, -1), tuple.Vector(0, -math.Sqrt2/2, math.Sqrt2/2))
+	i := shape.NewIntersection(math.Sqrt2, mirror)
+	comps := i.PrepareComputations(r)
+	col := w.ReflectedColor(&comps)
+	if !col.Equals(color.New(1, 0, 0)) {
+		t.Errorf("wanted color=%v, got %v", color.New(1, 0, 0), col)
+	}
+}
+
+func TestEquirectangularEnvironmentMap(t *testing.T) {
+	image := canvas.New(4, 2)
+	for x := 0; x < 4; x++ {
+		image.WritePixel(x, 0, color.New(float64(x)/4, 1, 0))
+		image.WritePixel(x, 1, color.New(float64(x)/4, 0, 0))
+	}
+	e := NewEquirectangular(&image)
+	c := e.ColorFor(ray.New(tuple.Point(0, 0, 0), tuple.Vector(0, 0.5, 1)))
+	if !c.Equals(color.New(0.5, 1, 0)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.5, 1, 0), c)
+	}
+	c = e.ColorFor(ray.New(tuple.Point(0, 0, 0), tuple.Vector(-1, -0.5, 0)))
+	if !c.Equals(color.New(0.25, 0, 0)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.25, 0, 0), c)
+	}
+	e.SetTransform(transforms.RotationY(math.Pi / 2))
+	if !e.inverse.Equals(transforms.RotationY(-math.Pi / 2)) {
+		t.Errorf("wanted the inverse kept when the transform is set, got %v", e.inverse)
+	}
+	c = e.ColorFor(ray.New(tuple.Point(0, 0, 0), tuple.Vector(1, 0.5, 0)))
+	if !c.Equals(color.New(0.5, 1, 0)) {
+		t.Errorf("wanted rotated color=%v, got %v", color.New(0.5, 1, 0), c)
+	}
+	e.Transform = matrix.Identity
+	c = e.ColorFor(ray.New(tuple.Point(0, 0, 0), tuple.Vector(0, 0.5, 1)))
+	if !c.Equals(color.New(0.5, 1, 0)) {
+		t.Errorf("wanted a transform assigned directly to be used, got %v", c)
+	}
+}
+
+func TestCubeEnvironmentMap(t *testing.T) {
+	var faces [6]*canvas.Canvas
+	for i := range faces {
+		face := canvas.New(1, 1)
+		face.WritePixel(0, 0, color.New(float64(i)/10, 0, 0))
+		faces[i] = &face
+	}
+	e := NewCubeMap(faces)
+	directions := map[int]tuple.Tuple{
+		CubeRight: tuple.Vector(1, 0.2, 0.1),
+		CubeLeft:  tuple.Vector(-1, 0.5, -0.5),
+		CubeUp:    tuple.Vector(0.3, 1, 0),
+		CubeDown:  tuple.Vector(0, -1, 0.9),
+		CubeFront: tuple.Vector(0.1, 0, 1),
+		CubeBack:  tuple.Vector(-0.2, -0.3, -1),
+	}
+	for face, d := range directions {
+		c := e.ColorFor(ray.New(tuple.Point(0, 0, 0), d))
+		if !c.Equals(color.New(float64(face)/10, 0, 0)) {
+			t.Errorf("wanted direction %v to sample face %v, got %v", d, face, c)
+		}
+	}
+}
+
+func TestReflectedColorWithReflectivityMask(t *testing.T) {
+	w := Default()
+	plane := shape.NewPlane()
+	plane.GetMaterial().Reflective = 0.5
+	plane.GetMaterial().ReflectiveMap = pattern.NewStripe(color.White, color.Black)
+	plane.SetTransform(transforms.Translation(0, -1, 0))
+	w.Objects = append(w.Objects, plane)
+	r := ray.New(tuple.Point(0, 0, -3), tuple.Vector(0, -math.Sqrt2/2, math.Sqrt2/2))
+	i := shape.NewIntersection(math.Sqrt2, plane)
+	comps := i.PrepareComputations(r)
+	col := w.ReflectedColor(&comps)
+	if !col.Equals(color.New(0.19032, 0.2379, 0.14274)) {
+		t.Errorf("wanted color=%v, got %v", color.New(0.19032, 0.2379, 0.14274), col)
+	}
+	plane.GetMaterial().ReflectiveMap.SetTransform(transforms.Translation(-1, 0, 0))
+	col = w.ReflectedColor(&comps)
+	if !col.Equals(color.Black) {
+		t.Errorf("wanted color=%v, got %v", color.Black, col)
+	}
+}
+
+func TestEmissiveSphereLightsOtherObjects(t *testing.T) {
+	l := light.PointLight(tuple.Point(0, 10, 0), color.Black)
+	floor := shape.NewPlane()
+	lamp := shape.NewSphere()
+	lamp.SetTransform(transforms.Translation(0, 2, 0))
+	lamp.Material.Emission = color.White
+	w := World{Light: &l, Objects: []shape.Shape{floor, lamp}}
+	if c := w.ColorAt(ray.New(tuple.Point(0, 2, -5), tuple.Vector(0, 0, 1))); !c.Equals(color.White) {
+		t.Errorf("wanted lamp to show its emission %v, got %v", color.White, c)
+	}
+	r := ray.New(tuple.Point(0, 0.5, -0.5), tuple.Vector(0, -math.Sqrt2/2, math.Sqrt2/2))
+	if c := w.ColorAt(r); !c.Equals(color.Black) {
+		t.Errorf("wanted a glowing sphere without light samples not to light the floor, got %v", c)
+	}
+	lamp.Material.LightSamples = 64
+	// only the quarter of the lamp facing the floor reaches it
+	if c := w.ColorAt(r); c.R < 0.15 || c.R > 0.3 {
+		t.Errorf("wanted the lamp to light the floor, got %v", c)
+	}
+	blocker := shape.NewSphere()
+	blocker.SetTransform(transforms.Chain(transforms.Scaling(3, 0.1, 3), transforms.Translation(0, 0.8, 0)))
+	w.Objects = append(w.Objects, blocker)
+	if c := w.ColorAt(r); !c.Equals(color.Black) {
+		t.Errorf("wanted a blocked lamp not to light the floor, got %v", c)
+	}
+}
+
+func TestEmitterShadowsItsFarSide(t *testing.T) {
+	l := light.PointLight(tuple.Point(0, 10, 0), color.White)
+	lamp := shape.NewSphere()
+	lamp.SetTransform(transforms.Translation(0, 2, 0))
+	w := World{Light: &l, Objects: []shape.Shape{lamp}}
+	p := tuple.Point(0, 0, 0)
+	if w.isShadowed(p, tuple.Point(0, 1, 0), lamp) {
+		t.Errorf("wanted a sample facing the point to light it")
+	}
+	if !w.isShadowed(p, tuple.Point(0, 3, 0), lamp) {
+		t.Errorf("wanted a sample on the far side of the lamp to be blocked by its body")
+	}
+}
+
+func TestStats(t *testing.T) {
+	w := World{Stats: &Stats{}}
+	l := light.PointLight(tuple.Point(0, 0, 0), color.White)
+	w.Light = &l
+	lower := shape.NewPlane()
+	lower.GetMaterial().Reflective = 1
+	lower.SetTransform(transforms.Translation(0, -1, 0))
+	upper := shape.NewPlane()
+	upper.GetMaterial().Reflective = 1
+	upper.SetTransform(transforms.Translation(0, 1, 0))
+	w.Objects = []shape.Shape{lower, upper, shape.NewSphere()}
+	w.Objects[2].SetTransform(transforms.Translation(5, 0, 0))
+	w.ColorAt(ray.New(tuple.Point(0, 0, 0), tuple.Vector(0, 1, 0)))
+	s := w.Stats
+	// the ray bounces DefaultMaxDepth times, and every hit casts a shadow ray
+	if s.PrimaryRays != 1 || s.ReflectionRays != 5 || s.ShadowRays != 6 {
+		t.Errorf("wanted 1 primary, 5 reflection and 6 shadow rays, got %v, %v and %v", s.PrimaryRays, s.ReflectionRays, s.ShadowRays)
+	}
+	if s.Rays() != 12 || s.Tests["plane"] != 24 || s.Tests["sphere"] != 12 || s.TotalTests() != 36 {
+		t.Errorf("wanted 12 rays each tested against 2 planes and a sphere, got %v rays and tests %v", s.Rays(), s.Tests)
+	}
+	if s.Tracing <= 0 || s.Intersecting <= 0 || s.Intersecting > s.Tracing {
+		t.Errorf("wanted intersecting to take part of the tracing time, got %v of %v", s.Intersecting, s.Tracing)
+	}
+
+	total := Stats{}
+	total.Add(s)
+	total.Add(s)
+	if total.PrimaryRays != 2 || total.Tests["plane"] != 48 || total.Tracing != 2*s.Tracing {
+		t.Errorf("wanted stats added twice to double, got %+v", total)
+	}
+	for _, want := range []string{"rays: 1 primary, 6 shadow, 5 reflection", "intersection tests: 36 (24 plane, 12 sphere)", "BVH nodes visited: 0 (no BVH)"} {
+		if !strings.Contains(s.String(), want) {
+			t.Errorf("wanted the summary to contain %q, got %q", want, s.String())
+		}
+	}
+}
+
+func TestShaders(t *testing.T) {
+	w := Default()
+	front := ray.New(tuple.Point(0, 0, -5), tuple.Vector(0, 0, 1))
+	back := ray.New(tuple.Point(0, 0, 5), tuple.Vector(0, 0, -1))
+	miss := ray.New(tuple.Point(0, 0, -5), tuple.Vector(0, 1, 0))
+	tests := []struct {
+		name   string
+		shader Shader
+		r      ray.Ray
+		want   color.Color
+	}{
+		{"normals", Normals{}, front, color.New(0.5, 0.5, 0)},
+		{"normals miss", Normals{}, miss, color.Black},
+		{"depth", Depth{Far: 8}, front, color.New(0, 1, 0)},
+		{"depth default", Depth{}, front, color.New(0, 1, 0.4)},
+		{"depth near", Depth{Near: 2, Far: 3}, front, color.New(1, 0, 0)},
+		{"cost hit", Cost{Max: 4}, front, color.New(1, 0, 0)},
+		{"cost miss", Cost{Max: 4}, miss, color.New(0, 1, 0)},
+		{"shadow lit", Shadow{}, front, color.White},
+		{"shadow blocked", Shadow{}, back, color.Black},
+		{"shadow miss", Shadow{}, miss, color.New(0.5, 0.5, 0.5)},
+		{"ids", ObjectIDs{}, front, color.FromID(1)},
+		{"ids miss", ObjectIDs{}, miss, color.Black},
+	}
+	for _, test := range tests {
+		w.Shader = test.shader
+		got, comps := w.Trace(test.r)
+		if !got.Equals(test.want) {
+			t.Errorf("%s: wanted color=%v, got %v", test.name, test.want, got)
+		}
+		if (comps == nil) != (test.r == miss) {
+			t.Errorf("%s: wanted the computations of the hit, got %v", test.name, comps)
+		}
+	}
+
+	plane := shape.NewPlane()
+	w = World{Objects: []shape.Shape{plane}, Light: w.Light}
+	r := ray.New(tuple.Point(0.3, 1, 0.1), tuple.Vector(0, -1, 0))
+	for squares, want := range map[int]color.Color{1: color.New(0.5, 0.5, 0.5), 4: color.White} {
+		w.Shader = UVChecker{Squares: squares}
+		if got := w.ColorAt(r); !got.Equals(want) {
+			t.Errorf("wanted a plane with %d squares to a unit=%v, got %v", squares, want, got)
+		}
+	}
+
+	for _, name := range ShaderNames() {
+		if _, ok := ShaderNamed(name); !ok {
+			t.Errorf("wanted a shader named %s", name)
+		}
+	}
+	if _, ok := ShaderNamed("wireframe"); ok {
+		t.Errorf("wanted no shader named wireframe")
+	}
+}